@@ -2,23 +2,49 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/adrianrios/lunar-test/internal/api"
+	"github.com/adrianrios/lunar-test/internal/migrations"
 	"github.com/adrianrios/lunar-test/internal/rockets"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func main() {
+	// `rockets migrate` migrates the database and exits, for deployments that don't migrate on startup
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		mongoClient := getMongo()
+		runMigrations(mongoClient.Database(os.Getenv("MONGO_DATABASE")))
+		if err := mongoClient.Disconnect(context.Background()); err != nil {
+			log.Printf("Error disconnecting from MongoDB: %v", err)
+		}
+		return
+	}
+	// `rockets retention [-dry-run]` applies the retention policy once and prints the report
+	if len(os.Args) > 1 && os.Args[1] == "retention" {
+		runRetention(os.Args[2:])
+		return
+	}
+	// `rockets rebuild [-channel id] [-dry-run] [-concurrency n]` recomputes rockets from their message logs
+	if len(os.Args) > 1 && os.Args[1] == "rebuild" {
+		runRebuild(os.Args[2:])
+		return
+	}
+
 	r := chi.NewRouter()
 
 	// Middleware
@@ -32,10 +58,26 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
-	mongoClient := getMongo()
-	rocketsAPI := setupRocketsAPI(mongoClient)
+	store := setupStorage()
+	// Rockets stored by this instance are pushed to the streams
+	feed := rockets.NewRocketFeed(getEnvInt("STREAM_HISTORY", 1000))
+	// Lifecycle events of the rockets stored by this instance are sent to the webhooks
+	webhookService := rockets.NewWebhookService(store.webhookRepository, store.deadLetterRepository, rockets.WebhookPolicy{
+		MaxAttempts:    getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		InitialBackoff: getEnvDuration("WEBHOOK_INITIAL_BACKOFF", time.Second),
+		MaxBackoff:     getEnvDuration("WEBHOOK_MAX_BACKOFF", 5*time.Minute),
+		Timeout:        getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		Workers:        getEnvInt("WEBHOOK_WORKERS", 4),
+		QueueSize:      getEnvInt("WEBHOOK_QUEUE_SIZE", 1000),
+	})
+	rocketsAPI, messagesService, rebuildService := setupRocketsAPI(store.messagesRepository, store.rocketsRepository, feed, webhookService)
+	// Runtime metrics, like the number of live channel locks, tell about the internals so they need the admin token
+	r.Handle("/debug/vars", rocketsAPI.AdminOnly(expvar.Handler()))
 	h := api.HandlerFromMux(rocketsAPI, r)
 
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	retentionDone := scheduleRetention(retentionCtx, store)
+
 	// HTTP Server
 	srv := &http.Server{
 		Addr:         ":8088",
@@ -44,6 +86,8 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Streams never go idle, Shutdown would wait for them until it times out
+	srv.RegisterOnShutdown(feed.Close)
 
 	go func() {
 		log.Printf("Starting server on %s", srv.Addr)
@@ -66,26 +110,335 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	if err := mongoClient.Disconnect(shutdownCtx); err != nil {
-		log.Printf("Error disconnecting from MongoDB: %v", err)
+	// Queued messages must be applied, and gap timers stopped, before the database goes away
+	if err := messagesService.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error stopping message processing: %v", err)
 	} else {
-		log.Println("Disconnected from MongoDB")
+		log.Println("Message processing stopped")
 	}
 
+	// A fleet rebuild stops where it is, the rockets rebuilt so far stay written
+	if err := rebuildService.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error stopping the rebuild: %v", err)
+	}
+
+	// Deliveries still retrying are parked with the dead letters, to be redelivered later
+	if err := webhookService.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error stopping the webhook deliveries: %v", err)
+	}
+
+	stopRetention()
+	<-retentionDone
+	store.close(shutdownCtx)
+
 	log.Println("Server stopped")
 }
 
-func setupRocketsAPI(mongoClient *mongo.Client) *api.RocketsAPI {
+// storage holds the repositories of a backend and the function that closes it.
+type storage struct {
+	messagesRepository   rockets.MessageRepository
+	rocketsRepository    rockets.RocketsRepository
+	archiveRepository    rockets.ArchiveRepository
+	webhookRepository    rockets.WebhookRepository
+	deadLetterRepository rockets.DeadLetterRepository
+	close                func(ctx context.Context)
+}
+
+// setupStorage returns the repositories of the backend selected with STORAGE.
+func setupStorage() storage {
+	switch os.Getenv("STORAGE") {
+	case "", "mongo":
+	case "memory":
+		log.Println("Storing messages and rockets in memory, they are lost on shutdown")
+		return storage{
+			messagesRepository:   rockets.NewMemoryMessageRepository(),
+			rocketsRepository:    rockets.NewMemoryRocketsRepository(),
+			archiveRepository:    rockets.NewMemoryArchiveRepository(),
+			webhookRepository:    rockets.NewMemoryWebhookRepository(),
+			deadLetterRepository: rockets.NewMemoryDeadLetterRepository(),
+			close:                func(context.Context) {},
+		}
+	case "bolt":
+		return setupBolt()
+	default:
+		log.Fatalf("Invalid STORAGE: %s", os.Getenv("STORAGE"))
+	}
+
+	mongoClient := getMongo()
 	db := mongoClient.Database(os.Getenv("MONGO_DATABASE"))
+	if os.Getenv("MIGRATE_ON_STARTUP") != "false" {
+		runMigrations(db)
+	}
 	messagesCollection := db.Collection("messages")
 	rocketsCollection := db.Collection("rockets")
+	archiveCollection := db.Collection("archive")
+	webhooksCollection := db.Collection("webhooks")
+	deadLettersCollection := db.Collection("deadLetters")
+
+	return storage{
+		messagesRepository:   rockets.NewMongoMessageRepository(messagesCollection),
+		rocketsRepository:    rockets.NewMongoRocketsRepository(rocketsCollection),
+		archiveRepository:    rockets.NewMongoArchiveRepository(archiveCollection),
+		webhookRepository:    rockets.NewMongoWebhookRepository(webhooksCollection),
+		deadLetterRepository: rockets.NewMongoDeadLetterRepository(deadLettersCollection),
+		close: func(ctx context.Context) {
+			if err := mongoClient.Disconnect(ctx); err != nil {
+				log.Printf("Error disconnecting from MongoDB: %v", err)
+			} else {
+				log.Println("Disconnected from MongoDB")
+			}
+		},
+	}
+}
+
+// runMigrations brings the database to the last version, waiting for another replica doing the same.
+func runMigrations(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), getEnvDuration("MIGRATION_TIMEOUT", 10*time.Minute))
+	defer cancel()
+
+	if err := migrations.Migrate(ctx, db); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
+	log.Println("Database migrated")
+}
 
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	messagesService := rockets.NewResequencerMessageService(messagesRepository, rocketsRepository)
-	rocketsService := rockets.NewRocketsServiceImpl(rocketsRepository)
+// setupBolt opens the embedded database at BOLT_PATH, for deployments without MongoDB.
+func setupBolt() storage {
+	path := os.Getenv("BOLT_PATH")
+	if path == "" {
+		path = "rockets.db"
+	}
+	db, err := rockets.OpenBolt(path)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+
+	messagesRepository := rockets.NewBoltMessageRepository(db)
+	if err := messagesRepository.EnsureBuckets(context.Background()); err != nil {
+		log.Fatalf("Failed to create message buckets: %v", err)
+	}
+	rocketsRepository := rockets.NewBoltRocketsRepository(db)
+	if err := rocketsRepository.EnsureBuckets(context.Background()); err != nil {
+		log.Fatalf("Failed to create rocket buckets: %v", err)
+	}
+	archiveRepository := rockets.NewBoltArchiveRepository(db)
+	if err := archiveRepository.EnsureBuckets(context.Background()); err != nil {
+		log.Fatalf("Failed to create archive buckets: %v", err)
+	}
+	webhookRepository := rockets.NewBoltWebhookRepository(db)
+	if err := webhookRepository.EnsureBuckets(context.Background()); err != nil {
+		log.Fatalf("Failed to create webhook buckets: %v", err)
+	}
+	deadLetterRepository := rockets.NewBoltDeadLetterRepository(db)
+	if err := deadLetterRepository.EnsureBuckets(context.Background()); err != nil {
+		log.Fatalf("Failed to create dead letter buckets: %v", err)
+	}
+	log.Printf("Storing messages and rockets in %s", path)
+
+	return storage{
+		messagesRepository:   messagesRepository,
+		rocketsRepository:    rocketsRepository,
+		archiveRepository:    archiveRepository,
+		webhookRepository:    webhookRepository,
+		deadLetterRepository: deadLetterRepository,
+		close: func(context.Context) {
+			if err := db.Close(); err != nil {
+				log.Printf("Error closing %s: %v", path, err)
+			} else {
+				log.Printf("Closed %s", path)
+			}
+		},
+	}
+}
+
+func newRetentionService(store storage) *rockets.RetentionService {
+	return rockets.NewRetentionService(store.messagesRepository, store.rocketsRepository, store.archiveRepository, rockets.RetentionPolicy{
+		MaxAge:       getEnvDuration("RETENTION_MAX_AGE", 0),
+		KeepMessages: getEnvInt("RETENTION_KEEP_MESSAGES", 0),
+		ArchiveAfter: getEnvDuration("RETENTION_ARCHIVE_AFTER", 0),
+	})
+}
 
-	return api.NewRocketsAPI(messagesService, rocketsService)
+// scheduleRetention applies the retention policy every RETENTION_INTERVAL until ctx is done. The returned channel is
+// closed once the last run finished.
+func scheduleRetention(ctx context.Context, store storage) <-chan struct{} {
+	done := make(chan struct{})
+	interval := getEnvDuration("RETENTION_INTERVAL", 0)
+	if interval <= 0 {
+		close(done)
+		return done
+	}
+
+	retention := newRetentionService(store)
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := retention.Run(ctx, false)
+				if err != nil {
+					log.Printf("Error applying retention: %v", err)
+					continue
+				}
+				log.Printf("Retention compacted %d messages of %d channels, archived %d rockets",
+					report.CompactedMessages, report.CompactedChannels, report.ArchivedRockets)
+			}
+		}
+	}()
+	log.Printf("Applying retention every %s", interval)
+	return done
+}
+
+func runRetention(args []string) {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be deleted without deleting it")
+	flags.Parse(args)
+
+	store := setupStorage()
+	defer store.close(context.Background())
+
+	report, err := newRetentionService(store).Run(context.Background(), *dryRun)
+	if err != nil {
+		log.Fatalf("Failed to apply retention: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to print the report: %v", err)
+	}
+}
+
+func setupRocketsAPI(messagesRepository rockets.MessageRepository, rocketsRepository rockets.RocketsRepository, feed *rockets.RocketFeed, webhookService *rockets.WebhookService) (*api.RocketsAPI, rockets.MessageService, *rockets.RebuildService) {
+	gapPolicy := rockets.GapPolicy{
+		Timeout:    getEnvDuration("GAP_SKIP_TIMEOUT", 0),
+		MaxPending: getEnvInt("GAP_SKIP_MAX_PENDING", 0),
+	}
+	var messagesService rockets.MessageService
+	switch os.Getenv("RESEQUENCER") {
+	case "", "log":
+		resequencer := rockets.NewResequencerMessageService(messagesRepository, rocketsRepository, gapPolicy)
+		resequencer.PublishTo(feed)
+		resequencer.NotifyWebhooks(webhookService)
+		messagesService = resequencer
+	case "buffered":
+		resequencer := rockets.NewBufferedMessageService(messagesRepository, rocketsRepository, gapPolicy)
+		resequencer.ReleaseIdleAfter(getEnvDuration("BUFFER_IDLE_TIMEOUT", 5*time.Minute))
+		resequencer.PublishTo(feed)
+		resequencer.NotifyWebhooks(webhookService)
+		messagesService = resequencer
+		log.Println("Resequencing messages with in-memory buffers")
+	default:
+		log.Fatalf("Invalid RESEQUENCER: %s", os.Getenv("RESEQUENCER"))
+	}
+	if os.Getenv("INGESTION_MODE") == "async" {
+		asyncService := rockets.NewAsyncMessageService(messagesRepository, messagesService, rockets.AsyncConfig{
+			Workers:      getEnvInt("INGESTION_WORKERS", 0),
+			QueueSize:    getEnvInt("INGESTION_QUEUE_SIZE", 0),
+			Backpressure: rockets.Backpressure(os.Getenv("INGESTION_BACKPRESSURE")),
+			MaxAttempts:  getEnvInt("INGESTION_MAX_ATTEMPTS", 5),
+			RetryBackoff: getEnvDuration("INGESTION_RETRY_BACKOFF", 100*time.Millisecond),
+		})
+		go func() {
+			queued, err := asyncService.Recover(context.Background(), rocketsRepository)
+			if err != nil {
+				log.Printf("Failed to recover unprocessed messages: %v", err)
+				return
+			}
+			if queued > 0 {
+				log.Printf("Recovering unprocessed messages of %d channels", queued)
+			}
+		}()
+		messagesService = asyncService
+		log.Println("Processing messages asynchronously")
+	}
+	rocketsService := rockets.NewRocketsServiceImpl(rocketsRepository, messagesRepository)
+	rebuildService := rockets.NewRebuildService(messagesRepository, rocketsRepository)
+	rebuildService.PublishTo(feed)
+	if os.Getenv("ADMIN_TOKEN") == "" {
+		log.Println("ADMIN_TOKEN is not set, the admin endpoints are disabled")
+	}
+
+	config := api.Config{
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+		WebSocket: api.WebSocketConfig{
+			Buffer:       getEnvInt("WS_BUFFER", 256),
+			WriteTimeout: getEnvDuration("WS_WRITE_TIMEOUT", 10*time.Second),
+			PingInterval: getEnvDuration("WS_PING_INTERVAL", 30*time.Second),
+		},
+	}
+	return api.NewRocketsAPI(messagesService, rocketsService, rebuildService, webhookService, feed, config), messagesService, rebuildService
+}
+
+func runRebuild(args []string) {
+	flags := flag.NewFlagSet("rebuild", flag.ExitOnError)
+	channel := flags.String("channel", "", "rebuild only the rocket of this channel")
+	dryRun := flags.Bool("dry-run", false, "report the rockets that would change without storing them")
+	concurrency := flags.Int("concurrency", 4, "how many rockets are rebuilt at once")
+	flags.Parse(args)
+
+	store := setupStorage()
+	defer store.close(context.Background())
+	rebuildService := rockets.NewRebuildService(store.messagesRepository, store.rocketsRepository)
+
+	var result any
+	if *channel != "" {
+		id, err := uuid.Parse(*channel)
+		if err != nil {
+			log.Fatalf("Invalid channel: %v", err)
+		}
+		if result, err = rebuildService.Rebuild(context.Background(), id, *dryRun); err != nil {
+			log.Fatalf("Failed to rebuild %s: %v", id, err)
+		}
+	} else {
+		report, err := rebuildService.RebuildAll(context.Background(), rockets.RebuildOptions{
+			DryRun:      *dryRun,
+			Concurrency: *concurrency,
+			Progress: func(report rockets.RebuildReport) {
+				// About every 5% of the fleet
+				if report.Done == report.Total || report.Done%max(report.Total/20, 1) == 0 {
+					log.Printf("Rebuilt %d/%d rockets, %d changed, %d failed", report.Done, report.Total, report.Changed, report.Failed)
+				}
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to rebuild the fleet: %v", err)
+		}
+		result = report
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatalf("Failed to print the report: %v", err)
+	}
+}
+
+func getEnvInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return parsed
+}
+
+func getEnvDuration(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return parsed
 }
 
 func getMongo() *mongo.Client {
@@ -1,17 +1,29 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"expvar"
+	"io"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/adrianrios/lunar-test/internal/migrations"
 	"github.com/adrianrios/lunar-test/internal/rockets"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
@@ -21,16 +33,8 @@ import (
 )
 
 func TestRocketStateGeneration_InOrder(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	channelID := uuid.New()
 
@@ -60,8 +64,7 @@ func TestRocketStateGeneration_InOrder(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec1.Code)
 
 	// Check rocket state after first message
-	rockets, err := rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets := allRockets(t, rocketsRepository)
 	require.Len(t, rockets, 1)
 	assert.Equal(t, channelID, rockets[0].Channel)
 	assert.Equal(t, "Falcon-9", rockets[0].Type)
@@ -93,8 +96,7 @@ func TestRocketStateGeneration_InOrder(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec2.Code)
 
 	// Check rocket state after second message
-	rockets, err = rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets = allRockets(t, rocketsRepository)
 	require.Len(t, rockets, 1)
 	assert.Equal(t, 3500, rockets[0].Speed) // 500 + 3000
 	assert.Equal(t, 2, *rockets[0].LastMessageNumber)
@@ -121,8 +123,7 @@ func TestRocketStateGeneration_InOrder(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec3.Code)
 
 	// Check final rocket state
-	rockets, err = rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets = allRockets(t, rocketsRepository)
 	require.Len(t, rockets, 1)
 	assert.Equal(t, 3000, rockets[0].Speed) // 500 + 3000 - 500
 	assert.Equal(t, 3, *rockets[0].LastMessageNumber)
@@ -155,16 +156,8 @@ func TestRocketStateGeneration_InOrder(t *testing.T) {
 }
 
 func TestRocketStateGeneration_OutOfOrder(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	channelID := uuid.New()
 
@@ -190,8 +183,7 @@ func TestRocketStateGeneration_OutOfOrder(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec3.Code)
 
 	// No rocket should be created yet
-	rockets, err := rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets := allRockets(t, rocketsRepository)
 	assert.Len(t, rockets, 0)
 
 	// Post message 1
@@ -220,8 +212,7 @@ func TestRocketStateGeneration_OutOfOrder(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec1.Code)
 
 	// rocket has message number 1
-	rockets, err = rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets = allRockets(t, rocketsRepository)
 	assert.Len(t, rockets, 1)
 	assert.Equal(t, *rockets[0].LastMessageNumber, 1)
 
@@ -247,8 +238,7 @@ func TestRocketStateGeneration_OutOfOrder(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec2.Code)
 
 	// Now rocket should be created with all messages applied in order
-	rockets, err = rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets = allRockets(t, rocketsRepository)
 	require.Len(t, rockets, 1)
 	assert.Equal(t, channelID, rockets[0].Channel)
 	assert.Equal(t, 3000, rockets[0].Speed) // 500 + 3000 - 500 (applied in correct order despite arrival order)
@@ -256,16 +246,8 @@ func TestRocketStateGeneration_OutOfOrder(t *testing.T) {
 }
 
 func TestRocketExploded(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	channelID := uuid.New()
 
@@ -312,8 +294,7 @@ func TestRocketExploded(t *testing.T) {
 	handler.ServeHTTP(rec2, req2)
 
 	// Check rocket state
-	rockets, err := rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets := allRockets(t, rocketsRepository)
 	require.Len(t, rockets, 1)
 	assert.Equal(t, "exploded", rockets[0].Status)
 	assert.NotNil(t, rockets[0].ExplosionReason)
@@ -321,16 +302,8 @@ func TestRocketExploded(t *testing.T) {
 }
 
 func TestMissionChanged(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	channelID := uuid.New()
 
@@ -377,24 +350,15 @@ func TestMissionChanged(t *testing.T) {
 	handler.ServeHTTP(rec2, req2)
 
 	// Check rocket state
-	rockets, err := rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets := allRockets(t, rocketsRepository)
 	require.Len(t, rockets, 1)
 	assert.Equal(t, "SHUTTLE_MIR", rockets[0].Mission)
 	assert.Equal(t, 2, *rockets[0].LastMessageNumber)
 }
 
 func TestMultipleRockets(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	// Launch rocket 1
 	channelID1 := uuid.New()
@@ -443,8 +407,7 @@ func TestMultipleRockets(t *testing.T) {
 	handler.ServeHTTP(rec2, req2)
 
 	// Check both rockets exist
-	rockets, err := rocketsRepository.All(context.Background(), nil, nil)
-	require.NoError(t, err)
+	rockets := allRockets(t, rocketsRepository)
 	assert.Len(t, rockets, 2)
 
 	// Check we can get each rocket individually
@@ -470,16 +433,8 @@ func TestMultipleRockets(t *testing.T) {
 }
 
 func TestListRockets_SortBySpeed(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	// Create 3 rockets with different speeds
 	rockets := []struct {
@@ -548,16 +503,8 @@ func TestListRockets_SortBySpeed(t *testing.T) {
 }
 
 func TestListRockets_SortByType(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	// Create rockets with different types
 	rocketTypes := []string{"Starship", "Atlas-V", "Falcon-9"}
@@ -601,16 +548,8 @@ func TestListRockets_SortByType(t *testing.T) {
 }
 
 func TestListRockets_SortByStatus(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	// Create active rocket
 	msg1 := RocketMessage{
@@ -688,16 +627,8 @@ func TestListRockets_SortByStatus(t *testing.T) {
 }
 
 func TestListRockets_NoSort(t *testing.T) {
-	mongoClient, cleanup := setupMongoDB(t)
-	defer cleanup()
-
-	db := mongoClient.Database("rockets_test")
-	messagesCollection := db.Collection("messages")
-	rocketsCollection := db.Collection("rockets")
-
-	messagesRepository := rockets.NewMongoMessageRepository(messagesCollection)
-	rocketsRepository := rockets.NewMongoRocketsRepository(rocketsCollection)
-	handler := setupHanler(messagesRepository, rocketsRepository)
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
 	// Create a couple of rockets
 	for i := 0; i < 2; i++ {
@@ -737,54 +668,1901 @@ func TestListRockets_NoSort(t *testing.T) {
 	assert.Len(t, resp.Rockets, 2)
 }
 
-func setupMongoDB(t *testing.T) (*mongo.Client, func()) {
-	ctx := context.Background()
+func TestListRockets_SortBySeveralKeys(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
-	req := testcontainers.ContainerRequest{
-		Image:        "mongo:8.0",
-		ExposedPorts: []string{"27017/tcp"},
-		Env: map[string]string{
-			"MONGO_INITDB_ROOT_USERNAME": "test",
-			"MONGO_INITDB_ROOT_PASSWORD": "test",
-			"MONGO_INITDB_DATABASE":      "rockets_test",
-		},
-		WaitingFor: wait.ForLog("Waiting for connections").
-			WithStartupTimeout(60 * time.Second),
+	launched := time.Now().Add(-time.Hour)
+	for i, launch := range []RocketLaunchedPayload{
+		{Type: "Falcon-9", LaunchSpeed: 500, Mission: "ARTEMIS"},
+		{Type: "Saturn-V", LaunchSpeed: 500, Mission: "APOLLO"},
+		{Type: "Falcon-9", LaunchSpeed: 900, Mission: "GEMINI"},
+	} {
+		msg := RocketMessage{
+			Metadata: MessageMetadata{
+				Channel:       uuid.New(),
+				MessageNumber: 1,
+				MessageTime:   launched.Add(time.Duration(i) * time.Minute),
+				MessageType:   RocketLaunched,
+			},
+		}
+		var msgPayload RocketMessage_Message
+		_ = msgPayload.FromRocketLaunchedPayload(launch)
+		msg.Message = msgPayload
+		body, _ := json.Marshal(msg)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
 	}
 
-	mongoContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: req,
-		Started:          true,
+	list := func(sortBy string) (int, []string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rockets?sortBy="+sortBy, nil))
+		var page RocketPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		missions := make([]string, 0, len(page.Rockets))
+		for _, rocket := range page.Rockets {
+			missions = append(missions, rocket.Mission)
+		}
+		return rec.Code, missions
+	}
+
+	code, missions := list("speed,-lastMessageTime")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"APOLLO", "ARTEMIS", "GEMINI"}, missions)
+	_, missions = list("type,-speed")
+	assert.Equal(t, []string{"GEMINI", "ARTEMIS", "APOLLO"}, missions)
+	_, missions = list("lastMessageNumber,lastMessageTime&order=desc")
+	assert.Equal(t, []string{"GEMINI", "APOLLO", "ARTEMIS"}, missions)
+
+	for _, sortBy := range []string{"altitude", "speed,-speed", "speed,", "+speed"} {
+		code, _ := list(url.QueryEscape(sortBy))
+		assert.Equal(t, http.StatusBadRequest, code, sortBy)
+	}
+}
+
+func TestListRockets_Paged(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	for _, speed := range []int{300, 100, 200, 100, 400} {
+		msg := RocketMessage{
+			Metadata: MessageMetadata{
+				Channel:       uuid.New(),
+				MessageNumber: 1,
+				MessageTime:   time.Now(),
+				MessageType:   RocketLaunched,
+			},
+		}
+		var msgPayload RocketMessage_Message
+		_ = msgPayload.FromRocketLaunchedPayload(RocketLaunchedPayload{Type: "Falcon-9", LaunchSpeed: speed, Mission: "TEST"})
+		msg.Message = msgPayload
+		body, _ := json.Marshal(msg)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	list := func(query string) (int, RocketPage) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rockets?"+query, nil))
+		var page RocketPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		return rec.Code, page
+	}
+
+	// Following the cursors visits every rocket once, in order
+	var speeds []int
+	query := "sortBy=speed&limit=2"
+	for pages := 0; pages < 5; pages++ {
+		code, page := list(query)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 5, page.Total)
+		for _, rocket := range page.Rockets {
+			speeds = append(speeds, rocket.Speed)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		query = "sortBy=speed&limit=2&cursor=" + url.QueryEscape(*page.NextCursor)
+	}
+	assert.Equal(t, []int{100, 100, 200, 300, 400}, speeds)
+
+	// Without a limit the whole fleet comes in one page
+	code, page := list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, page.Rockets, 5)
+	assert.Nil(t, page.NextCursor)
+
+	_, first := list("sortBy=speed&limit=2")
+	code, _ = list("sortBy=speed&order=desc&cursor=" + url.QueryEscape(*first.NextCursor))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = list("cursor=garbage")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = list("limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestListRockets_Filtered(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	for _, launch := range []RocketLaunchedPayload{
+		{Type: "Falcon-9", LaunchSpeed: 500, Mission: "ARTEMIS"},
+		{Type: "Falcon-9", LaunchSpeed: 1500, Mission: "ARTEMIS-2"},
+		{Type: "Saturn-V", LaunchSpeed: 2500, Mission: "APOLLO"},
+	} {
+		msg := RocketMessage{
+			Metadata: MessageMetadata{
+				Channel:       uuid.New(),
+				MessageNumber: 1,
+				MessageTime:   time.Now(),
+				MessageType:   RocketLaunched,
+			},
+		}
+		var msgPayload RocketMessage_Message
+		_ = msgPayload.FromRocketLaunchedPayload(launch)
+		msg.Message = msgPayload
+		body, _ := json.Marshal(msg)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	list := func(query string) (int, []string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rockets?sortBy=speed&"+query, nil))
+		var page RocketPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		missions := make([]string, 0, len(page.Rockets))
+		for _, rocket := range page.Rockets {
+			missions = append(missions, rocket.Mission)
+		}
+		return rec.Code, missions
+	}
+
+	code, missions := list("type=Falcon-9&minSpeed=1000")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"ARTEMIS-2"}, missions)
+	_, missions = list("missionPrefix=ART&maxSpeed=1500")
+	assert.Equal(t, []string{"ARTEMIS", "ARTEMIS-2"}, missions)
+	_, missions = list("search=saturn&status=active")
+	assert.Equal(t, []string{"APOLLO"}, missions)
+	_, missions = list("lastMessageFrom=" + url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339)))
+	assert.Empty(t, missions)
+
+	for _, query := range []string{"misson=ARTEMIS", "status=landed", "minSpeed=10&maxSpeed=5", "minSpeed=fast"} {
+		code, _ := list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestStats(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	post := func(channel uuid.UUID, number int, sent time.Time, messageType MessageMetadataMessageType, payload func(*RocketMessage_Message)) {
+		msg := RocketMessage{
+			Metadata: MessageMetadata{Channel: channel, MessageNumber: number, MessageTime: sent, MessageType: messageType},
+		}
+		payload(&msg.Message)
+		body, _ := json.Marshal(msg)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	launch := func(channel uuid.UUID, sent time.Time, rocketType string, speed int) {
+		post(channel, 1, sent, RocketLaunched, func(message *RocketMessage_Message) {
+			_ = message.FromRocketLaunchedPayload(RocketLaunchedPayload{Type: rocketType, LaunchSpeed: speed, Mission: "ARTEMIS"})
+		})
+	}
+
+	recent, old, exploded := uuid.New(), uuid.New(), uuid.New()
+	launch(recent, time.Now(), "Falcon-9", 500)
+	launch(old, time.Now().Add(-2*time.Hour), "Falcon-9", 1500)
+	launch(exploded, time.Now().Add(-time.Hour), "Saturn-V", 3000)
+	post(exploded, 2, time.Now().Add(-time.Hour), RocketExploded, func(message *RocketMessage_Message) {
+		_ = message.FromRocketExplodedPayload(RocketExplodedPayload{Reason: "PRESSURE_VESSEL_FAILURE"})
 	})
-	require.NoError(t, err)
 
-	host, err := mongoContainer.Host(ctx)
-	require.NoError(t, err)
+	get := func(query string) (int, FleetStats) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats?"+query, nil))
+		var stats FleetStats
+		_ = json.Unmarshal(rec.Body.Bytes(), &stats)
+		return rec.Code, stats
+	}
 
-	port, err := mongoContainer.MappedPort(ctx, "27017")
-	require.NoError(t, err)
+	code, stats := get("")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, stats.Total)
+	assert.Equal(t, 1, stats.Active)
+	assert.Equal(t, 15, stats.ActiveWithin)
+	assert.Equal(t, []StatsCount{{Key: "active", Count: 2}, {Key: "exploded", Count: 1}}, stats.ByStatus)
+	assert.Equal(t, []StatsCount{{Key: "ARTEMIS", Count: 3}}, stats.ByMission)
+	assert.Equal(t, []StatsCount{{Key: "PRESSURE_VESSEL_FAILURE", Count: 1}}, stats.Explosions)
+	assert.Equal(t, []StatsGroup{
+		{Key: "Falcon-9", Count: 2, Active: 1, AverageSpeed: 1000, MinSpeed: 500, MaxSpeed: 1500},
+		{Key: "Saturn-V", Count: 1, Active: 0, AverageSpeed: 3000, MinSpeed: 3000, MaxSpeed: 3000},
+	}, stats.SpeedByType)
+	assert.Nil(t, stats.Groups)
+
+	code, stats = get("groupBy=status&activeWithin=90&from=" + url.QueryEscape(time.Now().Add(-90*time.Minute).Format(time.RFC3339)))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, stats.Total)
+	assert.Equal(t, 2, stats.Active)
+	require.NotNil(t, stats.Groups)
+	assert.Equal(t, []StatsGroup{
+		{Key: "active", Count: 1, Active: 1, AverageSpeed: 500, MinSpeed: 500, MaxSpeed: 500},
+		{Key: "exploded", Count: 1, Active: 1, AverageSpeed: 3000, MinSpeed: 3000, MaxSpeed: 3000},
+	}, *stats.Groups)
+
+	for _, query := range []string{"groupBy=speed", "activeWithin=0", "window=15"} {
+		code, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
 
-	connStr := "mongodb://test:test@" + host + ":" + port.Port()
+func TestRocketTimeseries(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
 
-	clientOptions := options.Client().ApplyURI(connStr)
-	mongoClient, err := mongo.Connect(ctx, clientOptions)
-	require.NoError(t, err)
+	channel := uuid.New()
+	base := time.Now().UTC().Truncate(time.Minute).Add(-10 * time.Minute)
+	post := func(number int, sent time.Duration, messageType MessageMetadataMessageType, payload func(*RocketMessage_Message)) {
+		msg := RocketMessage{
+			Metadata: MessageMetadata{Channel: channel, MessageNumber: number, MessageTime: base.Add(sent), MessageType: messageType},
+		}
+		payload(&msg.Message)
+		body, _ := json.Marshal(msg)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	post(1, 10*time.Second, RocketLaunched, func(message *RocketMessage_Message) {
+		_ = message.FromRocketLaunchedPayload(RocketLaunchedPayload{Type: "Falcon-9", LaunchSpeed: 500, Mission: "ARTEMIS"})
+	})
+	post(2, 40*time.Second, RocketSpeedIncreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 300})
+	})
+	post(3, 70*time.Second, RocketSpeedDecreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedDecreasedPayload(RocketSpeedDecreasedPayload{By: 200})
+	})
+	post(4, 80*time.Second, RocketMissionChanged, func(message *RocketMessage_Message) {
+		_ = message.FromRocketMissionChangedPayload(RocketMissionChangedPayload{NewMission: "APOLLO"})
+	})
+	post(5, 190*time.Second, RocketSpeedIncreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 1000})
+	})
+	// Sent before the message it follows, so it counts at the time of that one
+	post(6, 150*time.Second, RocketSpeedDecreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedDecreasedPayload(RocketSpeedDecreasedPayload{By: 100})
+	})
 
-	err = mongoClient.Ping(ctx, nil)
-	require.NoError(t, err)
+	get := func(query string) (int, RocketTimeseries) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rockets/"+channel.String()+"/timeseries?"+query, nil))
+		var timeseries RocketTimeseries
+		_ = json.Unmarshal(rec.Body.Bytes(), &timeseries)
+		return rec.Code, timeseries
+	}
 
-	cleanup := func() {
-		_ = mongoClient.Disconnect(ctx)
-		_ = mongoContainer.Terminate(ctx)
+	code, timeseries := get("field=speed")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "speed", timeseries.Field)
+	assert.Nil(t, timeseries.Buckets)
+	require.NotNil(t, timeseries.Points)
+	assert.Equal(t, []TimeseriesPoint{
+		{MessageNumber: 1, Time: base.Add(10 * time.Second), Value: 500, Mission: "ARTEMIS"},
+		{MessageNumber: 2, Time: base.Add(40 * time.Second), Value: 800, Mission: "ARTEMIS"},
+		{MessageNumber: 3, Time: base.Add(70 * time.Second), Value: 600, Mission: "ARTEMIS"},
+		{MessageNumber: 4, Time: base.Add(80 * time.Second), Value: 600, Mission: "APOLLO"},
+		{MessageNumber: 5, Time: base.Add(190 * time.Second), Value: 1600, Mission: "APOLLO"},
+		{MessageNumber: 6, Time: base.Add(190 * time.Second), Value: 1500, Mission: "APOLLO"},
+	}, *timeseries.Points)
+
+	code, timeseries = get("bucket=1m")
+	require.Equal(t, http.StatusOK, code)
+	require.NotNil(t, timeseries.Bucket)
+	assert.Equal(t, "1m0s", *timeseries.Bucket)
+	assert.Nil(t, timeseries.Points)
+	require.NotNil(t, timeseries.Buckets)
+	assert.Equal(t, []TimeseriesBucket{
+		{Start: base, Messages: 2, Min: 500, Max: 800, Last: 800, Mission: "ARTEMIS"},
+		{Start: base.Add(time.Minute), Messages: 2, Min: 600, Max: 800, Last: 600, Mission: "APOLLO"},
+		{Start: base.Add(2 * time.Minute), Messages: 0, Min: 600, Max: 600, Last: 600, Mission: "APOLLO"},
+		{Start: base.Add(3 * time.Minute), Messages: 2, Min: 600, Max: 1600, Last: 1500, Mission: "APOLLO"},
+	}, *timeseries.Buckets)
+
+	window := "&from=" + url.QueryEscape(base.Add(time.Minute).Format(time.RFC3339)) +
+		"&to=" + url.QueryEscape(base.Add(2*time.Minute).Format(time.RFC3339))
+	code, timeseries = get("bucket=1m" + window)
+	require.Equal(t, http.StatusOK, code)
+	require.NotNil(t, timeseries.Buckets)
+	assert.Equal(t, []TimeseriesBucket{
+		{Start: base.Add(time.Minute), Messages: 2, Min: 600, Max: 800, Last: 600, Mission: "APOLLO"},
+		{Start: base.Add(2 * time.Minute), Messages: 0, Min: 600, Max: 600, Last: 600, Mission: "APOLLO"},
+	}, *timeseries.Buckets)
+
+	code, timeseries = get(window[1:])
+	require.Equal(t, http.StatusOK, code)
+	require.NotNil(t, timeseries.Points)
+	assert.Len(t, *timeseries.Points, 2)
+
+	for _, query := range []string{"field=mission", "bucket=500ms", "bucket=soon", "bucket=1s&to=2100-01-01T00:00:00Z", "step=1m"} {
+		code, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
 	}
 
-	return mongoClient, cleanup
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rockets/"+uuid.NewString()+"/timeseries", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 }
 
-func setupHanler(messagesRepository *rockets.MongoMessageRepository, rocketsRepository *rockets.MongoRocketsRepository) http.Handler {
-	messagesService := rockets.NewResequencerMessageService(messagesRepository, rocketsRepository)
-	rocketsService := rockets.NewRocketsServiceImpl(rocketsRepository)
-	api := NewRocketsAPI(messagesService, rocketsService)
-	handler := HandlerFromMux(api, chi.NewRouter())
-	return handler
+func TestRocketMetrics(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	launched := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	post := func(channel uuid.UUID, number int, sent time.Duration, messageType MessageMetadataMessageType, payload func(*RocketMessage_Message)) {
+		msg := RocketMessage{
+			Metadata: MessageMetadata{Channel: channel, MessageNumber: number, MessageTime: launched.Add(sent), MessageType: messageType},
+		}
+		payload(&msg.Message)
+		body, _ := json.Marshal(msg)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	launch := func(channel uuid.UUID, speed int) {
+		post(channel, 1, 0, RocketLaunched, func(message *RocketMessage_Message) {
+			_ = message.FromRocketLaunchedPayload(RocketLaunchedPayload{Type: "Falcon-9", LaunchSpeed: speed, Mission: "ARTEMIS"})
+		})
+	}
+
+	artemis, apollo := uuid.New(), uuid.New()
+	launch(artemis, 100)
+	post(artemis, 2, 10*time.Second, RocketSpeedIncreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 400})
+	})
+	post(artemis, 3, 30*time.Second, RocketSpeedDecreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedDecreasedPayload(RocketSpeedDecreasedPayload{By: 300})
+	})
+	post(artemis, 4, 40*time.Second, RocketMissionChanged, func(message *RocketMessage_Message) {
+		_ = message.FromRocketMissionChangedPayload(RocketMissionChangedPayload{NewMission: "APOLLO"})
+	})
+	// Sent before the message it follows, it adds no time nor distance
+	post(artemis, 5, 35*time.Second, RocketSpeedIncreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 50})
+	})
+	launch(apollo, 1000)
+	post(apollo, 2, 5*time.Second, RocketSpeedIncreased, func(message *RocketMessage_Message) {
+		_ = message.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 10})
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rockets/"+artemis.String(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var rocket Rocket
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rocket))
+	assert.Equal(t, 250, rocket.Speed)
+	assert.Equal(t, 500, rocket.PeakSpeed)
+	assert.Equal(t, 450, rocket.TotalAcceleration)
+	assert.Equal(t, 1, rocket.MissionChanges)
+	require.NotNil(t, rocket.LaunchTime)
+	assert.True(t, launched.Equal(*rocket.LaunchTime))
+	assert.Equal(t, 40.0, rocket.FlightDuration)
+	assert.Equal(t, 100*10+500*20+200*10.0, rocket.Distance)
+
+	list := func(query string) (int, []uuid.UUID) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rockets?"+query, nil))
+		var page RocketPage
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		channels := make([]uuid.UUID, 0, len(page.Rockets))
+		for _, rocket := range page.Rockets {
+			channels = append(channels, rocket.Channel)
+		}
+		return rec.Code, channels
+	}
+
+	code, channels := list("sortBy=-distance")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []uuid.UUID{artemis, apollo}, channels)
+	code, channels = list("sortBy=peakSpeed,-flightDuration")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []uuid.UUID{artemis, apollo}, channels)
+	code, channels = list("minMissionChanges=1&maxDistance=20000")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []uuid.UUID{artemis}, channels)
+	code, channels = list("minPeakSpeed=600&maxFlightDuration=10&launchedFrom=" + url.QueryEscape(launched.Format(time.RFC3339)))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []uuid.UUID{apollo}, channels)
+
+	for _, query := range []string{"minDistance=10&maxDistance=1", "minPeakSpeed=fast", "sortBy=maxSpeed"} {
+		code, _ := list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestDuplicateMessages(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	channelID := uuid.New()
+	messageTime := time.Now()
+
+	// Launch rocket
+	msg1 := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID,
+			MessageNumber: 1,
+			MessageTime:   messageTime,
+			MessageType:   RocketLaunched,
+		},
+	}
+	var msgPayload1 RocketMessage_Message
+	_ = msgPayload1.FromRocketLaunchedPayload(RocketLaunchedPayload{
+		Type:        "Falcon-9",
+		LaunchSpeed: 500,
+		Mission:     "ARTEMIS",
+	})
+	msg1.Message = msgPayload1
+	body1, _ := json.Marshal(msg1)
+	req1 := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body1))
+	rec1 := httptest.NewRecorder()
+	handler.ServeHTTP(rec1, req1)
+	assert.Equal(t, http.StatusOK, rec1.Code)
+
+	// Deliver the speed increase twice
+	msg2 := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID,
+			MessageNumber: 2,
+			MessageTime:   messageTime,
+			MessageType:   RocketSpeedIncreased,
+		},
+	}
+	var msgPayload2 RocketMessage_Message
+	_ = msgPayload2.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 3000})
+	msg2.Message = msgPayload2
+	body2, _ := json.Marshal(msg2)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body2))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Same number with a different payload is a conflict
+	var conflictingPayload RocketMessage_Message
+	_ = conflictingPayload.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 1000})
+	msg2.Message = conflictingPayload
+	body3, _ := json.Marshal(msg2)
+	req3 := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body3))
+	rec3 := httptest.NewRecorder()
+	handler.ServeHTTP(rec3, req3)
+	assert.Equal(t, http.StatusConflict, rec3.Code)
+
+	var errResp Error
+	require.NoError(t, json.Unmarshal(rec3.Body.Bytes(), &errResp))
+	assert.NotEmpty(t, errResp.Error)
+
+	// The speed increase is applied once and the log keeps a single copy
+	rockets := allRockets(t, rocketsRepository)
+	require.Len(t, rockets, 1)
+	assert.Equal(t, 3500, rockets[0].Speed)
+	assert.Equal(t, 2, *rockets[0].LastMessageNumber)
+
+	messages, err := messagesRepository.FindByChannel(context.Background(), channelID)
+	require.NoError(t, err)
+	assert.Len(t, messages, 2)
+}
+
+func TestAsyncIngestion(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+
+	feed := rockets.NewRocketFeed(100)
+	resequencer := newMessageService(messagesRepository, rocketsRepository, rockets.GapPolicy{}, feed, nil)
+	messagesService := rockets.NewAsyncMessageService(messagesRepository, resequencer, rockets.AsyncConfig{Workers: 2, QueueSize: 10})
+	handler := HandlerFromMux(newRocketsAPI(messagesService, messagesRepository, rocketsRepository, feed, nil), chi.NewRouter())
+
+	channelID := uuid.New()
+
+	// Post the speed change before the launch
+	msg2 := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID,
+			MessageNumber: 2,
+			MessageTime:   time.Now(),
+			MessageType:   RocketSpeedIncreased,
+		},
+	}
+	var msgPayload2 RocketMessage_Message
+	_ = msgPayload2.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 3000})
+	msg2.Message = msgPayload2
+	body2, _ := json.Marshal(msg2)
+	req2 := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body2))
+	rec2 := httptest.NewRecorder()
+	handler.ServeHTTP(rec2, req2)
+	assert.Equal(t, http.StatusAccepted, rec2.Code)
+
+	msg1 := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID,
+			MessageNumber: 1,
+			MessageTime:   time.Now(),
+			MessageType:   RocketLaunched,
+		},
+	}
+	var msgPayload1 RocketMessage_Message
+	_ = msgPayload1.FromRocketLaunchedPayload(RocketLaunchedPayload{
+		Type:        "Falcon-9",
+		LaunchSpeed: 500,
+		Mission:     "ARTEMIS",
+	})
+	msg1.Message = msgPayload1
+	body1, _ := json.Marshal(msg1)
+	req1 := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body1))
+	rec1 := httptest.NewRecorder()
+	handler.ServeHTTP(rec1, req1)
+	assert.Equal(t, http.StatusAccepted, rec1.Code)
+
+	// Shutdown drains the queues
+	require.NoError(t, messagesService.Shutdown(context.Background()))
+
+	rockets := allRockets(t, rocketsRepository)
+	require.Len(t, rockets, 1)
+	assert.Equal(t, 3500, rockets[0].Speed)
+	assert.Equal(t, 2, *rockets[0].LastMessageNumber)
+
+	// Messages arriving after shutdown are refused
+	req3 := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body1))
+	rec3 := httptest.NewRecorder()
+	handler.ServeHTTP(rec3, req3)
+	assert.Equal(t, http.StatusServiceUnavailable, rec3.Code)
+}
+
+func TestBatchIngestion(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	channelID1 := uuid.New()
+	channelID2 := uuid.New()
+
+	launch := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID1,
+			MessageNumber: 1,
+			MessageTime:   time.Now(),
+			MessageType:   RocketLaunched,
+		},
+	}
+	var launchPayload RocketMessage_Message
+	_ = launchPayload.FromRocketLaunchedPayload(RocketLaunchedPayload{
+		Type:        "Falcon-9",
+		LaunchSpeed: 500,
+		Mission:     "ARTEMIS",
+	})
+	launch.Message = launchPayload
+
+	increase := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID1,
+			MessageNumber: 2,
+			MessageTime:   time.Now(),
+			MessageType:   RocketSpeedIncreased,
+		},
+	}
+	var increasePayload RocketMessage_Message
+	_ = increasePayload.FromRocketSpeedIncreasedPayload(RocketSpeedIncreasedPayload{By: 3000})
+	increase.Message = increasePayload
+
+	otherLaunch := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID2,
+			MessageNumber: 1,
+			MessageTime:   time.Now(),
+			MessageType:   RocketLaunched,
+		},
+	}
+	var otherLaunchPayload RocketMessage_Message
+	_ = otherLaunchPayload.FromRocketLaunchedPayload(RocketLaunchedPayload{
+		Type:        "Starship",
+		LaunchSpeed: 1000,
+		Mission:     "MARS",
+	})
+	otherLaunch.Message = otherLaunchPayload
+
+	// JSON array, out of order, with a duplicate and a broken message
+	body, _ := json.Marshal([]any{increase, otherLaunch, "not a message", launch, launch})
+	req := httptest.NewRequest(http.MethodPost, "/messages/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var batchResp BatchResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batchResp))
+	require.Len(t, batchResp.Results, 5)
+	assert.Equal(t, BatchItemResultStatusAccepted, batchResp.Results[0].Status)
+	assert.Equal(t, BatchItemResultStatusAccepted, batchResp.Results[1].Status)
+	assert.Equal(t, BatchItemResultStatusInvalid, batchResp.Results[2].Status)
+	assert.NotNil(t, batchResp.Results[2].Reason)
+	assert.Equal(t, BatchItemResultStatusAccepted, batchResp.Results[3].Status)
+	assert.Equal(t, BatchItemResultStatusDuplicate, batchResp.Results[4].Status)
+	assert.Equal(t, 4, batchResp.Results[4].Index)
+
+	rocket1, err := rocketsRepository.FindByChannel(context.Background(), channelID1)
+	require.NoError(t, err)
+	assert.Equal(t, 3500, rocket1.Speed)
+	assert.Equal(t, 2, *rocket1.LastMessageNumber)
+
+	rocket2, err := rocketsRepository.FindByChannel(context.Background(), channelID2)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, rocket2.Speed)
+
+	// NDJSON
+	decrease := RocketMessage{
+		Metadata: MessageMetadata{
+			Channel:       channelID1,
+			MessageNumber: 3,
+			MessageTime:   time.Now(),
+			MessageType:   RocketSpeedDecreased,
+		},
+	}
+	var decreasePayload RocketMessage_Message
+	_ = decreasePayload.FromRocketSpeedDecreasedPayload(RocketSpeedDecreasedPayload{By: 500})
+	decrease.Message = decreasePayload
+
+	var ndjson bytes.Buffer
+	_ = json.NewEncoder(&ndjson).Encode(decrease)
+	ndjson.WriteString("{broken\n")
+	req = httptest.NewRequest(http.MethodPost, "/messages/batch", &ndjson)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batchResp))
+	require.Len(t, batchResp.Results, 2)
+	assert.Equal(t, BatchItemResultStatusAccepted, batchResp.Results[0].Status)
+	assert.Equal(t, BatchItemResultStatusInvalid, batchResp.Results[1].Status)
+
+	rocket1, err = rocketsRepository.FindByChannel(context.Background(), channelID1)
+	require.NoError(t, err)
+	assert.Equal(t, 3000, rocket1.Speed)
+
+	// Not a batch at all
+	req = httptest.NewRequest(http.MethodPost, "/messages/batch", bytes.NewReader([]byte(`{"metadata":{}}`)))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Too many messages: refused before the rest of the body is read, so the broken tail is never reached
+	oversized := "[" + strings.Repeat(`{},`, maxBatchSize+1) + "{broken"
+	req = httptest.NewRequest(http.MethodPost, "/messages/batch", strings.NewReader(oversized))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestInvalidMessages(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	channelID := uuid.New()
+	metadata := func(number int, messageType string) map[string]any {
+		return map[string]any{
+			"channel":       channelID,
+			"messageNumber": number,
+			"messageTime":   time.Now(),
+			"messageType":   messageType,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		message map[string]any
+		field   string
+	}{
+		{
+			name:    "missing rocket type",
+			message: map[string]any{"metadata": metadata(1, "RocketLaunched"), "message": map[string]any{"launchSpeed": 500, "mission": "ARTEMIS"}},
+			field:   "message.type",
+		},
+		{
+			name:    "launch speed is not a number",
+			message: map[string]any{"metadata": metadata(1, "RocketLaunched"), "message": map[string]any{"type": "Falcon-9", "launchSpeed": "fast", "mission": "ARTEMIS"}},
+			field:   "message.launchSpeed",
+		},
+		{
+			name:    "missing mission",
+			message: map[string]any{"metadata": metadata(1, "RocketLaunched"), "message": map[string]any{"type": "Falcon-9", "launchSpeed": 500}},
+			field:   "message.mission",
+		},
+		{
+			name:    "negative speed increase",
+			message: map[string]any{"metadata": metadata(2, "RocketSpeedIncreased"), "message": map[string]any{"by": -100}},
+			field:   "message.by",
+		},
+		{
+			name:    "fractional speed decrease",
+			message: map[string]any{"metadata": metadata(2, "RocketSpeedDecreased"), "message": map[string]any{"by": 10.5}},
+			field:   "message.by",
+		},
+		{
+			name:    "missing explosion reason",
+			message: map[string]any{"metadata": metadata(2, "RocketExploded"), "message": map[string]any{}},
+			field:   "message.reason",
+		},
+		{
+			name:    "missing new mission",
+			message: map[string]any{"metadata": metadata(2, "RocketMissionChanged"), "message": map[string]any{"mission": "MARS"}},
+			field:   "message.newMission",
+		},
+		{
+			name:    "unknown message type",
+			message: map[string]any{"metadata": metadata(2, "RocketRefueled"), "message": map[string]any{}},
+			field:   "metadata.messageType",
+		},
+		{
+			name:    "zero message number",
+			message: map[string]any{"metadata": metadata(0, "RocketSpeedIncreased"), "message": map[string]any{"by": 100}},
+			field:   "metadata.messageNumber",
+		},
+		{
+			name: "nil channel",
+			message: map[string]any{"metadata": map[string]any{
+				"channel":       uuid.Nil,
+				"messageNumber": 2,
+				"messageTime":   time.Now(),
+				"messageType":   "RocketSpeedIncreased",
+			}, "message": map[string]any{"by": 100}},
+			field: "metadata.channel",
+		},
+		{
+			name: "missing message time",
+			message: map[string]any{"metadata": map[string]any{
+				"channel":       channelID,
+				"messageNumber": 2,
+				"messageType":   "RocketSpeedIncreased",
+			}, "message": map[string]any{"by": 100}},
+			field: "metadata.messageTime",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.message)
+			req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var errResp Error
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+			require.NotNil(t, errResp.Field)
+			assert.Equal(t, tt.field, *errResp.Field)
+		})
+	}
+
+	// Nothing reaches the log
+	messages, err := messagesRepository.FindByChannel(context.Background(), channelID)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestRocketEvents(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	channelID := uuid.New()
+	start := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	messages := []map[string]any{
+		{"number": 1, "type": "RocketLaunched", "message": map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"}},
+		{"number": 2, "type": "RocketSpeedIncreased", "message": map[string]any{"by": 300}},
+		{"number": 4, "type": "RocketSpeedIncreased", "message": map[string]any{"by": 100}},
+	}
+	// Message 2 is redelivered twice and message 4 once
+	for _, i := range []int{0, 1, 2, 1, 2, 1} {
+		m := messages[i]
+		body, _ := json.Marshal(map[string]any{
+			"metadata": map[string]any{
+				"channel":       channelID,
+				"messageNumber": m["number"],
+				"messageTime":   start.Add(time.Duration(i) * time.Second),
+				"messageType":   m["type"],
+			},
+			"message": m["message"],
+		})
+		req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	getEvents := func(query string) (int, RocketEventsPage) {
+		req := httptest.NewRequest(http.MethodGet, "/rockets/"+channelID.String()+"/events"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var page RocketEventsPage
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		}
+		return rec.Code, page
+	}
+
+	// Message 3 is missing, so message 4 is stuck. Redeliveries follow the message they repeat.
+	code, page := getEvents("")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, page.Total)
+	require.Len(t, page.Events, 5)
+	assert.Equal(t, 1, page.Events[0].MessageNumber)
+	assert.Equal(t, RocketEventStatusApplied, page.Events[0].Status)
+	assert.Equal(t, "ARTEMIS", page.Events[0].Message["mission"])
+	assert.Nil(t, page.Events[0].Redeliveries)
+	assert.Equal(t, 2, page.Events[1].MessageNumber)
+	assert.Equal(t, RocketEventStatusApplied, page.Events[1].Status)
+	assert.Equal(t, 2, page.Events[2].MessageNumber)
+	assert.Equal(t, RocketEventStatusDuplicate, page.Events[2].Status)
+	require.NotNil(t, page.Events[2].Redeliveries)
+	assert.Equal(t, 2, *page.Events[2].Redeliveries)
+	assert.NotNil(t, page.Events[2].LastRedeliveredAt)
+	assert.Equal(t, 4, page.Events[3].MessageNumber)
+	assert.Equal(t, RocketEventStatusPending, page.Events[3].Status)
+	assert.Equal(t, 4, page.Events[4].MessageNumber)
+	assert.Equal(t, RocketEventStatusDuplicate, page.Events[4].Status)
+	require.NotNil(t, page.Events[4].Redeliveries)
+	assert.Equal(t, 1, *page.Events[4].Redeliveries)
+	assert.Nil(t, page.NextOffset)
+
+	// Filter by type
+	code, page = getEvents("?messageType=RocketSpeedIncreased")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 4, page.Total)
+	for _, event := range page.Events {
+		assert.Equal(t, "RocketSpeedIncreased", event.MessageType)
+	}
+
+	// Filter by time range, bounds included
+	from := start.Add(time.Second).Format(time.RFC3339Nano)
+	code, page = getEvents("?from=" + from + "&to=" + from)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Events, 2)
+	assert.Equal(t, 2, page.Events[0].MessageNumber)
+	assert.Equal(t, RocketEventStatusDuplicate, page.Events[1].Status)
+
+	// Paginate
+	code, page = getEvents("?limit=2")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, page.Total)
+	require.Len(t, page.Events, 2)
+	require.NotNil(t, page.NextOffset)
+	assert.Equal(t, 2, *page.NextOffset)
+
+	code, page = getEvents("?limit=2&offset=4")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, 4, page.Events[0].MessageNumber)
+	assert.Nil(t, page.NextOffset)
+
+	code, _ = getEvents("?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Unknown rocket
+	req := httptest.NewRequest(http.MethodGet, "/rockets/"+uuid.New().String()+"/events", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRocketStateAsOf(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	start := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	postAt := func(channel uuid.UUID, number int, at time.Time, messageType string, payload map[string]any) {
+		body, _ := json.Marshal(map[string]any{
+			"metadata": map[string]any{
+				"channel":       channel,
+				"messageNumber": number,
+				"messageTime":   at,
+				"messageType":   messageType,
+			},
+			"message": payload,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	falcon := uuid.New()
+	postAt(falcon, 1, start, "RocketLaunched", map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"})
+	postAt(falcon, 2, start.Add(10*time.Minute), "RocketSpeedIncreased", map[string]any{"by": 300})
+	postAt(falcon, 3, start.Add(20*time.Minute), "RocketMissionChanged", map[string]any{"newMission": "APOLLO"})
+	postAt(falcon, 5, start.Add(25*time.Minute), "RocketSpeedIncreased", map[string]any{"by": 1000})
+	postAt(falcon, 4, start.Add(40*time.Minute), "RocketSpeedDecreased", map[string]any{"by": 100})
+
+	saturn := uuid.New()
+	postAt(saturn, 1, start.Add(30*time.Minute), "RocketLaunched", map[string]any{"type": "Saturn-V", "launchSpeed": 2000, "mission": "GEMINI"})
+
+	getRocket := func(channel uuid.UUID, asOf string) (int, Rocket) {
+		req := httptest.NewRequest(http.MethodGet, "/rockets/"+channel.String()+"?asOf="+asOf, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var rocket Rocket
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rocket))
+		}
+		return rec.Code, rocket
+	}
+
+	// By timestamp
+	code, rocket := getRocket(falcon, start.Add(15*time.Minute).Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 800, rocket.Speed)
+	assert.Equal(t, "ARTEMIS", rocket.Mission)
+	require.NotNil(t, rocket.LastMessageNumber)
+	assert.Equal(t, 2, *rocket.LastMessageNumber)
+
+	// Message 5 happened before 14:30, but message 4 did not, so the replay stops at 3
+	code, rocket = getRocket(falcon, start.Add(30*time.Minute).Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 800, rocket.Speed)
+	assert.Equal(t, "APOLLO", rocket.Mission)
+	assert.Equal(t, 3, *rocket.LastMessageNumber)
+
+	// By message number
+	code, rocket = getRocket(falcon, "1")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 500, rocket.Speed)
+	assert.Equal(t, 1, *rocket.LastMessageNumber)
+
+	code, rocket = getRocket(falcon, "5")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1700, rocket.Speed)
+
+	// Before the launch
+	code, _ = getRocket(saturn, start.Add(15*time.Minute).Format(time.RFC3339))
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = getRocket(falcon, "yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Fleet-wide snapshot
+	listAsOf := func(query string) []Rocket {
+		req := httptest.NewRequest(http.MethodGet, "/rockets?"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp struct {
+			Rockets []Rocket `json:"rockets"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Rockets
+	}
+
+	fleet := listAsOf("asOf=" + start.Add(15*time.Minute).Format(time.RFC3339))
+	require.Len(t, fleet, 1)
+	assert.Equal(t, falcon, fleet[0].Channel)
+
+	fleet = listAsOf("asOf=" + start.Add(35*time.Minute).Format(time.RFC3339) + "&sortBy=speed&order=desc")
+	require.Len(t, fleet, 2)
+	assert.Equal(t, saturn, fleet[0].Channel)
+	assert.Equal(t, 2000, fleet[0].Speed)
+	assert.Equal(t, 800, fleet[1].Speed)
+
+	// Messages 1 to 3 are folded into a snapshot, the points before it are gone
+	retention := rockets.NewRetentionService(messagesRepository, rocketsRepository, rockets.NewMemoryArchiveRepository(), rockets.RetentionPolicy{KeepMessages: 2})
+	_, err := retention.Run(context.Background(), false)
+	require.NoError(t, err)
+
+	for _, asOf := range []string{"2", start.Add(15 * time.Minute).Format(time.RFC3339)} {
+		req := httptest.NewRequest(http.MethodGet, "/rockets/"+falcon.String()+"?asOf="+asOf, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusGone, rec.Code)
+		var apiErr Error
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+		assert.Contains(t, apiErr.Error, "earliest point left is message 3 at "+start.Add(20*time.Minute).Format(time.RFC3339Nano))
+	}
+
+	code, rocket = getRocket(falcon, "3")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "APOLLO", rocket.Mission)
+	code, rocket = getRocket(falcon, start.Add(30*time.Minute).Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, *rocket.LastMessageNumber)
+
+	// The fleet before the snapshot is gone too, rather than missing the rocket
+	req := httptest.NewRequest(http.MethodGet, "/rockets?asOf="+start.Add(15*time.Minute).Format(time.RFC3339), nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusGone, rec.Code)
+	var apiErr Error
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+	assert.Contains(t, apiErr.Error, falcon.String())
+
+	// Before the launch the snapshot tells the rocket didn't exist yet
+	assert.Empty(t, listAsOf("asOf="+start.Add(-time.Minute).Format(time.RFC3339)))
+	code, _ = getRocket(falcon, start.Add(-time.Minute).Format(time.RFC3339))
+	assert.Equal(t, http.StatusNotFound, code)
+
+	fleet = listAsOf("asOf=" + start.Add(35*time.Minute).Format(time.RFC3339))
+	assert.Len(t, fleet, 2)
+}
+
+func TestGaps(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	postMessage := func(channel uuid.UUID, number int, messageType string, payload map[string]any) {
+		body, _ := json.Marshal(map[string]any{
+			"metadata": map[string]any{
+				"channel":       channel,
+				"messageNumber": number,
+				"messageTime":   time.Now(),
+				"messageType":   messageType,
+			},
+			"message": payload,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	getJSON := func(path string, target any) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), target))
+		}
+		return rec.Code
+	}
+
+	// Messages 3, 4 and 7 are missing
+	stuck := uuid.New()
+	postMessage(stuck, 1, "RocketLaunched", map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"})
+	postMessage(stuck, 2, "RocketSpeedIncreased", map[string]any{"by": 100})
+	postMessage(stuck, 5, "RocketSpeedIncreased", map[string]any{"by": 100})
+	postMessage(stuck, 6, "RocketSpeedIncreased", map[string]any{"by": 100})
+	postMessage(stuck, 8, "RocketSpeedIncreased", map[string]any{"by": 100})
+
+	// The launch is missing, its gap opens later than the ones of stuck; times are stored to the millisecond
+	time.Sleep(2 * time.Millisecond)
+	unlaunched := uuid.New()
+	postMessage(unlaunched, 2, "RocketSpeedIncreased", map[string]any{"by": 100})
+
+	inSync := uuid.New()
+	postMessage(inSync, 1, "RocketLaunched", map[string]any{"type": "Saturn-V", "launchSpeed": 2000, "mission": "GEMINI"})
+
+	var rocket Rocket
+	require.Equal(t, http.StatusOK, getJSON("/rockets/"+stuck.String(), &rocket))
+	assert.Equal(t, 3, rocket.PendingMessages)
+	assert.False(t, rocket.InSync)
+	assert.Equal(t, 2, *rocket.LastMessageNumber)
+
+	require.Equal(t, http.StatusOK, getJSON("/rockets/"+inSync.String(), &rocket))
+	assert.Equal(t, 0, rocket.PendingMessages)
+	assert.True(t, rocket.InSync)
+
+	var gaps GapList
+	require.Equal(t, http.StatusOK, getJSON("/rockets/"+stuck.String()+"/gaps", &gaps))
+	require.Len(t, gaps.Gaps, 2)
+	assert.Equal(t, 3, gaps.Gaps[0].From)
+	assert.Equal(t, 4, gaps.Gaps[0].To)
+	assert.Equal(t, 2, gaps.Gaps[0].Missing)
+	assert.Equal(t, 7, gaps.Gaps[1].From)
+	assert.Equal(t, 7, gaps.Gaps[1].To)
+	assert.False(t, gaps.Gaps[1].OpenSince.Before(gaps.Gaps[0].OpenSince))
+	assert.GreaterOrEqual(t, gaps.Gaps[0].OpenForSeconds, 0)
+
+	require.Equal(t, http.StatusOK, getJSON("/rockets/"+inSync.String()+"/gaps", &gaps))
+	assert.Empty(t, gaps.Gaps)
+
+	assert.Equal(t, http.StatusNotFound, getJSON("/rockets/"+uuid.New().String()+"/gaps", &gaps))
+
+	// Fleet-wide, the oldest gap first
+	require.Equal(t, http.StatusOK, getJSON("/gaps", &gaps))
+	require.Len(t, gaps.Gaps, 3)
+	assert.Equal(t, stuck, gaps.Gaps[0].Channel)
+	assert.Equal(t, stuck, gaps.Gaps[1].Channel)
+	assert.Equal(t, unlaunched, gaps.Gaps[2].Channel)
+	assert.Equal(t, 1, gaps.Gaps[2].From)
+
+	// Filling the first gap applies messages up to the next one
+	postMessage(stuck, 3, "RocketSpeedIncreased", map[string]any{"by": 100})
+	postMessage(stuck, 4, "RocketSpeedIncreased", map[string]any{"by": 100})
+
+	require.Equal(t, http.StatusOK, getJSON("/rockets/"+stuck.String(), &rocket))
+	assert.Equal(t, 6, *rocket.LastMessageNumber)
+	assert.Equal(t, 1, rocket.PendingMessages)
+
+	require.Equal(t, http.StatusOK, getJSON("/rockets/"+stuck.String()+"/gaps", &gaps))
+	require.Len(t, gaps.Gaps, 1)
+	assert.Equal(t, 7, gaps.Gaps[0].From)
+}
+
+func TestGapSkipPolicy(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+
+	newHandler := func(policy rockets.GapPolicy) http.Handler {
+		feed := rockets.NewRocketFeed(100)
+		messagesService := newMessageService(messagesRepository, rocketsRepository, policy, feed, nil)
+		return HandlerFromMux(newRocketsAPI(messagesService, messagesRepository, rocketsRepository, feed, nil), chi.NewRouter())
+	}
+	postMessage := func(handler http.Handler, channel uuid.UUID, number int, messageType string, payload map[string]any) {
+		body, _ := json.Marshal(map[string]any{
+			"metadata": map[string]any{
+				"channel":       channel,
+				"messageNumber": number,
+				"messageTime":   time.Now(),
+				"messageType":   messageType,
+			},
+			"message": payload,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	getRocket := func(handler http.Handler, channel uuid.UUID) Rocket {
+		req := httptest.NewRequest(http.MethodGet, "/rockets/"+channel.String(), nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var rocket Rocket
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rocket))
+		return rocket
+	}
+
+	t.Run("skips after too many pending messages and rebuilds when the message arrives late", func(t *testing.T) {
+		handler := newHandler(rockets.GapPolicy{MaxPending: 2})
+		channelID := uuid.New()
+
+		postMessage(handler, channelID, 1, "RocketLaunched", map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"})
+		postMessage(handler, channelID, 4, "RocketSpeedIncreased", map[string]any{"by": 100})
+
+		rocket := getRocket(handler, channelID)
+		assert.Equal(t, 1, *rocket.LastMessageNumber)
+		assert.Equal(t, 1, rocket.PendingMessages)
+
+		postMessage(handler, channelID, 5, "RocketSpeedIncreased", map[string]any{"by": 100})
+
+		rocket = getRocket(handler, channelID)
+		assert.Equal(t, 5, *rocket.LastMessageNumber)
+		assert.Equal(t, 700, rocket.Speed)
+		assert.True(t, rocket.InSync)
+		require.NotNil(t, rocket.SkippedMessages)
+		assert.Equal(t, []MessageRange{{From: 2, To: 3}}, *rocket.SkippedMessages)
+
+		// Message 3 shows up, 2 is still lost
+		postMessage(handler, channelID, 3, "RocketSpeedDecreased", map[string]any{"by": 50})
+
+		rocket = getRocket(handler, channelID)
+		assert.Equal(t, 5, *rocket.LastMessageNumber)
+		assert.Equal(t, 650, rocket.Speed)
+		require.NotNil(t, rocket.SkippedMessages)
+		assert.Equal(t, []MessageRange{{From: 2, To: 2}}, *rocket.SkippedMessages)
+
+		postMessage(handler, channelID, 2, "RocketMissionChanged", map[string]any{"newMission": "APOLLO"})
+
+		rocket = getRocket(handler, channelID)
+		assert.Equal(t, 5, *rocket.LastMessageNumber)
+		assert.Equal(t, 650, rocket.Speed)
+		assert.Equal(t, "APOLLO", rocket.Mission)
+		assert.Nil(t, rocket.SkippedMessages)
+	})
+
+	t.Run("skips after the timeout", func(t *testing.T) {
+		handler := newHandler(rockets.GapPolicy{Timeout: 100 * time.Millisecond})
+		channelID := uuid.New()
+
+		postMessage(handler, channelID, 1, "RocketLaunched", map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"})
+		postMessage(handler, channelID, 3, "RocketSpeedIncreased", map[string]any{"by": 100})
+		assert.Equal(t, 1, *getRocket(handler, channelID).LastMessageNumber)
+
+		// No other message comes, the channel is processed again when the timeout expires
+		assert.Eventually(t, func() bool {
+			return *getRocket(handler, channelID).LastMessageNumber == 3
+		}, 5*time.Second, 20*time.Millisecond)
+
+		rocket := getRocket(handler, channelID)
+		assert.Equal(t, 600, rocket.Speed)
+		require.NotNil(t, rocket.SkippedMessages)
+		assert.Equal(t, []MessageRange{{From: 2, To: 2}}, *rocket.SkippedMessages)
+	})
+
+	t.Run("never skips the launch", func(t *testing.T) {
+		handler := newHandler(rockets.GapPolicy{MaxPending: 1})
+		channelID := uuid.New()
+
+		postMessage(handler, channelID, 2, "RocketSpeedIncreased", map[string]any{"by": 100})
+		postMessage(handler, channelID, 3, "RocketSpeedIncreased", map[string]any{"by": 100})
+
+		req := httptest.NewRequest(http.MethodGet, "/rockets/"+channelID.String(), nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestMultipleInstances(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+
+	// Every instance has its own locks and state, only the database is shared
+	var instances []http.Handler
+	for i := 0; i < 3; i++ {
+		feed := rockets.NewRocketFeed(100)
+		messagesService := newMessageService(messagesRepository, rocketsRepository, rockets.GapPolicy{}, feed, nil)
+		instances = append(instances, HandlerFromMux(newRocketsAPI(messagesService, messagesRepository, rocketsRepository, feed, nil), chi.NewRouter()))
+	}
+
+	const channels = 10
+	const messagesPerChannel = 30
+	var bodies [][]byte
+	var channelIDs []uuid.UUID
+	for c := 0; c < channels; c++ {
+		channelID := uuid.New()
+		channelIDs = append(channelIDs, channelID)
+		for number := 1; number <= messagesPerChannel; number++ {
+			message := map[string]any{
+				"metadata": map[string]any{
+					"channel":       channelID,
+					"messageNumber": number,
+					"messageTime":   time.Now(),
+					"messageType":   "RocketSpeedIncreased",
+				},
+				"message": map[string]any{"by": number},
+			}
+			if number == 1 {
+				message["metadata"].(map[string]any)["messageType"] = "RocketLaunched"
+				message["message"] = map[string]any{"type": "Falcon-9", "launchSpeed": 0, "mission": "ARTEMIS"}
+			}
+			body, _ := json.Marshal(message)
+			bodies = append(bodies, body)
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(bodies), func(i, j int) { bodies[i], bodies[j] = bodies[j], bodies[i] })
+
+	// Messages are spread over the instances and sent concurrently
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(bodies[i]))
+				rec := httptest.NewRecorder()
+				instances[i%len(instances)].ServeHTTP(rec, req)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			}
+		}()
+	}
+	for i := range bodies {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	// No update is lost: every rocket adds up all its speed increases
+	expectedSpeed := 0
+	for number := 2; number <= messagesPerChannel; number++ {
+		expectedSpeed += number
+	}
+	for _, channelID := range channelIDs {
+		rocket, err := rocketsRepository.FindByChannel(context.Background(), channelID)
+		require.NoError(t, err)
+		assert.Equal(t, messagesPerChannel, *rocket.LastMessageNumber)
+		assert.Equal(t, expectedSpeed, rocket.Speed)
+		assert.Equal(t, 0, rocket.PendingMessages)
+	}
+}
+
+func TestAdminRebuild(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	do := func(method, path, token string, body any, target any) int {
+		var reader *bytes.Reader
+		if body != nil {
+			encoded, _ := json.Marshal(body)
+			reader = bytes.NewReader(encoded)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if target != nil && rec.Code < 300 {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), target))
+		}
+		return rec.Code
+	}
+
+	var channels []uuid.UUID
+	for i := 0; i < 3; i++ {
+		channel := uuid.New()
+		channels = append(channels, channel)
+		launch := map[string]any{
+			"metadata": map[string]any{"channel": channel, "messageNumber": 1, "messageTime": time.Now(), "messageType": "RocketLaunched"},
+			"message":  map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"},
+		}
+		increase := map[string]any{
+			"metadata": map[string]any{"channel": channel, "messageNumber": 2, "messageTime": time.Now(), "messageType": "RocketSpeedIncreased"},
+			"message":  map[string]any{"by": 100},
+		}
+		require.Equal(t, http.StatusOK, do(http.MethodPost, "/messages", "", launch, nil))
+		require.Equal(t, http.StatusOK, do(http.MethodPost, "/messages", "", increase, nil))
+	}
+
+	// A bug stored wrong speeds for two of the rockets
+	for _, channel := range channels[:2] {
+		rocket, err := rocketsRepository.FindByChannel(context.Background(), channel)
+		require.NoError(t, err)
+		rocket.Speed = 1
+		require.NoError(t, rocketsRepository.Upsert(context.Background(), *rocket))
+	}
+
+	path := "/admin/rockets/" + channels[0].String() + "/rebuild"
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, path, "", nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, path, "wrong", nil, nil))
+
+	var rebuild RocketRebuild
+	require.Equal(t, http.StatusOK, do(http.MethodPost, path+"?dryRun=true", adminToken, nil, &rebuild))
+	assert.Equal(t, []string{"speed"}, rebuild.Changes)
+	assert.Equal(t, 1, rebuild.Before.Speed)
+	assert.Equal(t, 600, rebuild.After.Speed)
+	var rocket Rocket
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/rockets/"+channels[0].String(), "", nil, &rocket))
+	assert.Equal(t, 1, rocket.Speed)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, path, adminToken, nil, &rebuild))
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/rockets/"+channels[0].String(), "", nil, &rocket))
+	assert.Equal(t, 600, rocket.Speed)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/admin/rockets/"+uuid.New().String()+"/rebuild", adminToken, nil, nil))
+
+	// The rest of the fleet
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/rebuilds", adminToken, map[string]any{"concurrency": 0}, nil))
+	var job RebuildJob
+	require.Equal(t, http.StatusAccepted, do(http.MethodPost, "/admin/rebuilds", adminToken, map[string]any{"concurrency": 2}, &job))
+	require.Eventually(t, func() bool {
+		return do(http.MethodGet, "/admin/rebuilds/"+job.Id.String(), adminToken, nil, &job) == http.StatusOK && job.Status != Running
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, Finished, job.Status)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, 3, job.Done)
+	assert.Equal(t, 1, job.Changed)
+	require.Len(t, job.Rockets, 1)
+	assert.Equal(t, channels[1], job.Rockets[0].Channel)
+	assert.Empty(t, job.Failures)
+
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/rockets/"+channels[1].String(), "", nil, &rocket))
+	assert.Equal(t, 600, rocket.Speed)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/admin/rebuilds/"+job.Id.String(), "", nil, nil))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/rebuilds/"+uuid.New().String(), adminToken, nil, nil))
+}
+
+func TestAdminOnly(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	api := newRocketsAPI(nil, messagesRepository, rocketsRepository, rockets.NewRocketFeed(10), nil)
+	handler := api.AdminOnly(expvar.Handler())
+
+	for token, code := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, adminToken: http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, code, rec.Code, "token %q", token)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	handler := setupHanler(t, messagesRepository, rocketsRepository)
+
+	do := func(method, path, token string, body any, target any) int {
+		encoded, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(encoded))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if target != nil && rec.Code < 300 {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), target))
+		}
+		return rec.Code
+	}
+	post := func(channel uuid.UUID, number int, messageType string, payload map[string]any) {
+		message := map[string]any{
+			"metadata": map[string]any{"channel": channel, "messageNumber": number, "messageTime": time.Now(), "messageType": messageType},
+			"message":  payload,
+		}
+		require.Equal(t, http.StatusOK, do(http.MethodPost, "/messages", "", message, nil))
+	}
+
+	// The incident tooling checks the signature of every delivery, and is down until it is fixed
+	var down sync.Map
+	type delivery struct {
+		id    string
+		event LifecycleEvent
+	}
+	received := make(chan delivery, 10)
+	secrets := map[string]string{"/incidents": "incidents secret", "/flaky": "flaky secret"}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(rockets.WebhookTimestampHeader), 10, 64)
+		if r.Header.Get(rockets.WebhookSignatureHeader) != rockets.SignWebhook(secrets[r.URL.Path], timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, isDown := down.Load(r.URL.Path); isDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event LifecycleEvent
+		_ = json.Unmarshal(body, &event)
+		received <- delivery{id: r.Header.Get(rockets.WebhookDeliveryHeader), event: event}
+	}))
+	defer receiver.Close()
+	next := func() delivery {
+		select {
+		case d := <-received:
+			return d
+		case <-time.After(5 * time.Second):
+			t.Fatal("no delivery received")
+			return delivery{}
+		}
+	}
+
+	request := WebhookRequest{Url: receiver.URL + "/incidents", Events: []string{"rocket.exploded", "rocket.missionChanged"}}
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/admin/webhooks", "", request, nil))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/webhooks", adminToken, WebhookRequest{Url: "incidents", Events: []string{"rocket.exploded"}}, nil))
+	incidentsSecret, flakySecret := "incidents secret", "flaky secret"
+	request.Secret = &incidentsSecret
+	var incidents Webhook
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/admin/webhooks", adminToken, request, &incidents))
+	assert.Equal(t, "incidents secret", *incidents.Secret)
+
+	// Secrets are only shown on creation
+	var listed []Webhook
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/webhooks", adminToken, nil, &listed))
+	require.Len(t, listed, 1)
+	assert.Nil(t, listed[0].Secret)
+	assert.Equal(t, []string{"rocket.exploded", "rocket.missionChanged"}, listed[0].Events)
+
+	channel := uuid.New()
+	post(channel, 1, "RocketLaunched", map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"})
+	post(channel, 2, "RocketSpeedIncreased", map[string]any{"by": 100})
+	post(channel, 3, "RocketMissionChanged", map[string]any{"newMission": "APOLLO"})
+	changed := next()
+	assert.Equal(t, "rocket.missionChanged", changed.event.Type)
+	assert.Equal(t, "ARTEMIS", *changed.event.PreviousMission)
+	assert.Equal(t, "APOLLO", changed.event.Rocket.Mission)
+	assert.Equal(t, 600, changed.event.Rocket.Speed)
+
+	post(channel, 4, "RocketExploded", map[string]any{"reason": "PRESSURE_VESSEL_FAILURE"})
+	exploded := next()
+	assert.Equal(t, "rocket.exploded", exploded.event.Type)
+	assert.Equal(t, Exploded, exploded.event.Rocket.Status)
+	assert.Equal(t, channel, exploded.event.Rocket.Channel)
+
+	// A webhook that keeps failing ends up with the failed deliveries
+	down.Store("/flaky", true)
+	var flaky Webhook
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/admin/webhooks", adminToken, WebhookRequest{Url: receiver.URL + "/flaky", Events: []string{"rocket.launched"}, Secret: &flakySecret}, &flaky))
+	launched := uuid.New()
+	post(launched, 1, "RocketLaunched", map[string]any{"type": "Saturn-V", "launchSpeed": 1000, "mission": "APOLLO"})
+
+	var failed []WebhookDelivery
+	require.Eventually(t, func() bool {
+		return do(http.MethodGet, "/admin/webhooks/deliveries?webhook="+flaky.Id.String(), adminToken, nil, &failed) == http.StatusOK && len(failed) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 3, failed[0].Attempts)
+	assert.Contains(t, failed[0].Error, "503")
+	assert.Equal(t, launched, failed[0].Event.Rocket.Channel)
+
+	var parked WebhookDelivery
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/webhooks/deliveries/"+failed[0].Id.String(), adminToken, nil, &parked))
+	assert.Equal(t, "rocket.launched", parked.Event.Type)
+	assert.Equal(t, http.StatusBadGateway, do(http.MethodPost, "/admin/webhooks/deliveries/"+parked.Id.String()+"/redeliver", adminToken, nil, nil))
+
+	// Once fixed, the redelivery goes through with the same delivery ID
+	down.Delete("/flaky")
+	var redelivered WebhookDelivery
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/admin/webhooks/deliveries/"+parked.Id.String()+"/redeliver", adminToken, nil, &redelivered))
+	assert.Equal(t, 5, redelivered.Attempts)
+	assert.Equal(t, parked.Id.String(), next().id)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/webhooks/deliveries/"+parked.Id.String(), adminToken, nil, nil))
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/webhooks/"+flaky.Id.String(), adminToken, nil, nil))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/webhooks/"+flaky.Id.String(), adminToken, nil, nil))
+}
+
+func TestRocketStreams(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	server := httptest.NewServer(setupHanler(t, messagesRepository, rocketsRepository))
+	defer server.Close()
+
+	post := func(channel uuid.UUID, number int, messageType string, payload map[string]any) {
+		body, _ := json.Marshal(map[string]any{
+			"metadata": map[string]any{"channel": channel, "messageNumber": number, "messageTime": time.Now(), "messageType": messageType},
+			"message":  payload,
+		})
+		resp, err := http.Post(server.URL+"/messages", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	type event struct {
+		id     string
+		rocket Rocket
+	}
+	open := func(path, lastEventID string) (<-chan event, func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		events := make(chan event, 10)
+		go func() {
+			defer close(events)
+			defer resp.Body.Close()
+			var current event
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				switch {
+				case strings.HasPrefix(line, "id: "):
+					current.id = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "data: "):
+					_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.rocket)
+				case line == "":
+					events <- current
+					current = event{}
+				}
+			}
+		}()
+		return events, cancel
+	}
+	next := func(events <-chan event) event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return event{}
+		}
+	}
+
+	launched := uuid.New()
+	post(launched, 1, "RocketLaunched", map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"})
+
+	// The fleet stream starts with the current state
+	fleet, closeFleet := open("/rockets/stream", "")
+	defer closeFleet()
+	assert.Equal(t, launched, next(fleet).rocket.Channel)
+
+	// A rocket stream can be opened before the launch
+	channel := uuid.New()
+	rocket, closeRocket := open("/rockets/"+channel.String()+"/stream", "")
+	post(channel, 1, "RocketLaunched", map[string]any{"type": "Saturn-V", "launchSpeed": 1000, "mission": "APOLLO"})
+	launch := next(rocket)
+	assert.Equal(t, 1000, launch.rocket.Speed)
+	assert.Equal(t, channel, next(fleet).rocket.Channel)
+
+	// Updates missed while disconnected are sent on resume
+	closeRocket()
+	post(launched, 2, "RocketSpeedIncreased", map[string]any{"by": 100})
+	post(channel, 2, "RocketSpeedIncreased", map[string]any{"by": 200})
+	post(channel, 3, "RocketExploded", map[string]any{"reason": "PRESSURE_VESSEL_FAILURE"})
+	rocket, closeRocket = open("/rockets/"+channel.String()+"/stream", launch.id)
+	defer closeRocket()
+	assert.Equal(t, 1200, next(rocket).rocket.Speed)
+	exploded := next(rocket).rocket
+	assert.Equal(t, Exploded, exploded.Status)
+	assert.Equal(t, "PRESSURE_VESSEL_FAILURE", *exploded.ExplosionReason)
+
+	assert.Equal(t, 600, next(fleet).rocket.Speed)
+	assert.Equal(t, 1200, next(fleet).rocket.Speed)
+	assert.Equal(t, Exploded, next(fleet).rocket.Status)
+
+	// An ID the instance doesn't know sends the current state again
+	resumed, closeResumed := open("/rockets/"+channel.String()+"/stream", "unknown-1")
+	defer closeResumed()
+	assert.Equal(t, Exploded, next(resumed).rocket.Status)
+}
+
+func TestRocketWebSocket(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	server := httptest.NewServer(setupHanler(t, messagesRepository, rocketsRepository))
+	defer server.Close()
+
+	post := func(channel uuid.UUID, number int, messageType string, payload map[string]any) {
+		body, _ := json.Marshal(map[string]any{
+			"metadata": map[string]any{"channel": channel, "messageNumber": number, "messageTime": time.Now(), "messageType": messageType},
+			"message":  payload,
+		})
+		resp, err := http.Post(server.URL+"/messages", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	artemis := uuid.New()
+	post(artemis, 1, "RocketLaunched", map[string]any{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"})
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/rockets/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	type message struct {
+		Type          string         `json:"type"`
+		ID            string         `json:"id"`
+		Error         string         `json:"error"`
+		Channel       uuid.UUID      `json:"channel"`
+		Subscriptions []string       `json:"subscriptions"`
+		Fields        map[string]any `json:"fields"`
+	}
+	send := func(m map[string]any) {
+		require.NoError(t, conn.WriteJSON(m))
+	}
+	next := func() message {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		var m message
+		require.NoError(t, conn.ReadJSON(&m))
+		return m
+	}
+
+	// Invalid requests are answered with errors, the connection stays open
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	assert.Equal(t, "error", next().Type)
+	send(map[string]any{"type": "subscribe", "id": "bad", "filter": map[string]any{"changes": []string{"altitude"}}})
+	reply := next()
+	assert.Equal(t, "error", reply.Type)
+	assert.Equal(t, "bad", reply.ID)
+
+	// A subscription starts with the whole rockets it matches
+	send(map[string]any{"type": "subscribe", "id": "artemis", "filter": map[string]any{"missions": []string{"ARTEMIS"}}})
+	assert.Equal(t, message{Type: "subscribed", ID: "artemis"}, next())
+	initial := next()
+	assert.Equal(t, "diff", initial.Type)
+	assert.Equal(t, artemis, initial.Channel)
+	assert.Equal(t, []string{"artemis"}, initial.Subscriptions)
+	assert.Equal(t, "ARTEMIS", initial.Fields["mission"])
+	assert.EqualValues(t, 500, initial.Fields["speed"])
+
+	// The rockets the connection already got are not sent again
+	send(map[string]any{"type": "subscribe", "id": "status", "filter": map[string]any{"changes": []string{"status"}}})
+	assert.Equal(t, message{Type: "subscribed", ID: "status"}, next())
+
+	// Then only the fields that changed, to the subscriptions interested in them
+	post(artemis, 2, "RocketSpeedIncreased", map[string]any{"by": 100})
+	diff := next()
+	assert.Equal(t, []string{"artemis"}, diff.Subscriptions)
+	assert.EqualValues(t, 600, diff.Fields["speed"])
+	assert.EqualValues(t, 2, diff.Fields["lastMessageNumber"])
+	assert.NotContains(t, diff.Fields, "mission")
+	assert.NotContains(t, diff.Fields, "status")
+
+	apollo := uuid.New()
+	post(apollo, 1, "RocketLaunched", map[string]any{"type": "Saturn-V", "launchSpeed": 1000, "mission": "APOLLO"})
+	diff = next()
+	assert.Equal(t, apollo, diff.Channel)
+	assert.Equal(t, []string{"status"}, diff.Subscriptions)
+	assert.Equal(t, string(Active), diff.Fields["status"])
+
+	// A rocket leaving a subscription is sent to it one last time
+	post(artemis, 3, "RocketMissionChanged", map[string]any{"newMission": "APOLLO"})
+	diff = next()
+	assert.Equal(t, []string{"artemis"}, diff.Subscriptions)
+	// Two messages can be sent within the resolution of the clock, leaving the message time and what is derived
+	// from it unchanged
+	delete(diff.Fields, "lastMessageTime")
+	delete(diff.Fields, "flightDuration")
+	delete(diff.Fields, "distance")
+	assert.Equal(t, map[string]any{"mission": "APOLLO", "missionChanges": float64(1), "lastMessageNumber": float64(3)}, diff.Fields)
+
+	send(map[string]any{"type": "unsubscribe", "id": "artemis"})
+	assert.Equal(t, message{Type: "unsubscribed", ID: "artemis"}, next())
+	send(map[string]any{"type": "unsubscribe", "id": "artemis"})
+	assert.Equal(t, "error", next().Type)
+
+	post(apollo, 2, "RocketExploded", map[string]any{"reason": "PRESSURE_VESSEL_FAILURE"})
+	diff = next()
+	assert.Equal(t, []string{"status"}, diff.Subscriptions)
+	assert.Equal(t, string(Exploded), diff.Fields["status"])
+	assert.Equal(t, "PRESSURE_VESSEL_FAILURE", diff.Fields["explosionReason"])
+
+	// Every field of the rocket can be followed, the derived metrics included
+	send(map[string]any{"type": "subscribe", "id": "peak", "filter": map[string]any{"changes": []string{"peakSpeed"}}})
+	assert.Equal(t, message{Type: "subscribed", ID: "peak"}, next())
+	post(artemis, 4, "RocketSpeedIncreased", map[string]any{"by": 100})
+	diff = next()
+	assert.Equal(t, []string{"peak"}, diff.Subscriptions)
+	assert.EqualValues(t, 700, diff.Fields["peakSpeed"])
+
+	// Slowing down leaves the peak, nothing is sent until it rises again
+	post(artemis, 5, "RocketSpeedDecreased", map[string]any{"by": 300})
+	post(artemis, 6, "RocketSpeedIncreased", map[string]any{"by": 400})
+	diff = next()
+	assert.Equal(t, []string{"peak"}, diff.Subscriptions)
+	assert.EqualValues(t, 6, diff.Fields["lastMessageNumber"])
+	assert.EqualValues(t, 800, diff.Fields["peakSpeed"])
+}
+
+func TestRocketWebSocketStartsWithMoreRocketsThanItsBuffer(t *testing.T) {
+	messagesRepository, rocketsRepository := setupRepositories(t)
+	feed := rockets.NewRocketFeed(100)
+	messagesService := newMessageService(messagesRepository, rocketsRepository, rockets.GapPolicy{}, feed, nil)
+	rocketsService := rockets.NewRocketsServiceImpl(rocketsRepository, messagesRepository)
+	api := NewRocketsAPI(messagesService, rocketsService, nil, nil, feed, Config{WebSocket: WebSocketConfig{Buffer: 2}})
+	server := httptest.NewServer(HandlerFromMux(api, chi.NewRouter()))
+	defer server.Close()
+
+	const fleetSize = 50
+	for i := 0; i < fleetSize; i++ {
+		require.NoError(t, messagesService.Ingest(context.Background(), rockets.Message{
+			Metadata: rockets.Metadata{Channel: uuid.New(), MessageNumber: 1, MessageTime: time.Now(), MessageType: rockets.RocketLaunched},
+			Message:  map[string]interface{}{"type": "Falcon-9", "launchSpeed": float64(500), "mission": "ARTEMIS"},
+		}))
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/rockets/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "subscribe", "id": "all", "filter": map[string]any{}}))
+
+	// The client reads slowly, the rockets wait for it rather than disconnecting it
+	channels := make(map[string]bool)
+	for len(channels) < fleetSize {
+		time.Sleep(time.Millisecond)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		var m struct {
+			Type    string `json:"type"`
+			Channel string `json:"channel"`
+		}
+		require.NoError(t, conn.ReadJSON(&m))
+		if m.Type == "diff" {
+			channels[m.Channel] = true
+		}
+	}
+}
+
+func setupMongoDB(t *testing.T) (*mongo.Client, func()) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	req := testcontainers.ContainerRequest{
+		Image:        "mongo:8.0",
+		ExposedPorts: []string{"27017/tcp"},
+		Env: map[string]string{
+			"MONGO_INITDB_ROOT_USERNAME": "test",
+			"MONGO_INITDB_ROOT_PASSWORD": "test",
+			"MONGO_INITDB_DATABASE":      "rockets_test",
+		},
+		WaitingFor: wait.ForLog("Waiting for connections").
+			WithStartupTimeout(60 * time.Second),
+	}
+
+	mongoContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+
+	host, err := mongoContainer.Host(ctx)
+	require.NoError(t, err)
+
+	port, err := mongoContainer.MappedPort(ctx, "27017")
+	require.NoError(t, err)
+
+	connStr := "mongodb://test:test@" + host + ":" + port.Port()
+
+	clientOptions := options.Client().ApplyURI(connStr)
+	mongoClient, err := mongo.Connect(ctx, clientOptions)
+	require.NoError(t, err)
+
+	err = mongoClient.Ping(ctx, nil)
+	require.NoError(t, err)
+
+	cleanup := func() {
+		_ = mongoClient.Disconnect(ctx)
+		_ = mongoContainer.Terminate(ctx)
+	}
+
+	return mongoClient, cleanup
+}
+
+// adminToken is the admin token of the handlers under test.
+const adminToken = "test-admin-token"
+
+func setupHanler(t *testing.T, messagesRepository rockets.MessageRepository, rocketsRepository rockets.RocketsRepository) http.Handler {
+	feed := rockets.NewRocketFeed(100)
+	// Deliveries give up quickly, so failed ones reach the dead letters within a test
+	webhooks := rockets.NewWebhookService(rockets.NewMemoryWebhookRepository(), rockets.NewMemoryDeadLetterRepository(), rockets.WebhookPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		Timeout:        time.Second,
+	})
+	t.Cleanup(func() { _ = webhooks.Shutdown(context.Background()) })
+	messagesService := newMessageService(messagesRepository, rocketsRepository, rockets.GapPolicy{}, feed, webhooks)
+	api := newRocketsAPI(messagesService, messagesRepository, rocketsRepository, feed, webhooks)
+	handler := HandlerFromMux(api, chi.NewRouter())
+	return handler
+}
+
+// setupRepositories returns empty repositories of the backend selected with STORAGE: memory by default, so the suite
+// runs anywhere, a bolt file, or mongo in a container.
+func setupRepositories(t *testing.T) (rockets.MessageRepository, rockets.RocketsRepository) {
+	switch os.Getenv("STORAGE") {
+	case "mongo":
+	case "bolt":
+		db, err := rockets.OpenBolt(filepath.Join(t.TempDir(), "rockets.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		messagesRepository := rockets.NewBoltMessageRepository(db)
+		rocketsRepository := rockets.NewBoltRocketsRepository(db)
+		require.NoError(t, messagesRepository.EnsureBuckets(context.Background()))
+		require.NoError(t, rocketsRepository.EnsureBuckets(context.Background()))
+		return messagesRepository, rocketsRepository
+	default:
+		return rockets.NewMemoryMessageRepository(), rockets.NewMemoryRocketsRepository()
+	}
+
+	mongoClient, cleanup := setupMongoDB(t)
+	t.Cleanup(cleanup)
+
+	db := mongoClient.Database("rockets_test")
+	require.NoError(t, migrations.Migrate(context.Background(), db))
+	return rockets.NewMongoMessageRepository(db.Collection("messages")), rockets.NewMongoRocketsRepository(db.Collection("rockets"))
+}
+
+// newMessageService builds the resequencer selected with RESEQUENCER, so the suite runs against each of them.
+func newMessageService(messagesRepository rockets.MessageRepository, rocketsRepository rockets.RocketsRepository, policy rockets.GapPolicy, feed *rockets.RocketFeed, webhooks *rockets.WebhookService) rockets.MessageService {
+	if os.Getenv("RESEQUENCER") == "buffered" {
+		service := rockets.NewBufferedMessageService(messagesRepository, rocketsRepository, policy)
+		service.PublishTo(feed)
+		service.NotifyWebhooks(webhooks)
+		return service
+	}
+	service := rockets.NewResequencerMessageService(messagesRepository, rocketsRepository, policy)
+	service.PublishTo(feed)
+	service.NotifyWebhooks(webhooks)
+	return service
+}
+
+// newRocketsAPI returns the API of an instance, its rockets are published to the feed by the message service. Tests
+// that don't use webhooks pass nil.
+func newRocketsAPI(messagesService rockets.MessageService, messagesRepository rockets.MessageRepository, rocketsRepository rockets.RocketsRepository, feed *rockets.RocketFeed, webhooks *rockets.WebhookService) *RocketsAPI {
+	rocketsService := rockets.NewRocketsServiceImpl(rocketsRepository, messagesRepository)
+	rebuildService := rockets.NewRebuildService(messagesRepository, rocketsRepository)
+	rebuildService.PublishTo(feed)
+	return NewRocketsAPI(messagesService, rocketsService, rebuildService, webhooks, feed, Config{AdminToken: adminToken})
+}
+
+// allRockets returns every stored rocket, ordered by channel.
+func allRockets(t *testing.T, rocketsRepository rockets.RocketsRepository) []rockets.Rocket {
+	page, err := rocketsRepository.All(context.Background(), rockets.RocketQuery{})
+	require.NoError(t, err)
+	return page.Rockets
 }
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/adrianrios/lunar-test/internal/rockets"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+const (
+	defaultRebuildConcurrency = 4
+	maxRebuildConcurrency     = 32
+)
+
+// authorized checks the admin token of the request. Without an ADMIN_TOKEN every admin request is refused.
+func (a RocketsAPI) authorized(w http.ResponseWriter, r *http.Request) bool {
+	expected := "Bearer " + a.config.AdminToken
+	if a.config.AdminToken == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "a valid admin token is required")
+		return false
+	}
+	return true
+}
+
+// AdminOnly guards a handler mounted next to the API, like the runtime metrics, with the admin token.
+func (a RocketsAPI) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorized(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a RocketsAPI) RebuildRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params RebuildRocketParams) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	rebuild, err := a.rebuildService.Rebuild(r.Context(), uuid.UUID(channel), params.DryRun != nil && *params.DryRun)
+	switch {
+	case errors.Is(err, rockets.ErrRocketNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, rockets.ErrIncompleteLog):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toAPIRocketRebuild(*rebuild))
+}
+
+func (a RocketsAPI) StartRebuild(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	var req RebuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	options := rockets.RebuildOptions{Concurrency: defaultRebuildConcurrency}
+	if req.DryRun != nil {
+		options.DryRun = *req.DryRun
+	}
+	if req.Concurrency != nil {
+		if *req.Concurrency < 1 || *req.Concurrency > maxRebuildConcurrency {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("concurrency must be between 1 and %d", maxRebuildConcurrency))
+			return
+		}
+		options.Concurrency = *req.Concurrency
+	}
+
+	job, err := a.rebuildService.Start(options)
+	switch {
+	case errors.Is(err, rockets.ErrRebuildRunning):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, rockets.ErrShuttingDown):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/rebuilds/"+job.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(toAPIRebuildJob(job))
+}
+
+func (a RocketsAPI) GetRebuild(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	job, err := a.rebuildService.Job(uuid.UUID(id))
+	if errors.Is(err, rockets.ErrRebuildNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toAPIRebuildJob(job))
+}
+
+func toAPIRocketRebuild(r rockets.RocketRebuild) RocketRebuild {
+	return RocketRebuild{
+		Channel: openapi_types.UUID(r.Channel),
+		Changes: r.Changes,
+		Before:  toAPIRocket(r.Before),
+		After:   toAPIRocket(r.After),
+	}
+}
+
+func toAPIRebuildJob(job rockets.RebuildJob) RebuildJob {
+	status := Finished
+	var jobErr *string
+	switch {
+	case job.Running():
+		status = Running
+	case job.Error != "":
+		status = Stopped
+		jobErr = &job.Error
+	}
+
+	resp := RebuildJob{
+		Id:         openapi_types.UUID(job.ID),
+		Status:     status,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		Error:      jobErr,
+		DryRun:     job.Report.DryRun,
+		Total:      job.Report.Total,
+		Done:       job.Report.Done,
+		Changed:    job.Report.Changed,
+		Failed:     job.Report.Failed,
+		Rockets:    make([]RocketRebuild, 0, len(job.Report.Rockets)),
+		Failures:   make([]RebuildFailure, 0, len(job.Report.Failures)),
+	}
+	for _, rebuild := range job.Report.Rockets {
+		resp.Rockets = append(resp.Rockets, toAPIRocketRebuild(rebuild))
+	}
+	for _, failure := range job.Report.Failures {
+		resp.Failures = append(resp.Failures, RebuildFailure{Channel: openapi_types.UUID(failure.Channel), Error: failure.Error})
+	}
+	return resp
+}
@@ -6,6 +6,7 @@ package api
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -21,6 +22,20 @@ import (
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
+const (
+	AdminTokenScopes = "adminToken.Scopes"
+)
+
+// Defines values for BatchItemResultStatus.
+const (
+	BatchItemResultStatusAccepted  BatchItemResultStatus = "accepted"
+	BatchItemResultStatusConflict  BatchItemResultStatus = "conflict"
+	BatchItemResultStatusDuplicate BatchItemResultStatus = "duplicate"
+	BatchItemResultStatusFailed    BatchItemResultStatus = "failed"
+	BatchItemResultStatusInvalid   BatchItemResultStatus = "invalid"
+	BatchItemResultStatusRejected  BatchItemResultStatus = "rejected"
+)
+
 // Defines values for MessageMetadataMessageType.
 const (
 	RocketExploded       MessageMetadataMessageType = "RocketExploded"
@@ -30,18 +45,24 @@ const (
 	RocketSpeedIncreased MessageMetadataMessageType = "RocketSpeedIncreased"
 )
 
+// Defines values for RebuildJobStatus.
+const (
+	Finished RebuildJobStatus = "finished"
+	Running  RebuildJobStatus = "running"
+	Stopped  RebuildJobStatus = "stopped"
+)
+
 // Defines values for RocketStatus.
 const (
 	Active   RocketStatus = "active"
 	Exploded RocketStatus = "exploded"
 )
 
-// Defines values for ListRocketsParamsSortBy.
+// Defines values for RocketEventStatus.
 const (
-	Mission ListRocketsParamsSortBy = "mission"
-	Speed   ListRocketsParamsSortBy = "speed"
-	Status  ListRocketsParamsSortBy = "status"
-	Type    ListRocketsParamsSortBy = "type"
+	RocketEventStatusApplied   RocketEventStatus = "applied"
+	RocketEventStatusDuplicate RocketEventStatus = "duplicate"
+	RocketEventStatusPending   RocketEventStatus = "pending"
 )
 
 // Defines values for ListRocketsParamsOrder.
@@ -50,12 +71,107 @@ const (
 	Desc ListRocketsParamsOrder = "desc"
 )
 
+// BatchItemResult defines model for BatchItemResult.
+type BatchItemResult struct {
+	Channel *openapi_types.UUID `json:"channel,omitempty"`
+
+	// Index Position of the message in the batch, starting at 0
+	Index         int  `json:"index"`
+	MessageNumber *int `json:"messageNumber,omitempty"`
+
+	// Reason Why the message was not accepted
+	Reason *string `json:"reason,omitempty"`
+
+	// Status accepted: stored and applied (or queued). duplicate: already received. conflict: the message number was
+	// already received with a different content. invalid: the message can't be decoded or breaks a validation
+	// rule. rejected: the channel
+	// queue is full or the service is shutting down. failed: unexpected error, the message can be retried.
+	Status BatchItemResultStatus `json:"status"`
+}
+
+// BatchItemResultStatus accepted: stored and applied (or queued). duplicate: already received. conflict: the message number was
+// already received with a different content. invalid: the message can't be decoded or breaks a validation
+// rule. rejected: the channel
+// queue is full or the service is shutting down. failed: unexpected error, the message can be retried.
+type BatchItemResultStatus string
+
+// BatchResult defines model for BatchResult.
+type BatchResult struct {
+	Results []BatchItemResult `json:"results"`
+}
+
 // Error defines model for Error.
 type Error struct {
 	// Error Error message
 	Error string `json:"error"`
+
+	// Field JSON path of the offending field, for validation errors
+	Field *string `json:"field,omitempty"`
+}
+
+// FleetStats defines model for FleetStats.
+type FleetStats struct {
+	// Active Rockets whose last applied message was sent in the last `activeWithin` minutes
+	Active int `json:"active"`
+
+	// ActiveWithin Minutes used to count the active rockets
+	ActiveWithin int          `json:"activeWithin"`
+	ByMission    []StatsCount `json:"byMission"`
+	ByStatus     []StatsCount `json:"byStatus"`
+	ByType       []StatsCount `json:"byType"`
+
+	// Explosions Exploded rockets by explosion reason
+	Explosions []StatsCount `json:"explosions"`
+
+	// Groups Rockets by the value of the `groupBy` field, only with `groupBy`
+	Groups      *[]StatsGroup `json:"groups,omitempty"`
+	SpeedByType []StatsGroup  `json:"speedByType"`
+
+	// Total Number of rockets aggregated
+	Total int `json:"total"`
+}
+
+// Gap defines model for Gap.
+type Gap struct {
+	Channel openapi_types.UUID `json:"channel"`
+
+	// From First missing message number
+	From int `json:"from"`
+
+	// Missing Number of missing messages
+	Missing int `json:"missing"`
+
+	// OpenForSeconds How long the gap has been open
+	OpenForSeconds int `json:"openForSeconds"`
+
+	// OpenSince When the first message after the gap was received
+	OpenSince time.Time `json:"openSince"`
+
+	// To Last missing message number
+	To int `json:"to"`
 }
 
+// GapList defines model for GapList.
+type GapList struct {
+	Gaps []Gap `json:"gaps"`
+}
+
+// LifecycleEvent Body of a webhook delivery
+type LifecycleEvent struct {
+	Id         openapi_types.UUID `json:"id"`
+	OccurredAt time.Time          `json:"occurredAt"`
+
+	// PreviousMission Mission the rocket changed from, on rocket.missionChanged
+	PreviousMission *string `json:"previousMission,omitempty"`
+	Rocket          Rocket  `json:"rocket"`
+
+	// Type One of rocket.launched, rocket.exploded or rocket.missionChanged
+	Type LifecycleEventType `json:"type"`
+}
+
+// LifecycleEventType One of rocket.launched, rocket.exploded or rocket.missionChanged
+type LifecycleEventType = string
+
 // MessageMetadata defines model for MessageMetadata.
 type MessageMetadata struct {
 	// Channel Unique channel ID for the rocket
@@ -72,29 +188,111 @@ type MessageMetadata struct {
 // MessageMetadataMessageType defines model for MessageMetadata.MessageType.
 type MessageMetadataMessageType string
 
+// MessageRange defines model for MessageRange.
+type MessageRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// RebuildFailure defines model for RebuildFailure.
+type RebuildFailure struct {
+	Channel openapi_types.UUID `json:"channel"`
+	Error   string             `json:"error"`
+}
+
+// RebuildJob defines model for RebuildJob.
+type RebuildJob struct {
+	// Changed Rockets that changed, or would change in a dry run
+	Changed int `json:"changed"`
+
+	// Done Rockets rebuilt so far, failed ones included
+	Done   int  `json:"done"`
+	DryRun bool `json:"dryRun"`
+
+	// Error Why the rebuild stopped
+	Error      *string            `json:"error,omitempty"`
+	Failed     int                `json:"failed"`
+	Failures   []RebuildFailure   `json:"failures"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Id         openapi_types.UUID `json:"id"`
+
+	// Rockets Rockets that changed so far
+	Rockets   []RocketRebuild `json:"rockets"`
+	StartedAt time.Time       `json:"startedAt"`
+
+	// Status running: rockets are being rebuilt. finished: every rocket was rebuilt, or failed. stopped: the rebuild
+	// could not go through the fleet, see error.
+	Status RebuildJobStatus `json:"status"`
+
+	// Total Rockets to rebuild
+	Total int `json:"total"`
+}
+
+// RebuildJobStatus running: rockets are being rebuilt. finished: every rocket was rebuilt, or failed. stopped: the rebuild
+// could not go through the fleet, see error.
+type RebuildJobStatus string
+
+// RebuildRequest defines model for RebuildRequest.
+type RebuildRequest struct {
+	// Concurrency How many rockets are rebuilt at once
+	Concurrency *int `json:"concurrency,omitempty"`
+
+	// DryRun Only report the rockets that would change, without storing them
+	DryRun *bool `json:"dryRun,omitempty"`
+}
+
 // Rocket defines model for Rocket.
 type Rocket struct {
 	// Channel Unique channel ID for the rocket
 	Channel openapi_types.UUID `json:"channel"`
 
+	// Distance Estimate of the distance travelled, adding up for each message the speed held until it times the seconds
+	// since the latest message
+	Distance float64 `json:"distance"`
+
 	// ExplosionReason Reason for explosion (if status is exploded)
 	ExplosionReason *string `json:"explosionReason,omitempty"`
 
+	// FlightDuration Seconds from the launch to the latest message applied. It doesn't go back when a message was sent before
+	// an earlier one, and doesn't grow with the clock while no message comes.
+	FlightDuration float64 `json:"flightDuration"`
+
+	// InSync Whether every received message has been applied
+	InSync bool `json:"inSync"`
+
 	// LastMessageNumber Last processed message number
 	LastMessageNumber *int `json:"lastMessageNumber,omitempty"`
 
 	// LastMessageTime Time of last processed message
 	LastMessageTime *time.Time `json:"lastMessageTime,omitempty"`
 
+	// LaunchTime Time of the launch message
+	LaunchTime *time.Time `json:"launchTime,omitempty"`
+
 	// Mission Current mission name
 	Mission string `json:"mission"`
 
+	// MissionChanges Number of mission changes since the launch
+	MissionChanges int `json:"missionChanges"`
+
+	// PeakSpeed Highest speed the rocket reached
+	PeakSpeed int `json:"peakSpeed"`
+
+	// PendingMessages Messages received but waiting behind a missing message number
+	PendingMessages int `json:"pendingMessages"`
+
+	// SkippedMessages Message numbers given up on by the gap policy, lowest first
+	SkippedMessages *[]MessageRange `json:"skippedMessages,omitempty"`
+
 	// Speed Current speed of the rocket
 	Speed int `json:"speed"`
 
 	// Status Current status of the rocket
 	Status RocketStatus `json:"status"`
 
+	// TotalAcceleration Sum of the speed increases applied, decreases don't take from it
+	TotalAcceleration int `json:"totalAcceleration"`
+
 	// Type Type of rocket
 	Type string `json:"type"`
 }
@@ -102,6 +300,40 @@ type Rocket struct {
 // RocketStatus Current status of the rocket
 type RocketStatus string
 
+// RocketEvent defines model for RocketEvent.
+type RocketEvent struct {
+	// LastRedeliveredAt When the message was last received again, set on duplicate events
+	LastRedeliveredAt *time.Time `json:"lastRedeliveredAt,omitempty"`
+
+	// Message Payload of the message, as received
+	Message       map[string]interface{} `json:"message"`
+	MessageNumber int                    `json:"messageNumber"`
+	MessageTime   time.Time              `json:"messageTime"`
+	MessageType   string                 `json:"messageType"`
+
+	// Redeliveries How many times the message was received again, set on duplicate events
+	Redeliveries *int `json:"redeliveries,omitempty"`
+
+	// Status applied: part of the current rocket state. pending: waiting behind a missing message number.
+	// duplicate: the message was received again after being stored, it follows the event of the stored message.
+	Status RocketEventStatus `json:"status"`
+}
+
+// RocketEventStatus applied: part of the current rocket state. pending: waiting behind a missing message number.
+// duplicate: the message was received again after being stored, it follows the event of the stored message.
+type RocketEventStatus string
+
+// RocketEventsPage defines model for RocketEventsPage.
+type RocketEventsPage struct {
+	Events []RocketEvent `json:"events"`
+
+	// NextOffset Offset of the next page, absent on the last page
+	NextOffset *int `json:"nextOffset,omitempty"`
+
+	// Total Number of events matching the filters
+	Total int `json:"total"`
+}
+
 // RocketExplodedPayload defines model for RocketExplodedPayload.
 type RocketExplodedPayload struct {
 	// Reason Reason for explosion
@@ -137,6 +369,26 @@ type RocketMissionChangedPayload struct {
 	NewMission string `json:"newMission"`
 }
 
+// RocketPage defines model for RocketPage.
+type RocketPage struct {
+	// NextCursor Cursor of the next page, absent on the last page
+	NextCursor *string  `json:"nextCursor,omitempty"`
+	Rockets    []Rocket `json:"rockets"`
+
+	// Total Number of rockets in the fleet
+	Total int `json:"total"`
+}
+
+// RocketRebuild defines model for RocketRebuild.
+type RocketRebuild struct {
+	After  Rocket `json:"after"`
+	Before Rocket `json:"before"`
+
+	// Changes Fields the replay changed, empty when the stored rocket was right
+	Changes []string           `json:"changes"`
+	Channel openapi_types.UUID `json:"channel"`
+}
+
 // RocketSpeedDecreasedPayload defines model for RocketSpeedDecreasedPayload.
 type RocketSpeedDecreasedPayload struct {
 	// By Amount by which speed decreased
@@ -149,24 +401,290 @@ type RocketSpeedIncreasedPayload struct {
 	By int `json:"by"`
 }
 
+// RocketTimeseries defines model for RocketTimeseries.
+type RocketTimeseries struct {
+	// Bucket Length of the buckets, only with `bucket`
+	Bucket *string `json:"bucket,omitempty"`
+
+	// Buckets Buckets of the points, oldest first, only with `bucket`
+	Buckets *[]TimeseriesBucket `json:"buckets,omitempty"`
+	Channel openapi_types.UUID  `json:"channel"`
+	Field   string              `json:"field"`
+
+	// Points Value after each applied message, oldest first, only without `bucket`
+	Points *[]TimeseriesPoint `json:"points,omitempty"`
+}
+
+// StatsCount defines model for StatsCount.
+type StatsCount struct {
+	Count int `json:"count"`
+
+	// Key Value of the field, empty for rockets without one
+	Key string `json:"key"`
+}
+
+// StatsGroup defines model for StatsGroup.
+type StatsGroup struct {
+	// Active Rockets of the group active in the last `activeWithin` minutes
+	Active       int     `json:"active"`
+	AverageSpeed float64 `json:"averageSpeed"`
+	Count        int     `json:"count"`
+
+	// Key Value of the field, empty for rockets without one
+	Key      string `json:"key"`
+	MaxSpeed int    `json:"maxSpeed"`
+	MinSpeed int    `json:"minSpeed"`
+}
+
+// TimeseriesBucket defines model for TimeseriesBucket.
+type TimeseriesBucket struct {
+	// Last Value at the end of the bucket
+	Last int `json:"last"`
+	Max  int `json:"max"`
+
+	// Messages Applied messages sent in the bucket
+	Messages int `json:"messages"`
+	Min      int `json:"min"`
+
+	// Mission Mission held at the end of the bucket
+	Mission string    `json:"mission"`
+	Start   time.Time `json:"start"`
+}
+
+// TimeseriesPoint defines model for TimeseriesPoint.
+type TimeseriesPoint struct {
+	MessageNumber int    `json:"messageNumber"`
+	Mission       string `json:"mission"`
+
+	// Time Time the message was sent, or of the message before it when it was sent earlier
+	Time  time.Time `json:"time"`
+	Value int       `json:"value"`
+}
+
+// Webhook defines model for Webhook.
+type Webhook struct {
+	CreatedAt time.Time            `json:"createdAt"`
+	Events    []LifecycleEventType `json:"events"`
+	Id        openapi_types.UUID   `json:"id"`
+
+	// Secret Key of the signatures, only returned when the webhook is created
+	Secret *string `json:"secret,omitempty"`
+	Url    string  `json:"url"`
+}
+
+// WebhookDelivery defines model for WebhookDelivery.
+type WebhookDelivery struct {
+	Attempts int `json:"attempts"`
+
+	// Error Why the last attempt failed, empty once redelivered
+	Error string `json:"error"`
+
+	// Event Body of a webhook delivery
+	Event    LifecycleEvent `json:"event"`
+	FailedAt time.Time      `json:"failedAt"`
+
+	// Id Sent as X-Rockets-Delivery, the same on every attempt
+	Id        openapi_types.UUID `json:"id"`
+	WebhookId openapi_types.UUID `json:"webhookId"`
+}
+
+// WebhookRequest defines model for WebhookRequest.
+type WebhookRequest struct {
+	Events []LifecycleEventType `json:"events"`
+
+	// Secret Key of the signatures, a random one is generated when missing
+	Secret *string `json:"secret,omitempty"`
+
+	// Url Absolute http or https URL the events are POSTed to
+	Url string `json:"url"`
+}
+
+// RebuildRocketParams defines parameters for RebuildRocket.
+type RebuildRocketParams struct {
+	// DryRun Only report what would change, without storing the rocket
+	DryRun *bool `form:"dryRun,omitempty" json:"dryRun,omitempty"`
+}
+
+// ListFailedDeliveriesParams defines parameters for ListFailedDeliveries.
+type ListFailedDeliveriesParams struct {
+	// Webhook Only the deliveries of this webhook
+	Webhook *openapi_types.UUID `form:"webhook,omitempty" json:"webhook,omitempty"`
+}
+
+// PostMessagesBatchJSONBody defines parameters for PostMessagesBatch.
+type PostMessagesBatchJSONBody = []RocketMessage
+
 // ListRocketsParams defines parameters for ListRockets.
 type ListRocketsParams struct {
-	// SortBy Field to sort by
-	SortBy *ListRocketsParamsSortBy `form:"sortBy,omitempty" json:"sortBy,omitempty"`
-
-	// Order Sort order
+	// SortBy Comma-separated fields to sort by, each one breaking the ties of the previous ones, for example
+	// `status,-speed,lastMessageTime`. A leading `-` sorts a field in descending order. The fields are `type`,
+	// `speed`, `mission`, `status`, `lastMessageTime`, `lastMessageNumber`, `channel`, `peakSpeed`,
+	// `totalAcceleration`, `missionChanges`, `launchTime`, `flightDuration` and `distance`; rockets without a
+	// value sort first. The channel always breaks the last ties.
+	SortBy *string `form:"sortBy,omitempty" json:"sortBy,omitempty"`
+
+	// Order Sort order, `desc` reverses every field of `sortBy`
 	Order *ListRocketsParamsOrder `form:"order,omitempty" json:"order,omitempty"`
-}
 
-// ListRocketsParamsSortBy defines parameters for ListRockets.
-type ListRocketsParamsSortBy string
+	// AsOf Return the fleet as it was at this instant, replaying for each rocket the messages sent until then without
+	// gaps. Rockets not launched by then are left out. Answered with 410 when the log of a rocket launched by
+	// then was compacted past it.
+	AsOf *time.Time `form:"asOf,omitempty" json:"asOf,omitempty"`
+
+	// Status Only return rockets with this status, `active` or `exploded`
+	Status *string `form:"status,omitempty" json:"status,omitempty"`
+
+	// Type Only return rockets of this type
+	Type *string `form:"type,omitempty" json:"type,omitempty"`
+
+	// Mission Only return rockets on this mission
+	Mission *string `form:"mission,omitempty" json:"mission,omitempty"`
+
+	// MissionPrefix Only return rockets whose mission starts with this text, case sensitive
+	MissionPrefix *string `form:"missionPrefix,omitempty" json:"missionPrefix,omitempty"`
+
+	// MinSpeed Only return rockets at this speed or faster
+	MinSpeed *int `form:"minSpeed,omitempty" json:"minSpeed,omitempty"`
+
+	// MaxSpeed Only return rockets at this speed or slower
+	MaxSpeed *int `form:"maxSpeed,omitempty" json:"maxSpeed,omitempty"`
+
+	// LastMessageFrom Only return rockets whose last applied message was sent at or after this time
+	LastMessageFrom *time.Time `form:"lastMessageFrom,omitempty" json:"lastMessageFrom,omitempty"`
+
+	// LastMessageTo Only return rockets whose last applied message was sent at or before this time
+	LastMessageTo *time.Time `form:"lastMessageTo,omitempty" json:"lastMessageTo,omitempty"`
+
+	// MinPeakSpeed Only return rockets that reached this speed or faster
+	MinPeakSpeed *int `form:"minPeakSpeed,omitempty" json:"minPeakSpeed,omitempty"`
+
+	// MaxPeakSpeed Only return rockets that never went faster than this speed
+	MaxPeakSpeed *int `form:"maxPeakSpeed,omitempty" json:"maxPeakSpeed,omitempty"`
+
+	// MinTotalAcceleration Only return rockets whose speed increases add up to this or more
+	MinTotalAcceleration *int `form:"minTotalAcceleration,omitempty" json:"minTotalAcceleration,omitempty"`
+
+	// MaxTotalAcceleration Only return rockets whose speed increases add up to this or less
+	MaxTotalAcceleration *int `form:"maxTotalAcceleration,omitempty" json:"maxTotalAcceleration,omitempty"`
+
+	// MinMissionChanges Only return rockets that changed mission at least this many times
+	MinMissionChanges *int `form:"minMissionChanges,omitempty" json:"minMissionChanges,omitempty"`
+
+	// MaxMissionChanges Only return rockets that changed mission at most this many times
+	MaxMissionChanges *int `form:"maxMissionChanges,omitempty" json:"maxMissionChanges,omitempty"`
+
+	// LaunchedFrom Only return rockets launched at or after this time
+	LaunchedFrom *time.Time `form:"launchedFrom,omitempty" json:"launchedFrom,omitempty"`
+
+	// LaunchedTo Only return rockets launched at or before this time
+	LaunchedTo *time.Time `form:"launchedTo,omitempty" json:"launchedTo,omitempty"`
+
+	// MinFlightDuration Only return rockets whose latest message was sent at least this many seconds after the launch
+	MinFlightDuration *float64 `form:"minFlightDuration,omitempty" json:"minFlightDuration,omitempty"`
+
+	// MaxFlightDuration Only return rockets whose latest message was sent at most this many seconds after the launch
+	MaxFlightDuration *float64 `form:"maxFlightDuration,omitempty" json:"maxFlightDuration,omitempty"`
+
+	// MinDistance Only return rockets that travelled this distance or more
+	MinDistance *float64 `form:"minDistance,omitempty" json:"minDistance,omitempty"`
+
+	// MaxDistance Only return rockets that travelled this distance or less
+	MaxDistance *float64 `form:"maxDistance,omitempty" json:"maxDistance,omitempty"`
+
+	// Search Only return rockets with this text, ignoring case, in their channel, type, mission or explosion reason
+	Search *string `form:"search,omitempty" json:"search,omitempty"`
+
+	// Limit Maximum number of rockets to return, every rocket when absent
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor `nextCursor` of the previous page. It only works with the `sortBy` and `order` of the request that
+	// returned it.
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
 
 // ListRocketsParamsOrder defines parameters for ListRockets.
 type ListRocketsParamsOrder string
 
+// StreamRocketsParams defines parameters for StreamRockets.
+type StreamRocketsParams struct {
+	// LastEventID ID of the last event received, to resume the stream after a reconnection
+	LastEventID *string `json:"Last-Event-ID,omitempty"`
+}
+
+// GetRocketParams defines parameters for GetRocket.
+type GetRocketParams struct {
+	// AsOf Return the state at a past point, given as a timestamp (RFC 3339) or a message number. The state replays
+	// the messages of the log without gaps up to that point. Points before the retention compacted the log are
+	// gone.
+	AsOf *string `form:"asOf,omitempty" json:"asOf,omitempty"`
+}
+
+// GetRocketEventsParams defines parameters for GetRocketEvents.
+type GetRocketEventsParams struct {
+	// MessageType Only return events of this message type, for example RocketSpeedIncreased
+	MessageType *string `form:"messageType,omitempty" json:"messageType,omitempty"`
+
+	// From Only return events sent at or after this time
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To Only return events sent at or before this time
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+
+	// Limit Maximum number of events to return
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Offset Number of matching events to skip
+	Offset *int `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
+// StreamRocketParams defines parameters for StreamRocket.
+type StreamRocketParams struct {
+	// LastEventID ID of the last event received, to resume the stream after a reconnection
+	LastEventID *string `json:"Last-Event-ID,omitempty"`
+}
+
+// GetRocketTimeseriesParams defines parameters for GetRocketTimeseries.
+type GetRocketTimeseriesParams struct {
+	// Field Field to follow, only `speed` for now
+	Field *string `form:"field,omitempty" json:"field,omitempty"`
+
+	// Bucket Length of the buckets as a duration, for example `30s`, `1m` or `1h`, at least one second
+	Bucket *string `form:"bucket,omitempty" json:"bucket,omitempty"`
+
+	// From Only return points, or buckets, at or after this time
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To Only return points, or buckets, at or before this time
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+}
+
+// GetStatsParams defines parameters for GetStats.
+type GetStatsParams struct {
+	// GroupBy Field to group the rockets by, one of `type`, `mission`, `status` or `explosionReason`
+	GroupBy *string `form:"groupBy,omitempty" json:"groupBy,omitempty"`
+
+	// ActiveWithin Minutes since the last message under which a rocket counts as active
+	ActiveWithin *int `form:"activeWithin,omitempty" json:"activeWithin,omitempty"`
+
+	// From Only aggregate rockets whose last applied message was sent at or after this time
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To Only aggregate rockets whose last applied message was sent at or before this time
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+}
+
+// StartRebuildJSONRequestBody defines body for StartRebuild for application/json ContentType.
+type StartRebuildJSONRequestBody = RebuildRequest
+
+// CreateWebhookJSONRequestBody defines body for CreateWebhook for application/json ContentType.
+type CreateWebhookJSONRequestBody = WebhookRequest
+
 // PostMessageJSONRequestBody defines body for PostMessage for application/json ContentType.
 type PostMessageJSONRequestBody = RocketMessage
 
+// PostMessagesBatchJSONRequestBody defines body for PostMessagesBatch for application/json ContentType.
+type PostMessagesBatchJSONRequestBody = PostMessagesBatchJSONBody
+
 // AsRocketLaunchedPayload returns the union data inside the RocketMessage_Message as a RocketLaunchedPayload
 func (t RocketMessage_Message) AsRocketLaunchedPayload() (RocketLaunchedPayload, error) {
 	var body RocketLaunchedPayload
@@ -309,36 +827,198 @@ func (t *RocketMessage_Message) UnmarshalJSON(b []byte) error {
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// Rebuild every rocket
+	// (POST /admin/rebuilds)
+	StartRebuild(w http.ResponseWriter, r *http.Request)
+	// Get the progress of a fleet rebuild
+	// (GET /admin/rebuilds/{id})
+	GetRebuild(w http.ResponseWriter, r *http.Request, id openapi_types.UUID)
+	// Rebuild a rocket from its message log
+	// (POST /admin/rockets/{channel}/rebuild)
+	RebuildRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params RebuildRocketParams)
+	// List the webhooks
+	// (GET /admin/webhooks)
+	ListWebhooks(w http.ResponseWriter, r *http.Request)
+	// Subscribe a webhook to rocket lifecycle events
+	// (POST /admin/webhooks)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	// List the deliveries that failed every attempt
+	// (GET /admin/webhooks/deliveries)
+	ListFailedDeliveries(w http.ResponseWriter, r *http.Request, params ListFailedDeliveriesParams)
+	// Get a failed delivery
+	// (GET /admin/webhooks/deliveries/{id})
+	GetFailedDelivery(w http.ResponseWriter, r *http.Request, id openapi_types.UUID)
+	// Redeliver a failed delivery
+	// (POST /admin/webhooks/deliveries/{id}/redeliver)
+	RedeliverWebhook(w http.ResponseWriter, r *http.Request, id openapi_types.UUID)
+	// Delete a webhook
+	// (DELETE /admin/webhooks/{id})
+	DeleteWebhook(w http.ResponseWriter, r *http.Request, id openapi_types.UUID)
+	// List the gaps of every rocket
+	// (GET /gaps)
+	ListGaps(w http.ResponseWriter, r *http.Request)
 	// Receive rocket state messages
 	// (POST /messages)
 	PostMessage(w http.ResponseWriter, r *http.Request)
+	// Receive a batch of rocket state messages
+	// (POST /messages/batch)
+	PostMessagesBatch(w http.ResponseWriter, r *http.Request)
 	// List all rockets
 	// (GET /rockets)
 	ListRockets(w http.ResponseWriter, r *http.Request, params ListRocketsParams)
+	// Stream rocket updates
+	// (GET /rockets/stream)
+	StreamRockets(w http.ResponseWriter, r *http.Request, params StreamRocketsParams)
+	// Subscribe to rocket changes over a WebSocket
+	// (GET /rockets/ws)
+	SubscribeRockets(w http.ResponseWriter, r *http.Request)
 	// Get rocket by channel
 	// (GET /rockets/{channel})
-	GetRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID)
+	GetRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketParams)
+	// Get the event history of a rocket
+	// (GET /rockets/{channel}/events)
+	GetRocketEvents(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketEventsParams)
+	// Get the gaps of a rocket
+	// (GET /rockets/{channel}/gaps)
+	GetRocketGaps(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID)
+	// Stream the updates of a rocket
+	// (GET /rockets/{channel}/stream)
+	StreamRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params StreamRocketParams)
+	// Get the time-series of a rocket field
+	// (GET /rockets/{channel}/timeseries)
+	GetRocketTimeseries(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketTimeseriesParams)
+	// Statistics of the fleet
+	// (GET /stats)
+	GetStats(w http.ResponseWriter, r *http.Request, params GetStatsParams)
 }
 
 // Unimplemented server implementation that returns http.StatusNotImplemented for each endpoint.
 
 type Unimplemented struct{}
 
+// Rebuild every rocket
+// (POST /admin/rebuilds)
+func (_ Unimplemented) StartRebuild(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Get the progress of a fleet rebuild
+// (GET /admin/rebuilds/{id})
+func (_ Unimplemented) GetRebuild(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Rebuild a rocket from its message log
+// (POST /admin/rockets/{channel}/rebuild)
+func (_ Unimplemented) RebuildRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params RebuildRocketParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// List the webhooks
+// (GET /admin/webhooks)
+func (_ Unimplemented) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Subscribe a webhook to rocket lifecycle events
+// (POST /admin/webhooks)
+func (_ Unimplemented) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// List the deliveries that failed every attempt
+// (GET /admin/webhooks/deliveries)
+func (_ Unimplemented) ListFailedDeliveries(w http.ResponseWriter, r *http.Request, params ListFailedDeliveriesParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Get a failed delivery
+// (GET /admin/webhooks/deliveries/{id})
+func (_ Unimplemented) GetFailedDelivery(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Redeliver a failed delivery
+// (POST /admin/webhooks/deliveries/{id}/redeliver)
+func (_ Unimplemented) RedeliverWebhook(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Delete a webhook
+// (DELETE /admin/webhooks/{id})
+func (_ Unimplemented) DeleteWebhook(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// List the gaps of every rocket
+// (GET /gaps)
+func (_ Unimplemented) ListGaps(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // Receive rocket state messages
 // (POST /messages)
 func (_ Unimplemented) PostMessage(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
+// Receive a batch of rocket state messages
+// (POST /messages/batch)
+func (_ Unimplemented) PostMessagesBatch(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // List all rockets
 // (GET /rockets)
 func (_ Unimplemented) ListRockets(w http.ResponseWriter, r *http.Request, params ListRocketsParams) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
+// Stream rocket updates
+// (GET /rockets/stream)
+func (_ Unimplemented) StreamRockets(w http.ResponseWriter, r *http.Request, params StreamRocketsParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Subscribe to rocket changes over a WebSocket
+// (GET /rockets/ws)
+func (_ Unimplemented) SubscribeRockets(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
 // Get rocket by channel
 // (GET /rockets/{channel})
-func (_ Unimplemented) GetRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID) {
+func (_ Unimplemented) GetRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Get the event history of a rocket
+// (GET /rockets/{channel}/events)
+func (_ Unimplemented) GetRocketEvents(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketEventsParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Get the gaps of a rocket
+// (GET /rockets/{channel}/gaps)
+func (_ Unimplemented) GetRocketGaps(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Stream the updates of a rocket
+// (GET /rockets/{channel}/stream)
+func (_ Unimplemented) StreamRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params StreamRocketParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Get the time-series of a rocket field
+// (GET /rockets/{channel}/timeseries)
+func (_ Unimplemented) GetRocketTimeseries(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketTimeseriesParams) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Statistics of the fleet
+// (GET /stats)
+func (_ Unimplemented) GetStats(w http.ResponseWriter, r *http.Request, params GetStatsParams) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
@@ -351,11 +1031,17 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(http.Handler) http.Handler
 
-// PostMessage operation middleware
-func (siw *ServerInterfaceWrapper) PostMessage(w http.ResponseWriter, r *http.Request) {
+// StartRebuild operation middleware
+func (siw *ServerInterfaceWrapper) StartRebuild(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.PostMessage(w, r)
+		siw.Handler.StartRebuild(w, r)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -365,32 +1051,28 @@ func (siw *ServerInterfaceWrapper) PostMessage(w http.ResponseWriter, r *http.Re
 	handler.ServeHTTP(w, r)
 }
 
-// ListRockets operation middleware
-func (siw *ServerInterfaceWrapper) ListRockets(w http.ResponseWriter, r *http.Request) {
+// GetRebuild operation middleware
+func (siw *ServerInterfaceWrapper) GetRebuild(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
-	// Parameter object where we will unmarshal all parameters from the context
-	var params ListRocketsParams
-
-	// ------------- Optional query parameter "sortBy" -------------
+	// ------------- Path parameter "id" -------------
+	var id openapi_types.UUID
 
-	err = runtime.BindQueryParameter("form", true, false, "sortBy", r.URL.Query(), &params.SortBy)
+	err = runtime.BindStyledParameterWithOptions("simple", "id", chi.URLParam(r, "id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
 	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "sortBy", Err: err})
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
 		return
 	}
 
-	// ------------- Optional query parameter "order" -------------
+	ctx := r.Context()
 
-	err = runtime.BindQueryParameter("form", true, false, "order", r.URL.Query(), &params.Order)
-	if err != nil {
-		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "order", Err: err})
-		return
-	}
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
 
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.ListRockets(w, r, params)
+		siw.Handler.GetRebuild(w, r, id)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -400,8 +1082,8 @@ func (siw *ServerInterfaceWrapper) ListRockets(w http.ResponseWriter, r *http.Re
 	handler.ServeHTTP(w, r)
 }
 
-// GetRocket operation middleware
-func (siw *ServerInterfaceWrapper) GetRocket(w http.ResponseWriter, r *http.Request) {
+// RebuildRocket operation middleware
+func (siw *ServerInterfaceWrapper) RebuildRocket(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 
@@ -414,8 +1096,25 @@ func (siw *ServerInterfaceWrapper) GetRocket(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params RebuildRocketParams
+
+	// ------------- Optional query parameter "dryRun" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "dryRun", r.URL.Query(), &params.DryRun)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "dryRun", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.GetRocket(w, r, channel)
+		siw.Handler.RebuildRocket(w, r, channel, params)
 	}))
 
 	for _, middleware := range siw.HandlerMiddlewares {
@@ -425,8 +1124,786 @@ func (siw *ServerInterfaceWrapper) GetRocket(w http.ResponseWriter, r *http.Requ
 	handler.ServeHTTP(w, r)
 }
 
-type UnescapedCookieParamError struct {
-	ParamName string
+// ListWebhooks operation middleware
+func (siw *ServerInterfaceWrapper) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListWebhooks(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// CreateWebhook operation middleware
+func (siw *ServerInterfaceWrapper) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateWebhook(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListFailedDeliveries operation middleware
+func (siw *ServerInterfaceWrapper) ListFailedDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListFailedDeliveriesParams
+
+	// ------------- Optional query parameter "webhook" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "webhook", r.URL.Query(), &params.Webhook)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "webhook", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListFailedDeliveries(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetFailedDelivery operation middleware
+func (siw *ServerInterfaceWrapper) GetFailedDelivery(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", chi.URLParam(r, "id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetFailedDelivery(w, r, id)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// RedeliverWebhook operation middleware
+func (siw *ServerInterfaceWrapper) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", chi.URLParam(r, "id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.RedeliverWebhook(w, r, id)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// DeleteWebhook operation middleware
+func (siw *ServerInterfaceWrapper) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", chi.URLParam(r, "id"), &id, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	ctx := r.Context()
+
+	ctx = context.WithValue(ctx, AdminTokenScopes, []string{})
+
+	r = r.WithContext(ctx)
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.DeleteWebhook(w, r, id)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListGaps operation middleware
+func (siw *ServerInterfaceWrapper) ListGaps(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListGaps(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostMessage operation middleware
+func (siw *ServerInterfaceWrapper) PostMessage(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostMessage(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// PostMessagesBatch operation middleware
+func (siw *ServerInterfaceWrapper) PostMessagesBatch(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.PostMessagesBatch(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListRockets operation middleware
+func (siw *ServerInterfaceWrapper) ListRockets(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListRocketsParams
+
+	// ------------- Optional query parameter "sortBy" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "sortBy", r.URL.Query(), &params.SortBy)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "sortBy", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "order" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "order", r.URL.Query(), &params.Order)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "order", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "asOf" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "asOf", r.URL.Query(), &params.AsOf)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "asOf", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", r.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "status", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "type" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "type", r.URL.Query(), &params.Type)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "type", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "mission" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "mission", r.URL.Query(), &params.Mission)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "mission", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "missionPrefix" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "missionPrefix", r.URL.Query(), &params.MissionPrefix)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "missionPrefix", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minSpeed" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minSpeed", r.URL.Query(), &params.MinSpeed)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minSpeed", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "maxSpeed" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "maxSpeed", r.URL.Query(), &params.MaxSpeed)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "maxSpeed", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "lastMessageFrom" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "lastMessageFrom", r.URL.Query(), &params.LastMessageFrom)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "lastMessageFrom", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "lastMessageTo" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "lastMessageTo", r.URL.Query(), &params.LastMessageTo)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "lastMessageTo", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minPeakSpeed" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minPeakSpeed", r.URL.Query(), &params.MinPeakSpeed)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minPeakSpeed", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "maxPeakSpeed" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "maxPeakSpeed", r.URL.Query(), &params.MaxPeakSpeed)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "maxPeakSpeed", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minTotalAcceleration" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minTotalAcceleration", r.URL.Query(), &params.MinTotalAcceleration)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minTotalAcceleration", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "maxTotalAcceleration" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "maxTotalAcceleration", r.URL.Query(), &params.MaxTotalAcceleration)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "maxTotalAcceleration", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minMissionChanges" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minMissionChanges", r.URL.Query(), &params.MinMissionChanges)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minMissionChanges", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "maxMissionChanges" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "maxMissionChanges", r.URL.Query(), &params.MaxMissionChanges)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "maxMissionChanges", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "launchedFrom" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "launchedFrom", r.URL.Query(), &params.LaunchedFrom)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "launchedFrom", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "launchedTo" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "launchedTo", r.URL.Query(), &params.LaunchedTo)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "launchedTo", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minFlightDuration" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minFlightDuration", r.URL.Query(), &params.MinFlightDuration)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minFlightDuration", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "maxFlightDuration" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "maxFlightDuration", r.URL.Query(), &params.MaxFlightDuration)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "maxFlightDuration", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "minDistance" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "minDistance", r.URL.Query(), &params.MinDistance)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "minDistance", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "maxDistance" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "maxDistance", r.URL.Query(), &params.MaxDistance)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "maxDistance", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "search" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "search", r.URL.Query(), &params.Search)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "search", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListRockets(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// StreamRockets operation middleware
+func (siw *ServerInterfaceWrapper) StreamRockets(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params StreamRocketsParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Last-Event-ID" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Last-Event-ID")]; found {
+		var LastEventID string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Last-Event-ID", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Last-Event-ID", valueList[0], &LastEventID, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Last-Event-ID", Err: err})
+			return
+		}
+
+		params.LastEventID = &LastEventID
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.StreamRockets(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// SubscribeRockets operation middleware
+func (siw *ServerInterfaceWrapper) SubscribeRockets(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.SubscribeRockets(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetRocket operation middleware
+func (siw *ServerInterfaceWrapper) GetRocket(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "channel" -------------
+	var channel openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "channel", chi.URLParam(r, "channel"), &channel, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "channel", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetRocketParams
+
+	// ------------- Optional query parameter "asOf" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "asOf", r.URL.Query(), &params.AsOf)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "asOf", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetRocket(w, r, channel, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetRocketEvents operation middleware
+func (siw *ServerInterfaceWrapper) GetRocketEvents(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "channel" -------------
+	var channel openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "channel", chi.URLParam(r, "channel"), &channel, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "channel", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetRocketEventsParams
+
+	// ------------- Optional query parameter "messageType" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "messageType", r.URL.Query(), &params.MessageType)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "messageType", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", r.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "from", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", r.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "to", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "offset" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "offset", r.URL.Query(), &params.Offset)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "offset", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetRocketEvents(w, r, channel, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetRocketGaps operation middleware
+func (siw *ServerInterfaceWrapper) GetRocketGaps(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "channel" -------------
+	var channel openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "channel", chi.URLParam(r, "channel"), &channel, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "channel", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetRocketGaps(w, r, channel)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// StreamRocket operation middleware
+func (siw *ServerInterfaceWrapper) StreamRocket(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "channel" -------------
+	var channel openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "channel", chi.URLParam(r, "channel"), &channel, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "channel", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params StreamRocketParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Last-Event-ID" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Last-Event-ID")]; found {
+		var LastEventID string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Last-Event-ID", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Last-Event-ID", valueList[0], &LastEventID, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Last-Event-ID", Err: err})
+			return
+		}
+
+		params.LastEventID = &LastEventID
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.StreamRocket(w, r, channel, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetRocketTimeseries operation middleware
+func (siw *ServerInterfaceWrapper) GetRocketTimeseries(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "channel" -------------
+	var channel openapi_types.UUID
+
+	err = runtime.BindStyledParameterWithOptions("simple", "channel", chi.URLParam(r, "channel"), &channel, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationPath, Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "channel", Err: err})
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetRocketTimeseriesParams
+
+	// ------------- Optional query parameter "field" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "field", r.URL.Query(), &params.Field)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "field", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "bucket" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "bucket", r.URL.Query(), &params.Bucket)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "bucket", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", r.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "from", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", r.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "to", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetRocketTimeseries(w, r, channel, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetStats operation middleware
+func (siw *ServerInterfaceWrapper) GetStats(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetStatsParams
+
+	// ------------- Optional query parameter "groupBy" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "groupBy", r.URL.Query(), &params.GroupBy)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "groupBy", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "activeWithin" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "activeWithin", r.URL.Query(), &params.ActiveWithin)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "activeWithin", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", r.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "from", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", r.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "to", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetStats(w, r, params)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+type UnescapedCookieParamError struct {
+	ParamName string
 	Err       error
 }
 
@@ -538,15 +2015,69 @@ func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handl
 		ErrorHandlerFunc:   options.ErrorHandlerFunc,
 	}
 
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/admin/rebuilds", wrapper.StartRebuild)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/admin/rebuilds/{id}", wrapper.GetRebuild)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/admin/rockets/{channel}/rebuild", wrapper.RebuildRocket)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/admin/webhooks", wrapper.ListWebhooks)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/admin/webhooks", wrapper.CreateWebhook)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/admin/webhooks/deliveries", wrapper.ListFailedDeliveries)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/admin/webhooks/deliveries/{id}", wrapper.GetFailedDelivery)
+	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/admin/webhooks/deliveries/{id}/redeliver", wrapper.RedeliverWebhook)
+	})
+	r.Group(func(r chi.Router) {
+		r.Delete(options.BaseURL+"/admin/webhooks/{id}", wrapper.DeleteWebhook)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/gaps", wrapper.ListGaps)
+	})
 	r.Group(func(r chi.Router) {
 		r.Post(options.BaseURL+"/messages", wrapper.PostMessage)
 	})
+	r.Group(func(r chi.Router) {
+		r.Post(options.BaseURL+"/messages/batch", wrapper.PostMessagesBatch)
+	})
 	r.Group(func(r chi.Router) {
 		r.Get(options.BaseURL+"/rockets", wrapper.ListRockets)
 	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/rockets/stream", wrapper.StreamRockets)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/rockets/ws", wrapper.SubscribeRockets)
+	})
 	r.Group(func(r chi.Router) {
 		r.Get(options.BaseURL+"/rockets/{channel}", wrapper.GetRocket)
 	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/rockets/{channel}/events", wrapper.GetRocketEvents)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/rockets/{channel}/gaps", wrapper.GetRocketGaps)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/rockets/{channel}/stream", wrapper.StreamRocket)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/rockets/{channel}/timeseries", wrapper.GetRocketTimeseries)
+	})
+	r.Group(func(r chi.Router) {
+		r.Get(options.BaseURL+"/stats", wrapper.GetStats)
+	})
 
 	return r
 }
@@ -554,32 +2085,145 @@ func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handl
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/8xZ328aORD+VyzfPdzpICyQNLBvaUt7SNBWQO4eqqgyuwO43bW3tpccivjfT/b+3vUG",
-	"mqZVpT4Erz0ef/N55hv3AXs8jDgDpiR2H7D0dhAS8+dECC70H5HgEQhFwQxDNuyD9ASNFOUMu8lsFIKU",
-	"ZAu4g9UhAuxiqQRlW3w8drCArzEV4GP3Y2rkLp/G15/BU/jYwfPEwhwU8Ykizf29HWEMgqYHt4x+jQGl",
-	"39H0NdpwgdQOkODeF1C4gzdchERhF8cx9ZtOdnDq/7s4XIPlkO+FDwLxjbGazkX3VO0oQyTbubBLmYIt",
-	"iJLhFQ2hafbfHbCqSSKRBFZx2ScKukqvb/d7ZcYfMLA41DAvzMFnJGbeDvSBk4FlBOBPmSeAyPrwa6gP",
-	"T/6LAu6XBuZUSsrZqx1hW/BLUWwJdoFLFd4qKtVD2KiR7P6TGQH69Pq4CyBSW61vkowby/lc9AfdIKmI",
-	"iiWiMhn3wf8Ta3skjAK9x4fFZLm8XUw+/TNZLiezT29uprPbxcTmRECkmj9OzRmRCkWCeyAl+DmTWAZ1",
-	"k5Ilo3Za6lFN9sBq+nxuJnRp2n8VCwFMoXQCYsQYKBC6Wawm8+nSZlRqrrabNJ+zi5oHO7c8vHIcGyRJ",
-	"yB4xm4S0YTe9bsRTdJ8cIb0ydxbXVXpJa2AfIgN201n8hgQeZ90xPv+imXkZSkUI8hO2X6/stn8gh4AT",
-	"v3nbxDfcg6fxvXaqdMN2l7P81upyYCYs7ZSZMqooCVAyCWWQ5X63UKWV1fMnsPnHUSIlQhmBwvd2SNO8",
-	"0IQyLD5wBu832P34gH8XsMEu/q1XaIleKiR69hAdO+esqtapJ6zNi9m3ra3fgfNWVctivvbOlOdCzTxm",
-	"qC5+6tHM7eTF8rEYWv1pRJTB/byNy+/gvj07L/++Xa1mk0/z6eIkDUubtDtsD1nD4fWh6ehNyGOm0PqA",
-	"7nc0u8XIL2mZ3O+B9ULX/F0fTvnZoOV3+EmZxc+h81Q/9STKNtySoyfLFbr5ME10kCDeF8q2aX4xxQ0k",
-	"IszPYi51XKky0U6OLvVq3MF7EAljcP/CuXA0NjwCRiKKXTy8cC6GuIMjonYGiV5KVvMj4lJZ2gfmR5wy",
-	"he53IMCUVwWJ7NgKEiK9SlY8NbJum2tm7atGn2iDU1/XG57rG5ygBlK95L4Ji8eZ0gLbfcAkigLqmXW9",
-	"z2lpS6OQFLyqCtZNUhyGRBxyVFBJIu9JEEMtU2Yls7UE1lJELmhxfzwcXDtk3PXG3qZ76VyS7mgzGnZH",
-	"wxFc9/0xgRfXDV3tXtb6DTxwBoOuo/+t+mP3cuA6VxejF8Ph9V9O33WcmvZ268LfMEpUewkbDKVGwwZD",
-	"pQybW5gX0WZxzCvcM2PTfxyb4fg8bPKjlrCp9UU2hDJxkM2xA1VOyZU0+8xYXJ3gyfA8LGrnLiFS6yht",
-	"iJgpqNx12hDRuVQn7mcGYHgCgP55ANSOWQeg6LTbASh3420A6IrwzAAMTgDgfAMAxRGOBoFE1JySPFW9",
-	"eawWOCViMAMy4kwm6XjgOBbRnba7Ajyge/CRjD3drm7iIDjoa3OZrDqR9s9zOXkYM67WW4k9CWjRfKf9",
-	"8bGDr37O9goEIwGSIPYgEKQTO2XSJQBVK2leQvXcXvLJYL0FZWv0VCyYRAQFVCrdoJAgSA1KRJOXLHmQ",
-	"CkLzNoa4Wan94kJpdViv1DMqVaovjGwQJAQFQprmorr5GwqBjxQ3ttD6gLXUwS7+GoPQP4xKdbH++lL/",
-	"LiDNmvTzW+NCy9a9WOrNufDNy4pt/+xbsb0PGxIHCruYSK/8ZmB+afO2ne/s5D+bR7XWvYgsVRDK8+5m",
-	"0aBiIgQ5GJZYJGftPSrlRrbnr3QJjG8l0lZ433tIk+rx5A3QRPdKT0OmWyda1Ht0Q72ica/S/S2kbD9F",
-	"9ub7Zf3lyXBP6+yCesUjUDWPlsl44tXzu2l3DqmaoVuUclKSsy9/PF/STRlXaMNj5v9SRH0LKsvU60P+",
-	"/wtJdTWrbKyZcY8EyIc9BDwKDTfNXNzBsdBSYadU5PZ6gZ6341K5I2c0wse74/8BAAD//3Qru0QNGgAA",
+	"H4sIAAAAAAAC/+x9/W8bt7bgv0JoF9gWO5blOL038cP+4Js4vX4vaQPbfV1sFVxRM5TE6xlSJTm2hcL/",
+	"++IcfgxnhiPJie0m7z6gQJ3RDHl4yPP9wT9GuazWUjBh9Ojkj5HOV6yi+OffqMlX54ZVF0zXpYFHayXX",
+	"TBnO8IV8RYVgJfy5kKqiZnQyqmtejLKR2azZ6GSkjeJiObrPRlwU7A7eLJjOFV8bLsXoZPRRag5/Erkg",
+	"ZsVIxbSmS0a4wH/OAYSMaEOV4WJJqCGTZnQuDFsyBcO7736qqzlTME3/FcWolqIPwq+rTWvqW6qJkIbQ",
+	"PGdrw5Kr0YaaWvfH8t+cEG2kYgWhoiB0vS45K8h3UpHfa1az4vsxKep1yXNq2AmhpWK02BDFcsZvWDEm",
+	"uRSLkufmpAWYwMUBfFPR/YbccrMilBR8sWCKCQNjGCbMmHBxQ0tetMfKqfhfhswZKVguC1YQqchcMXqt",
+	"CSX4PoUlTYWqSzYmiv2T5Ya5Qdy+TwWuhnBNFnVZwhDwq2bqhuf4WK9qg/tWyFsxJgvKSxijFuxujeMR",
+	"ppRUWRc0AEwxozgrxlMxykZM1NXo5LdRtCcBgaNs5PE1gnOG0I+ykYd5lI3sxKNPvZ3EY/F7zRUrYHh7",
+	"SMP2Nu/LOYwFO49EMUQQCp/jn9ywCv/4n4otRiej/3HY0NmhI7LDLoXdhwmpUnTTg8+PnwLsDDDZB4n5",
+	"x+2Dim97lKdO+IKzsuh/9++XP/9E1tSsPMHKxYKJAvYYv8jIQqroANkN1qNsxO5otS5hEjfruKS1yFeX",
+	"a5aisc7K7TJS635XMmYuDTW6v3iaG37D+qu4kPk1M5rcrqRmpKTaBCKNuYAGOnKcCF+a2QF/5WbFxYxU",
+	"XNSG6SRDit/sA/DBfklqzQpiJMllLQzOY78jykKYHHq++cC15lLsfc4QPW9gjv4Rg/EuAzt7lOGu8Mmj",
+	"DMbu1qWEtSaY7Rn8BrzLIYvMNyS8Txy7zx4DiqWS9VoPn6O5lSA3tKyZJ4wZfvS3zcwThhTlxrLp8NOD",
+	"oPsRPkpBp4GC/vYZWB8c0UhDy/5yrXSFBXqM0+VSsSVtSclwUjskbAf1lDHqkEh0DMMRio96e5mtg5Hi",
+	"Cj/S9ZfpKwslqz4G3nGlDakAJrHsCOa0XmJf3YbLzmhpmpdrJt5JdclyKYrESfy7vCWlFEs8eku6Jiuq",
+	"yZwxQeDLwSEvuchZSiVilust7HrdOunCMBVmAA7p1Y9R1mC0oIYdGF4lxYqR/dne04fgtHOq/J66HcMZ",
+	"GrTHq+whceDcvOc6IdqXdL0/g4TTt0uW44ApEN7zBcs3ecnObpgwfXT9TRYbODeU3LL5SsprUrCS3zC1",
+	"GWUdoHmx11mXeV4rxYpT03p960auFbvhstaRKOqKOPwBj4tlF6g3LllBYKeAH7rn48q++sb+nJrNvrgL",
+	"7ZYdN5jf/nYbz8hWevpgAKaFpADP7u3zTLmNm58Fa7io04NYkfkHzAs2qQZR1KhTnY9S6PtgieoDM7Sg",
+	"hm7ljG1IfxH89zpo/OT8Lep3zZ6Ost3Hq2ebdZChCssIW0YYSgVCSUPfFRe8AjPgaIsBeAXHdZifdbW7",
+	"vfmWH95tp7dH7Il77zZwlLkHqNOei1wxqruP37Lu47Nm5+yDD+3N3mm2RDhqobqNlfYiUkfXHZMLmLZ/",
+	"RrxE7OPeyNTzDpANe05NfcHmNS+Ld5SXtWJfJrqDybMv1oaNCwfWv8t5GiTYnkGt0Kxo4HkZ0PKtrMvC",
+	"PSF4ugu1IapOy+dCii2mi0LIDNGSLKjKnG1NpGCacJGXdZHUybJRoTYXtYjQM5eyZFS0MJf2kNg5C6KN",
+	"XK/TnMaCkT4mC7u5+4vRzqFIKKoLLrhePUxy7SkUvQW21/a6bdhXn7dDuOWl1oUOr4cta8glpWohuFie",
+	"NDq7YmTOQNdyZ2hMPBpPCAM9wr3qNDx8B8+v3dyx3/+T+FBMRY6nW0hDlpKYlZL1cmWVyJIxkxHNmPUG",
+	"tF06Dr5Rs5nogMEZEqxv0DgJ2yI9SLuVR140zp4Y6YFKsmC1IDVmgejDSW8OSnTAt7CSC/Z7zVIKZi4F",
+	"Khgi39jFLSj6mF5mCVW/omLT2lDPDqgh0iq7Fb2z4vL4xS7Z2bCEMOuClpplPbWl3BDF1lKZSAVwlBAz",
+	"twzlt6wNukG5NUuqUdZjOfcpRAVd7xl1lIJrQ5O20Jk2vKIm2PX+TWIUvWFlCbydFugBq9c4M6P5Kmga",
+	"8AlarmTFyoLUwvCScEOAjLX91ZojU6E5DovOJsMau2sq4hUUsp6XEQdwhlLsK7kY8HTb5xZG/y75ji+I",
+	"JQHw2Hot8vuWivnx4uzy8peLs3/859nl5dn7f7w7PX//y8VZUgCUfLkyb2tFTdIscMYX2gBuraA8Adn2",
+	"V+69cmNybkghmQan9VKSOc2vyS0odbTvsJuzhVRsKqggjKqSMwVSMUNXfBhDyVvri4FJ81LieLxkRMgw",
+	"Yi4rpsf7Yp+Ly43Ik7qnWTHlOav31/tJgp3ulpogkmwEzscP27VoNKLXSuZM62j4La6JaNC01gxP4dSX",
+	"yaH3Vp3tBm+fIjoIDx2+GjJA3yA3dZ4FKYigFWud6tOLq7MP55dbBrUauN7pvZHCMT5NYiKG9SRRv2b0",
+	"2vq9+44cvlwBBVieERnPCtjKgE63ti74D96D1DfG3S/N+ZvXIN85RmfmbMUhTvUQx5a+5iChd07pxtBk",
+	"yW+YABYphfeXghdpLUuebzJSyltYNjqc9lWjWvbKkGN0+GBYFMtFhOX4fBz/MJkkVz6gaIVh8ff+uCGE",
+	"5dyfwWAfVHJO85yVbJCT1pWfxK6EO4NTe16SQXTPPSok8D1Dr5llvdwkt9Uk/RVgMTYOixYRvaNlLsXB",
+	"69H+RqpzqGgX+KkaD6/XxBr6SGGiR549qRPJ8z5xBFb9aVD5CJ63tgYCjPCCOW+b18738DTAdw3l0SXl",
+	"IiOagarWxIFBQAij9+d7dgIAAdQPmJ+WHyN4jap7GtxHuiklLTreloy0Xbk9rOwRXe/4Xz7Lq9L7XXlk",
+	"czbg80ZFuNGlYqzvj/D9adwR1glZU2U8FnNH+I5Rw7dsTNyxO9mXy46nIsoJ2L4Y54m3VpzNNMhAqVzI",
+	"spS3FhW4usAg8B0/YiemHhQPB3IruL7T+bS/yykicPd4FwnqjzTliXIbt7cboRkwJSUEuzM/LxaaJcjZ",
+	"PvdYhDfJ2hLMHHVNGQWH161Iess9tiOaZtdDKsgGcMYSWfDSMKV327DNKcZptmDUyRvHA1LZC/ubDZ9n",
+	"HvTSGXDCYZC9Y3UQ5DiFoAf3ueCG09Irll7eBLhRvgfLeDIYvtsW3XiIUvl0stVJ1HZChYd9GL0fGhHS",
+	"RmskW6RgPy9GJ7/tQ2Ld7brP9vmq7Sz/jG/fss/6tksP+33V9s2Hbz+hNGvCK3uorSEa0+eo7od9+GQa",
+	"nt6OCnY7GKn7id0OG0mXf//l6ur92T8+nF/sPIbRJMMAp1k6sNY3tdIp97N9/jlMOOnNfYDM+KLcCC4a",
+	"7+duNh4l/Ozg495t3MMhKgX7L8z6SfZ/Px8yht9Bfot2zuB1STdN0INVa7OxnppIDYndy6C3xwfuN2cX",
+	"fIpswAFO2uzJ/tGhQaMkD7aEw0vm8Dm8EWnm09uW+aaPstMKE77mgBvuZVOw1lpS6sVOMdVZ03yzC+Zz",
+	"8Xgwc5GA+XjymDCDKqmDDdABtPbO4o47jIllk6Ro39KtHCz7bJZiFu71ROqF/cEPu5Zc4KhlEXwXA3Ps",
+	"xXSahdqJvuyoRzmcvV8s5P0F/idmsFnrAt3YnbTIwbWCr//zl/sRwNmZMROl+uDCUicmyuNLRFjc476e",
+	"d802Q8hwe+2S+CxDW4TEDB1Wb+ND29kNTJM5OAaht1l5D85ldXBiaqHPIv3c3NUbpuiSBbV6D+/3n4Bb",
+	"DHEFGFO6uxj8dcu+xAmKMR6iAaOZU7vYI+OkI2mQ+GxsjYmizb2SO1XRu63umASJn7Ypup3lvG0qLobw",
+	"vMNGwsDX7mW1Qtlq78h3ZyvttxECLOQWVTboscM26rKlIetoqzeswUlvdWY4EpJKUcLIeyc7yqoo4PFB",
+	"1Yqb8LYPee3tRsSc5T1IpOvmcePZz7cj9FebpphgyIrRB2Y5PND1k8rx68vUPTNCNMtVStf4D7bxG6T5",
+	"UlBTK+Z1DcVMrQQr7EbBKz5pk2vi1p+aq1Yo4hs7bGXMWp8cHnKR8wLWOHa/jXNZHcKI+rBXNDBU5lKM",
+	"7AxZ4z1q9mLLFr71iaZ96WQMsG+dJocdeUW2AMOO4JJMvDSQImdENT73wTPxsJPQpCo9PHOoG88WBtzn",
+	"//fACeIDjyRb1aRpxcBCteFft8h9MhLcOTkvHm7V4CvN9x5DWbNLfksiLGzZ9sGclUehxoqLc/v1UZ80",
+	"H0hzlCgqClkRKbAAbckEU9R4+mvSwocIriMr51qWtWEEqA/4MPxfk18u3jcudpt/8/Hnyyss5dm5OS26",
+	"6yPdrrlW3GwuAW2OvIqKiyt5zRJi9mrFyOnbD+c//ePq5/84+ymgxZbhjTJb0glzzBlVcTgXVjO6hym5",
+	"WCQS8y/OLq/I6cdzm0+jaH6NWWNRmENjVoVj/sh4uEF25bXS04/nICWYsuJwdDSejCe++oCu+ehkdDye",
+	"jI9H2QiqynCxh7jaQ5fFhY/WMqUwXYKs9ylqmIHTSl9zcU4dxGYpl01haX4NqrIoxgTTmqRwrho/HFG1",
+	"0KC2UIwvZVMBQ62VXCqmMVdGMVo0eSyB0/9Tzm2ABSgF45HnhQf2ImSmKUtTkMzvsr+M42JocuX44eE/",
+	"nUPeUtCe+ZKeXO/v7dnTaym0PUcvJi8eezbIjcWJOmcn5IpiUh1s+cvJ5NEmt9WOiXnPbf0nUR4JMO/R",
+	"08/7wQX1IM1XQSEOHmJikGYRiNdPD8SpkJhr5I8wHFKXXnmfjX6YHD89CFdbKoBb3A1jCjFf++3T/ads",
+	"pOuqomoTnaCYpnGEDn84/IMX9wDv0gqKNtn9yCKiW1NFK2aY0jh75+S8DTkbbuZ/yjlmC4xOkDmNshG6",
+	"x09GvBjFTN1G2hvE7ZLWn3pUOXkmqvzo2Vd7pV8Rlbx8eiB+EddC3gq/eKepB0UUU+htTm3FwNphxcMO",
+	"7o/M2rrrCNm0LVxa59iKysM/nH/r3p/sYcF3gW72dsoDCLdWzhHkWoWsyiinkjfFziC80SfvvfdQXh5s",
+	"SttLQE9FkHEt/33m/ISULPgdzLSSt34Si0CfvZmQhl5U+aDnVsrsJ/h2k6sSNNp4Cz+fULM/tmRA3+6V",
+	"9dyB8ffa1uk5IEOaeQNTNwW7lzL9pNyjXZgwwN5bURw4Qxy1MDiT/1qcxKILax4WoEvixC9ePI+UjQkf",
+	"NHCmG+qLuEDIBdQSiDo0/rD7ZTWzH55HMzNMCVqiesCUrQX5PJWAblPxY97qjGA9qB5Ase+v/qUvJKy9",
+	"bGA3WSLY0UOYh8vbLBILJc2KbcgtU8y7jxq2Y1aMK2JNZv2VUOJDthc2I/aRaRuqSknAqwHzGz8H+5xq",
+	"Qknb4TAmZxDS8tXSJKdKcUcsK0YLpmI3Dn6STcVWxw7NldTa9arRWfQ5epENrdYZbN8vgt/5Qg9bidC8",
+	"een9F9lUzPSKvvjhL/9n5lL5IFF74wC8I3//cPrm4PLvpy9++IuXgKaZhpJCWnYMP8xlscnINdv43kCu",
+	"1EQxM56KU7EhVOhbpjARnJIXd3eE+4W4L9idPQOQQwX2slwscOmCrKm6jsd1xYdNsmZK5L/B4+rP/9NY",
+	"wB2H1f39fVf89y3io8eefQstNzSLBG21CQuNxSbwMrtLz24xA9VI5ajqG+Qel/UcBp6zqDOCkV5SlJ4V",
+	"hAX2pcRhO9t4UGC8w+P+tnl5h/76s7cvmvEt/XLtIR3QD5tfn8+6fIgYCwGBPcTZuy6TcETgEgps7aQr",
+	"APmGZVe0x1gX6Xhj2/+//fDt9Gi0DuCmf/y+LZdF7yyl9d22kNn8a/osiqCDSBU1DmziYw/2VNA+Yncf",
+	"zsMw5RbvPBMuK9CPbKN5lVQss4l/hN7SzZickgA/+uFLRm+cXrboMw10cd5yzVBY+pAW1Hu6doKoEdbr",
+	"AgRt2vfghmt0kf/q9PO2OR//8lQDp8brB5A2UbCSGW8HP5Pt7udXbGFbAMZU4uqVpCJNS4U5CwWgD7SY",
+	"3bB70rmXPBYpfeHzFp//qYTzMpFI0DTCYsbWQuk+60C+cM3W5l+TCG6DdfKA82P3m9DW14e+GdqSJZ3T",
+	"plZC+wpf7Sqc1IZoU+fXwXtsLSCmsUMdhpihgR1ToQa4r3j/CPM+ISf13d8SWPzRraRJ6f8K/GZ97bON",
+	"8DhoFmcEpgX2mSgwLxgCAMomoxlmS/+XilYEvtKt8L/vZVQ1yXbtTfsoQ4+BB1v8Lr8JAVbtRlknf0Rr",
+	"d07Y8GOU0xbVMfnitsFitU4BT8izHh29Pn7x1wl9fZC/zhcHLycv6cGrxavjg1fHr9hfj4rXlP3lr73W",
+	"WycvwxNbEguh9xcHE/jv6uj1ycsXJ5Mfxq/+cnz81/89OTqZTDq1kifd3mDWldFuN5ZCQ/gxjYZWwZyr",
+	"LHCpiv3StVB/9si4OdqOm+PX++EmLDXCTad1WgpD7hXi30kjKi6YahVBPTIufthxTo73w0Vn3RFGOk3n",
+	"UhjBV0jzThoj840tRnlkBBzvQMDRfgjoLLOLgHOxGwHnYjcCjiePjoAXOxAweQACmiXY5J+HRP08n97L",
+	"aToZbvgRStV1nedMa+gN37VX3Ruu1Oo+84lJ6RGjXvq2fz749easCWPrjchXSgpZ63Lz7I5TH3uyqqxd",
+	"qa1psB6gQjLtYoTg07fBubpkupvWbVxG5PPkCkV3BcQ55+mbBULEwwfhYTM6bWIw+PkMkF9Jafs+tJIN",
+	"fJsFezSadkW+F1iTD+BiPTD/BTNqc3DqCyfTraqMxMF91r2Cb2wWabOQXvb8/Z+sHn416V6RKYoHqq1A",
+	"+i1s66iHeO/HsKZ6itdA6M4pwI6aYG+VzKf/ZYRxzIjDcCBeXoD+aaRRTX56i4++k6IhwjVTpOSCfT+e",
+	"ig/xAcPKKhuQ80SgpauSWyzsdRZubRhJ0wCCyNGnldtxcVljcobKuZ9wicXC4MGySUmQg5P5mK9NH8fA",
+	"L/VJOriylGMr0rY1Xi3xBVG2B1RJB8GRqN+LJ7k7EEV/on5n5NaodtMKmdcVEybsTsJTsK/UehSCiK8B",
+	"SZAF/twwIdv8Mkqxkgt7cKoGdY+ZmrsXdA+Tqvss4Vmk7pULbhNuhWqLrIVUjqgRnqPjP0MaxXcXDfBA",
+	"an9t2gUk2WHUtGCnp6XTdUBvtGGVld1ybZtD+Z4yuOVa4o1KkDawBjILie6+r+Ks5BU3M8L1VGDvtjF5",
+	"577PZTXn2FLRQ7+iKCaxgw2hZek0m+rfyAzbGczsDScW1NDnxsE8dhSvpwL1JcOxYmYGIML9GVQ5Fthi",
+	"vpjQZDUqGGvWdJCYkRuuudHtagBkwuyGCdvocSqCJyf07pszc8uY8Cw2mcUAnpaLUGe1Nez7RlYVPdAM",
+	"XgLhsHB9EiQin8w3maUgkD5495JPGTS80Q19n314S2euCQ86RiBbBPspZQdYip91GjrOILBSMoqFEbOD",
+	"Gc6qg2rKBQFw3eU9iN8xufLVuFbkzYDHzjAvBWaYZWTm3AXwp50d/urO3H5kDR146LYO/gwt3nD4XpO3",
+	"aCrX5c0O6ltJwr/aTd9meKxnvvHb7N96VcR0KuwNLYh+9DLaFQedtryFrFp3D1ZInDUhoaUpx2thfiB4",
+	"b89vSk0cTjC9BNBwMzIyg59mRMEx1swfZ7t7chHoY2B2HCSdWTqiOo8bgOG/YLJkt68012kcoaCYuApU",
+	"LPLlwIRgE0zmkgzxdijfGNeRY2T3uMpV2xoX84vcjk0FuDMDe0B27y9LcHlRAs9pyRaGyNqMySkmNXmr",
+	"5eXRpCm8dAnSgWdFIyEzELgEYP0Udbk17D13ilYKw1T/vEhnZmwtVx7IKUakxkfWotIdNF++PwPNdeZb",
+	"Rw7tfdPSev+TlwLDJ6ngx+mp3E9fOpGwEzW9IFNzNb9+4XT23i83nC1RinFu2J3JSE41g5OpuWsHsAWk",
+	"j4ot+N2XA+YJyLUmhZbr2jA1OHloSLDFEv28iTVkHw5OTO8ea+J9rmCjBu01d/sQbJAlrRRokdx5Z2+c",
+	"eBL63Btq5zR4ANhX8omARuXKRZEfeMw+Rg1Zv3THEQwBwozcAp7s3PBYRFANn7zHhMXuY699blGE4hmu",
+	"AT+V7Qg1gJ2rRIva54KsZFoP4+opIGvde+F5KDWgamrHRZqurMNI+9Dt4ftUcFVyf7Do3aODFdSMh3Ex",
+	"+9ETsrAOXHvzKfvVkzEpz1lb1wDEPLV7zFxWfXQ5XWi8PnDy3nWbRafWMdDp6JEWUckvWgO9e/o1WFPc",
+	"33FhgQ13X+xkiW+b/tvPDtl2lviUkHVVSL4UthAwp5p5ny5XjQsDZskCs2rdy9HcX5pYh2ZU5avWEnZS",
+	"2wd7IYy/RjrqVGmkW0jWuf8H7au5uygtyQ7AR9QCI1w7025beJTtwT5bPpyu/wO8VHgPiO05J9W1bkJT",
+	"ja8IfABo+oYRnDcHj81UhDYN2yy7HGHYit6nr8L8GIKxndaGXJto95493AmozgjufEZy15tVOeeii34i",
+	"PknwjlmSdQ7bkmuD1+HYyO/Lo2dyGnft/4S1Dxa9TUyzicRwHNrFlK7Y1XbZ0sa2gET/w9eXDwZ+2HBI",
+	"In/yoTaK0WrQrXyJ4x5gZyPbhp0sopsy0Onjg1gzO+bMtZuPS+qsRzvGOBeIPEicAKeRlnDBvPUece3i",
+	"Ec4HCfO17fJowJhJZaQWwPDJDG7AsVV7B+dvZximgN3jrkqUiqmwQEYuqhxG5WUZav21q3ALLQFsHrkX",
+	"zdygv0kz4bhHt8cLIHZP93BTxo52pIXNR90zy5RhCW7tMHKouFcsl0Kw3ETOEhvWbphYCx9fyMtAnB0i",
+	"hAfN4RkesHdmLWLQa9k6MDpzuhKeCQ7e6abh8FdDTQ760FYBT0SbpG6HozS/rJeKFgzFLCW/svllkK4u",
+	"yzIvOex9RYWNHxlNdD0PYzgSwDCXd5meTMVUHJDZH1NE/nR0QqYj7SvgpqOMTEe8cI+P7L8th4Znf4zH",
+	"4/v7WQSC/Q3jOCFc5N31OnLGa3DXExsY0E7cWm8j/BNYu47CQiHSYynWXgEEL9ka2FkeXPsUH0fxMBuF",
+	"gLm+K/k1C9GG760sqWrtjT10LVPh9sUFMR2FdjFUiy04up8BUq+gvhqInLZcybPwIYZBooEK55OFgzNr",
+	"fNrACjc+ooNh/BkvZo0z2/MR5IBtKCExx4LndgAej8fjrNljezDg+W8W+E92hxFp8Q4D7IGZXcS4dQpR",
+	"uMEyurhK2+Yflj9R04TQ7HENrCeLY33RheHYMMtusajLcuaTcKYCE75xTMAtsF15A0z/tHXie6w/j+5W",
+	"Yu3uH8jh8aAxPSZvAmw+mrgAEP3dKwqITWq/qbksGDmaTF4lmbnf4Iaft7jk0eSoT+yXt9wdeVeL3tD7",
+	"Wkkjc1k+m8L2E0bHGwBWVBR6Ra9Zl735hUblsj4kKm3pSBikzfVCy5q9QtS9TaREr1nOFzwPwtzV59hX",
+	"MCa9pl7NGmWJ3k7fWAOZKHiWXGPmmCTmK4XifvLdxbs35Pj4+PX3Vr/uXB1ErsJ4NuKmbXg7MCOvaMhl",
+	"iIZipYD3JVI3/Zhgz1vduINgRMMEUmWjLfvBKFy0uJSCdeOjLyYvXh5Mjg4mR1dHL0+OX5xMJv9v3zDa",
+	"85taW3q7IFaf3cYCnBCbg/yn9rJ5TtvsQfbY126AQWWtY6VNysoA8zxs2qju5KFV7yJH1H4a/hm153f9",
+	"TpzRw8oSHJP2MlJrcfnQlRVVU9Fh0MrfYbbz6jJyGtcgh1+DUYfJN74DEUw8FS5PE4FLSd/A2898h+Jv",
+	"q0UYsnm7sSGMHud5t1J5SDKFf8CF2b5R7fPizQ6wB0dXF48fj+iDsmcowjxGCKLvFHXwBJ/o3p7PkF7z",
+	"wyT7IjdodLlsZD85oODe1QGQpL3ELgnTrutYnl7GRhf6JXgq/kpWHPjF81dwdNyV+tmE7k9ygKPHFQtf",
+	"m1xrbpd0+xU7+YZk3N4lw1Xn3mAvcmI5Nyb9C429awwuFUbb2kqeeW11GifrpiKkmeGbXIPro2TFVhnk",
+	"6o6/Tgn06c8viPbah7vEub2FcUuf/yapYZLypdu7KOnzvfeNmmhdap2AwAzDazkV4ECTayZChV4cAuFN",
+	"6kDmkqjR5+MCALJikd/Gvgl/il3+8m9Hw/tvx/035riP4zh7EJhp3TeXJLJ37qJl2qRj0+AlBWisB7RJ",
+	"f8ZWRZ2bl+wtzzfdK9fcz76T41RU8T1KvhezIFKMya/xNXPRnXQoAaEGT6N9ASlkRkKfZpSIzgFd8qVA",
+	"24xUdWn42lakWpq2L5ESr9FzdQqBqisuMlLROwQRacAuYuXqCiAwTP0Q3t8UmPJK2rsacG32Qxs2xG/9",
+	"+mxVBHKfraI5uhzwm2EgeGEmMb58xTUgdzUWKKuEvB2ywODbgbR+nzi5E4Dk7YjW51i4lKK2fTo7nmAU",
+	"6KiywY6jFcRsfDIWbJVNYRoAOlz19ZnmarhoUTVXOf7JZuswSE9ovj69lRaRU8pVxyt2oKMGlq5a6NkN",
+	"tobQPyPh5M/ypn51GqdpbydtxV6tdNSGbvFMni6Xii29XI1jcoqaVZPU3Tw3K1ZpVt6A9HMlgfNNKHKB",
+	"8x7fKOQ6LQNbmwpfcGq9Z/BDSJbDQWzCHH6ONxFAYmVIzGOK+UsxFcuZMOVmTGbAF1wQ28gZEVQpeWsF",
+	"YJQbFxpV7ZP1z4UvI3QBUCwbx9S0otDNeuDHqUCQuNk0WS03/mJM6q/FBD6cr6hK1yP+yAzeGrpL+gWZ",
+	"g/C09gQKEYGFg5JnC/5SZX5N4RH8cIHIHqo/cmt+YH6ivZG0G4j2GK5FwZS7ejicVHeEQHLlW6p04qtP",
+	"07Lz6IfIZwcR4Qd77VA4UE8QT1bf8pii7Eug/Ual3LuSeYJJWjvUcG143m/19s2It6/I+BpAJr6G36UY",
+	"1XuZ05IU7IaVco09H+y77spKe2vdyeFhCe+tpDYnryavXo3uP93//wEAvnFQTcuoAAA=",
 }
 
 // GetSwagger returns the content of the embedded swagger specification file
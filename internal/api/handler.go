@@ -1,8 +1,16 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/adrianrios/lunar-test/internal/rockets"
@@ -15,68 +23,396 @@ var _ ServerInterface = (*RocketsAPI)(nil)
 type RocketsAPI struct {
 	messagesService rockets.MessageService
 	rocketsService  *rockets.RocketsService
+	rebuildService  *rockets.RebuildService
+	webhookService  *rockets.WebhookService
+	feed            *rockets.RocketFeed
+	config          Config
 }
 
-func NewRocketsAPI(messagesService rockets.MessageService, rocketsService *rockets.RocketsService) *RocketsAPI {
-	return &RocketsAPI{messagesService: messagesService, rocketsService: rocketsService}
+// Config holds the settings of the API.
+type Config struct {
+	// AdminToken is the bearer token of the admin endpoints, they are disabled when it is empty.
+	AdminToken string
+	WebSocket  WebSocketConfig
+}
+
+func NewRocketsAPI(messagesService rockets.MessageService, rocketsService *rockets.RocketsService, rebuildService *rockets.RebuildService, webhookService *rockets.WebhookService, feed *rockets.RocketFeed, config Config) *RocketsAPI {
+	if config.WebSocket.Buffer < 1 {
+		config.WebSocket.Buffer = 256
+	}
+	if config.WebSocket.WriteTimeout <= 0 {
+		config.WebSocket.WriteTimeout = 10 * time.Second
+	}
+	if config.WebSocket.PingInterval <= 0 {
+		config.WebSocket.PingInterval = 30 * time.Second
+	}
+	if config.WebSocket.MaxSubscriptions < 1 {
+		config.WebSocket.MaxSubscriptions = 32
+	}
+
+	return &RocketsAPI{
+		messagesService: messagesService,
+		rocketsService:  rocketsService,
+		rebuildService:  rebuildService,
+		webhookService:  webhookService,
+		feed:            feed,
+		config:          config,
+	}
 }
 
 func (a RocketsAPI) PostMessage(w http.ResponseWriter, r *http.Request) {
 	var message rockets.Message
 	err := json.NewDecoder(r.Body).Decode(&message)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := rockets.ValidateMessage(message); err != nil {
+		writeValidationError(w, err)
 		return
 	}
 
-	if err := a.messagesService.Ingest(r.Context(), message); err != nil {
+	err = a.messagesService.Ingest(r.Context(), message)
+	switch {
+	case err == nil, errors.Is(err, rockets.ErrDuplicateMessage):
+		// Redeliveries are acknowledged like the original, so the sender stops retrying.
+		w.WriteHeader(a.acceptedStatus())
+	case errors.Is(err, rockets.ErrConflictingMessage):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, rockets.ErrQueueFull):
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, rockets.ErrShuttingDown):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
 		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// acceptedStatus is 202 when messages are applied in the background, since the rocket is not updated yet.
+func (a RocketsAPI) acceptedStatus() int {
+	if a.messagesService.Async() {
+		return http.StatusAccepted
+	}
+	return http.StatusOK
+}
+
+func (a RocketsAPI) PostMessagesBatch(w http.ResponseWriter, r *http.Request) {
+	items, err := decodeBatch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "the body must be a JSON array of messages or NDJSON")
 		return
 	}
+	if len(items) > maxBatchSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("a batch can't have more than %d messages", maxBatchSize))
+		return
+	}
+
+	results := make([]BatchItemResult, len(items))
+	messages := make([]rockets.Message, 0, len(items))
+	positions := make([]int, 0, len(items))
+	for i, item := range items {
+		results[i] = BatchItemResult{Index: i}
+
+		var message rockets.Message
+		if err := json.Unmarshal(item, &message); err != nil {
+			results[i].Status = BatchItemResultStatusInvalid
+			reason := err.Error()
+			results[i].Reason = &reason
+			continue
+		}
+		channel := openapi_types.UUID(message.Metadata.Channel)
+		number := message.Metadata.MessageNumber
+		results[i].Channel = &channel
+		results[i].MessageNumber = &number
+		if err := rockets.ValidateMessage(message); err != nil {
+			results[i].Status = BatchItemResultStatusInvalid
+			reason := err.Error()
+			results[i].Reason = &reason
+			continue
+		}
+
+		messages = append(messages, message)
+		positions = append(positions, i)
+	}
+
+	for i, result := range a.messagesService.IngestBatch(r.Context(), messages) {
+		item := &results[positions[i]]
+		item.Status = BatchItemResultStatus(result.Status)
+		if result.Reason != "" {
+			reason := result.Reason
+			item.Reason = &reason
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(a.acceptedStatus())
+	_ = json.NewEncoder(w).Encode(BatchResult{Results: results})
+}
+
+const maxBatchSize = 5000
+
+// decodeBatch splits the body into raw messages, reading NDJSON when the content type says so and a JSON array
+// otherwise. Messages are decoded one by one later, so a broken message doesn't fail the whole batch. Reading stops
+// one message past maxBatchSize, so an oversized batch is refused without reading the rest of the body.
+func decodeBatch(r *http.Request) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-ndjson" {
+		decoder := json.NewDecoder(r.Body)
+		if token, err := decoder.Token(); err != nil {
+			return nil, err
+		} else if token != json.Delim('[') {
+			return nil, errors.New("not a JSON array")
+		}
+		for decoder.More() && len(items) <= maxBatchSize {
+			var item json.RawMessage
+			if err := decoder.Decode(&item); err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if len(items) > maxBatchSize {
+			return items, nil
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
 
-	w.WriteHeader(http.StatusOK)
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() && len(items) <= maxBatchSize {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		items = append(items, bytes.Clone(line))
+	}
+	return items, scanner.Err()
 }
 
 func (a RocketsAPI) ListRockets(w http.ResponseWriter, r *http.Request, params ListRocketsParams) {
-	var sortBy *string
-	var order *string
+	// A misspelled filter would otherwise be dropped and return the whole fleet
+	if name := unknownQueryParameter(r, params); name != "" {
+		writeValidationError(w, &rockets.ValidationError{Field: name, Reason: "is not a known filter"})
+		return
+	}
+
+	query := rockets.RocketQuery{Filter: rockets.RocketFilter{
+		MinSpeed:             params.MinSpeed,
+		MaxSpeed:             params.MaxSpeed,
+		LastMessageFrom:      params.LastMessageFrom,
+		LastMessageTo:        params.LastMessageTo,
+		MinPeakSpeed:         params.MinPeakSpeed,
+		MaxPeakSpeed:         params.MaxPeakSpeed,
+		MinTotalAcceleration: params.MinTotalAcceleration,
+		MaxTotalAcceleration: params.MaxTotalAcceleration,
+		MinMissionChanges:    params.MinMissionChanges,
+		MaxMissionChanges:    params.MaxMissionChanges,
+		LaunchedFrom:         params.LaunchedFrom,
+		LaunchedTo:           params.LaunchedTo,
+		MinFlightDuration:    params.MinFlightDuration,
+		MaxFlightDuration:    params.MaxFlightDuration,
+		MinDistance:          params.MinDistance,
+		MaxDistance:          params.MaxDistance,
+	}}
+	if params.Status != nil {
+		query.Filter.Status = *params.Status
+	}
+	if params.Type != nil {
+		query.Filter.Type = *params.Type
+	}
+	if params.Mission != nil {
+		query.Filter.Mission = *params.Mission
+	}
+	if params.MissionPrefix != nil {
+		query.Filter.MissionPrefix = *params.MissionPrefix
+	}
+	if params.Search != nil {
+		query.Filter.Search = *params.Search
+	}
+	if err := query.Filter.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
 
+	sortBy := ""
 	if params.SortBy != nil {
-		sortVal := string(*params.SortBy)
-		sortBy = &sortVal
+		sortBy = *params.SortBy
+	}
+	sort, err := rockets.ParseRocketSort(sortBy, params.Order != nil && *params.Order == Desc)
+	if err != nil {
+		writeValidationError(w, err)
+		return
 	}
-	if params.Order != nil {
-		orderVal := string(*params.Order)
-		order = &orderVal
+	query.Sort = sort
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > 500 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		query.Limit = *params.Limit
+	}
+	if params.Cursor != nil {
+		query.Cursor = *params.Cursor
 	}
 
-	rockets, err := a.rocketsService.GetAll(r.Context(), sortBy, order)
+	var page *rockets.RocketPage
+	if params.AsOf != nil {
+		page, err = a.rocketsService.GetAllAsOf(r.Context(), *params.AsOf, query)
+	} else {
+		page, err = a.rocketsService.GetAll(r.Context(), query)
+	}
+	if errors.Is(err, rockets.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, rockets.ErrCompactedLog) {
+		writeError(w, http.StatusGone, err.Error())
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	apiRockets := make([]Rocket, 0, len(rockets))
-	for _, rkt := range rockets {
-		apiRockets = append(apiRockets, toAPIRocket(rkt))
+	resp := RocketPage{Rockets: make([]Rocket, 0, len(page.Rockets)), Total: page.Total}
+	for _, rkt := range page.Rockets {
+		resp.Rockets = append(resp.Rockets, toAPIRocket(rkt))
+	}
+	if page.NextCursor != "" {
+		resp.NextCursor = &page.NextCursor
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(struct {
-		Rockets []Rocket `json:"rockets"`
-	}{Rockets: apiRockets})
+	_ = json.NewEncoder(w).Encode(resp)
 }
 
-func (a RocketsAPI) GetRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID) {
-	rkt, err := a.rocketsService.GetByChannel(r.Context(), uuid.UUID(channel))
+func (a RocketsAPI) GetRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketParams) {
+	var rkt *rockets.Rocket
+	var err error
+	if params.AsOf != nil {
+		asOf, parseErr := rockets.ParseAsOf(*params.AsOf)
+		if parseErr != nil {
+			writeValidationError(w, parseErr)
+			return
+		}
+		rkt, err = a.rocketsService.GetByChannelAsOf(r.Context(), uuid.UUID(channel), asOf)
+	} else {
+		rkt, err = a.rocketsService.GetByChannel(r.Context(), uuid.UUID(channel))
+	}
+	if errors.Is(err, rockets.ErrRocketNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, rockets.ErrCompactedLog) {
+		writeError(w, http.StatusGone, err.Error())
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(toAPIRocket(*rkt))
 }
 
+func (a RocketsAPI) GetRocketEvents(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketEventsParams) {
+	filter := rockets.EventFilter{From: params.From, To: params.To, Limit: 50}
+	if params.MessageType != nil {
+		filter.MessageType = *params.MessageType
+	}
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > 500 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		filter.Limit = *params.Limit
+	}
+	if params.Offset != nil {
+		if *params.Offset < 0 {
+			writeError(w, http.StatusBadRequest, "offset must not be negative")
+			return
+		}
+		filter.Offset = *params.Offset
+	}
+
+	page, err := a.rocketsService.Events(r.Context(), uuid.UUID(channel), filter)
+	if errors.Is(err, rockets.ErrRocketNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := RocketEventsPage{Events: make([]RocketEvent, 0, len(page.Events)), Total: page.Total}
+	for _, event := range page.Events {
+		apiEvent := RocketEvent{
+			MessageNumber: event.Message.Metadata.MessageNumber,
+			MessageTime:   event.Message.Metadata.MessageTime,
+			MessageType:   event.Message.Metadata.MessageType,
+			Status:        RocketEventStatus(event.Status),
+			Message:       event.Message.Message,
+		}
+		if event.Status == rockets.EventDuplicate {
+			apiEvent.Redeliveries = &event.Message.Redeliveries
+			apiEvent.LastRedeliveredAt = &event.Message.RedeliveredAt
+		}
+		resp.Events = append(resp.Events, apiEvent)
+	}
+	if next := filter.Offset + len(page.Events); next < page.Total {
+		resp.NextOffset = &next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (a RocketsAPI) GetRocketGaps(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID) {
+	gaps, err := a.rocketsService.Gaps(r.Context(), uuid.UUID(channel))
+	if errors.Is(err, rockets.ErrRocketNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeGaps(w, gaps)
+}
+
+func (a RocketsAPI) ListGaps(w http.ResponseWriter, r *http.Request) {
+	gaps, err := a.rocketsService.AllGaps(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeGaps(w, gaps)
+}
+
+func writeGaps(w http.ResponseWriter, gaps []rockets.Gap) {
+	now := time.Now()
+	resp := GapList{Gaps: make([]Gap, 0, len(gaps))}
+	for _, gap := range gaps {
+		resp.Gaps = append(resp.Gaps, Gap{
+			Channel:        openapi_types.UUID(gap.Channel),
+			From:           gap.From,
+			To:             gap.To,
+			Missing:        gap.Missing(),
+			OpenSince:      gap.OpenSince,
+			OpenForSeconds: int(now.Sub(gap.OpenSince).Seconds()),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 func toAPIRocket(r rockets.Rocket) Rocket {
 	var lastNum *int
 	if r.LastMessageNumber != nil {
@@ -88,6 +424,19 @@ func toAPIRocket(r rockets.Rocket) Rocket {
 		v := *r.LastMessageTime
 		lastTime = &v
 	}
+	var launchTime *time.Time
+	if r.LaunchTime != nil {
+		v := *r.LaunchTime
+		launchTime = &v
+	}
+	var skipped *[]MessageRange
+	if len(r.SkippedMessages) > 0 {
+		ranges := make([]MessageRange, 0, len(r.SkippedMessages))
+		for _, s := range r.SkippedMessages {
+			ranges = append(ranges, MessageRange{From: s.From, To: s.To})
+		}
+		skipped = &ranges
+	}
 	return Rocket{
 		Channel:           openapi_types.UUID(r.Channel),
 		Type:              r.Type,
@@ -97,5 +446,53 @@ func toAPIRocket(r rockets.Rocket) Rocket {
 		ExplosionReason:   r.ExplosionReason,
 		LastMessageNumber: lastNum,
 		LastMessageTime:   lastTime,
+		PeakSpeed:         r.PeakSpeed,
+		TotalAcceleration: r.TotalAcceleration,
+		MissionChanges:    r.MissionChanges,
+		LaunchTime:        launchTime,
+		FlightDuration:    r.FlightDuration,
+		Distance:          r.Distance,
+		PendingMessages:   r.PendingMessages,
+		InSync:            r.InSync(),
+		SkippedMessages:   skipped,
 	}
 }
+
+// unknownQueryParameter returns the first query parameter, by name, that is not a field of params, the generated
+// parameters of the operation. It is empty when every parameter is known.
+func unknownQueryParameter(r *http.Request, params any) string {
+	known := make(map[string]bool)
+	fields := reflect.TypeOf(params)
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Field(i).Tag.Get("form"), ",")
+		known[name] = true
+	}
+
+	var unknown []string
+	for name := range r.URL.Query() {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return ""
+	}
+	return slices.Min(unknown)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(Error{Error: message})
+}
+
+func writeValidationError(w http.ResponseWriter, err error) {
+	var validationErr *rockets.ValidationError
+	if !errors.As(err, &validationErr) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(Error{Error: validationErr.Error(), Field: &validationErr.Field})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/adrianrios/lunar-test/internal/rockets"
+)
+
+func (a RocketsAPI) GetStats(w http.ResponseWriter, r *http.Request, params GetStatsParams) {
+	if name := unknownQueryParameter(r, params); name != "" {
+		writeValidationError(w, &rockets.ValidationError{Field: name, Reason: "is not a known parameter"})
+		return
+	}
+
+	activeWithin := 15
+	if params.ActiveWithin != nil {
+		if *params.ActiveWithin < 1 || *params.ActiveWithin > 10080 {
+			writeError(w, http.StatusBadRequest, "activeWithin must be between 1 and 10080")
+			return
+		}
+		activeWithin = *params.ActiveWithin
+	}
+
+	query := rockets.StatsQuery{
+		From:        params.From,
+		To:          params.To,
+		ActiveSince: time.Now().Add(-time.Duration(activeWithin) * time.Minute),
+	}
+	if params.GroupBy != nil {
+		query.GroupBy = *params.GroupBy
+	}
+	if err := query.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	stats, err := a.rocketsService.Stats(r.Context(), query)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := FleetStats{
+		Total:        stats.Total,
+		Active:       stats.Active,
+		ActiveWithin: activeWithin,
+		ByStatus:     toAPIStatsCounts(stats.ByStatus),
+		ByType:       toAPIStatsCounts(stats.ByType),
+		ByMission:    toAPIStatsCounts(stats.ByMission),
+		SpeedByType:  toAPIStatsGroups(stats.SpeedByType),
+		Explosions:   toAPIStatsCounts(stats.Explosions),
+	}
+	if query.GroupBy != "" {
+		groups := toAPIStatsGroups(stats.Groups)
+		resp.Groups = &groups
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func toAPIStatsCounts(counts []rockets.StatsCount) []StatsCount {
+	result := make([]StatsCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, StatsCount{Key: count.Key, Count: count.Count})
+	}
+	return result
+}
+
+func toAPIStatsGroups(groups []rockets.StatsGroup) []StatsGroup {
+	result := make([]StatsGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, StatsGroup{
+			Key:          group.Key,
+			Count:        group.Count,
+			Active:       group.Active,
+			AverageSpeed: group.AverageSpeed,
+			MinSpeed:     group.MinSpeed,
+			MaxSpeed:     group.MaxSpeed,
+		})
+	}
+	return result
+}
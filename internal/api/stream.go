@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adrianrios/lunar-test/internal/rockets"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// keepAliveInterval is how often an idle stream sends a comment, so proxies don't close it.
+const keepAliveInterval = 15 * time.Second
+
+func (a RocketsAPI) StreamRockets(w http.ResponseWriter, r *http.Request, params StreamRocketsParams) {
+	a.stream(w, r, nil, params.LastEventID)
+}
+
+func (a RocketsAPI) StreamRocket(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params StreamRocketParams) {
+	id := uuid.UUID(channel)
+	a.stream(w, r, &id, params.LastEventID)
+}
+
+// stream sends the rockets of the channel, or of the fleet, as Server-Sent Events until the client goes away. A client
+// resuming from an event the feed no longer remembers gets the current state first, like a new one.
+func (a RocketsAPI) stream(w http.ResponseWriter, r *http.Request, channel *uuid.UUID, lastEventID *string) {
+	resumeFrom := ""
+	if lastEventID != nil {
+		resumeFrom = *lastEventID
+	}
+	replay, current, updates, cancel := a.feed.Subscribe(channel, resumeFrom)
+	defer cancel()
+
+	// Subscribed before reading the state, so nothing stored meanwhile is missed
+	if replay == nil {
+		state, err := a.currentState(r, channel)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for _, rocket := range state {
+			replay = append(replay, rockets.RocketUpdate{ID: current, Rocket: rocket})
+		}
+	}
+
+	// The write timeout of the server would cut the stream
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, update := range replay {
+		if writeEvent(w, update) != nil {
+			return
+		}
+	}
+	if controller.Flush() != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, open := <-updates:
+			// A closed feed means the client fell behind, it reconnects with its last event ID
+			if !open || writeEvent(w, update) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if controller.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (a RocketsAPI) currentState(r *http.Request, channel *uuid.UUID) ([]rockets.Rocket, error) {
+	if channel == nil {
+		page, err := a.rocketsService.GetAll(r.Context(), rockets.RocketQuery{})
+		if err != nil {
+			return nil, err
+		}
+		return page.Rockets, nil
+	}
+	rocket, err := a.rocketsService.GetByChannel(r.Context(), *channel)
+	if errors.Is(err, rockets.ErrRocketNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []rockets.Rocket{*rocket}, nil
+}
+
+func writeEvent(w http.ResponseWriter, update rockets.RocketUpdate) error {
+	data, err := json.Marshal(toAPIRocket(update.Rocket))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: rocket\ndata: %s\n\n", update.ID, data)
+	return err
+}
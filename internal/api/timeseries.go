@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/adrianrios/lunar-test/internal/rockets"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+func (a RocketsAPI) GetRocketTimeseries(w http.ResponseWriter, r *http.Request, channel openapi_types.UUID, params GetRocketTimeseriesParams) {
+	if name := unknownQueryParameter(r, params); name != "" {
+		writeValidationError(w, &rockets.ValidationError{Field: name, Reason: "is not a known parameter"})
+		return
+	}
+
+	query := rockets.TimeseriesQuery{Field: "speed", From: params.From, To: params.To}
+	if params.Field != nil {
+		query.Field = *params.Field
+	}
+	if params.Bucket != nil {
+		bucket, err := time.ParseDuration(*params.Bucket)
+		if err != nil {
+			writeValidationError(w, &rockets.ValidationError{Field: "bucket", Reason: "must be a duration like 30s, 1m or 1h"})
+			return
+		}
+		query.Bucket = bucket
+	}
+	if err := query.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	timeseries, err := a.rocketsService.Timeseries(r.Context(), uuid.UUID(channel), query)
+	var validationErr *rockets.ValidationError
+	switch {
+	case errors.Is(err, rockets.ErrRocketNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.As(err, &validationErr):
+		writeValidationError(w, err)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := RocketTimeseries{Channel: channel, Field: query.Field}
+	if query.Bucket > 0 {
+		bucket := query.Bucket.String()
+		buckets := make([]TimeseriesBucket, 0, len(timeseries.Buckets))
+		for _, b := range timeseries.Buckets {
+			buckets = append(buckets, TimeseriesBucket{
+				Start:    b.Start,
+				Messages: b.Messages,
+				Min:      b.Min,
+				Max:      b.Max,
+				Last:     b.Last,
+				Mission:  b.Mission,
+			})
+		}
+		resp.Bucket, resp.Buckets = &bucket, &buckets
+	} else {
+		points := make([]TimeseriesPoint, 0, len(timeseries.Points))
+		for _, point := range timeseries.Points {
+			points = append(points, TimeseriesPoint{
+				MessageNumber: point.MessageNumber,
+				Time:          point.Time,
+				Value:         point.Value,
+				Mission:       point.Mission,
+			})
+		}
+		resp.Points = &points
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/adrianrios/lunar-test/internal/rockets"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+func (a RocketsAPI) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	webhooks, err := a.webhookService.Webhooks(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		resp = append(resp, toAPIWebhook(webhook))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (a RocketsAPI) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	secret := ""
+	if req.Secret != nil {
+		secret = *req.Secret
+	}
+
+	webhook, err := a.webhookService.Subscribe(r.Context(), req.Url, req.Events, secret)
+	if errors.Is(err, rockets.ErrInvalidWebhook) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// The secret is only shown once, listings leave it out
+	resp := toAPIWebhook(*webhook)
+	resp.Secret = &webhook.Secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (a RocketsAPI) DeleteWebhook(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	err := a.webhookService.Unsubscribe(r.Context(), uuid.UUID(id))
+	if errors.Is(err, rockets.ErrWebhookNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a RocketsAPI) ListFailedDeliveries(w http.ResponseWriter, r *http.Request, params ListFailedDeliveriesParams) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	deliveries, err := a.webhookService.FailedDeliveries(r.Context(), (*uuid.UUID)(params.Webhook))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		resp = append(resp, toAPIWebhookDelivery(delivery))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (a RocketsAPI) GetFailedDelivery(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	delivery, err := a.webhookService.FailedDelivery(r.Context(), uuid.UUID(id))
+	if errors.Is(err, rockets.ErrDeliveryNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toAPIWebhookDelivery(*delivery))
+}
+
+func (a RocketsAPI) RedeliverWebhook(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	if !a.authorized(w, r) {
+		return
+	}
+
+	delivery, err := a.webhookService.Redeliver(r.Context(), uuid.UUID(id))
+	switch {
+	case errors.Is(err, rockets.ErrDeliveryNotFound), errors.Is(err, rockets.ErrWebhookNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, rockets.ErrDeliveryFailed):
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toAPIWebhookDelivery(*delivery))
+}
+
+func toAPIWebhook(webhook rockets.Webhook) Webhook {
+	return Webhook{
+		Id:        openapi_types.UUID(webhook.ID),
+		Url:       webhook.URL,
+		Events:    webhook.Events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+func toAPIWebhookDelivery(delivery rockets.WebhookDelivery) WebhookDelivery {
+	event := LifecycleEvent{
+		Id:         openapi_types.UUID(delivery.Event.ID),
+		Type:       delivery.Event.Type,
+		OccurredAt: delivery.Event.OccurredAt,
+		Rocket:     toAPIRocket(delivery.Event.Rocket),
+	}
+	if delivery.Event.PreviousMission != "" {
+		event.PreviousMission = &delivery.Event.PreviousMission
+	}
+	return WebhookDelivery{
+		Id:        openapi_types.UUID(delivery.ID),
+		WebhookId: openapi_types.UUID(delivery.WebhookID),
+		Event:     event,
+		Attempts:  delivery.Attempts,
+		Error:     delivery.Error,
+		FailedAt:  delivery.FailedAt,
+	}
+}
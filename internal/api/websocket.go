@@ -0,0 +1,426 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianrios/lunar-test/internal/rockets"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketConfig sets how much a WebSocket client can lag before it is disconnected.
+type WebSocketConfig struct {
+	// Buffer is how many messages can wait to be written to a connection, it is closed as too slow beyond that. The
+	// rockets a subscription starts with don't count, they are written as fast as the client reads them.
+	Buffer int
+	// WriteTimeout is how long writing a message can take before the connection is closed.
+	WriteTimeout time.Duration
+	// PingInterval is how often the client is pinged, one that doesn't answer within two intervals is disconnected.
+	PingInterval time.Duration
+	// MaxSubscriptions is how many subscriptions a connection can hold at once.
+	MaxSubscriptions int
+}
+
+const maxClientMessageSize = 64 * 1024
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// rocketFilter selects the rockets of a subscription. A rocket matches when it is in every non empty list. Changes
+// further requires one of the fields to differ from what the connection last got of the rocket.
+type rocketFilter struct {
+	Channels []uuid.UUID `json:"channels"`
+	Missions []string    `json:"missions"`
+	Types    []string    `json:"types"`
+	Statuses []string    `json:"statuses"`
+	Changes  []string    `json:"changes"`
+}
+
+type clientMessage struct {
+	Type   string        `json:"type"`
+	ID     string        `json:"id"`
+	Filter *rocketFilter `json:"filter"`
+	// invalid is set for a message that is not a JSON object, so run answers it.
+	invalid bool
+}
+
+type serverMessage struct {
+	Type          string         `json:"type"`
+	ID            string         `json:"id,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Channel       *uuid.UUID     `json:"channel,omitempty"`
+	Subscriptions []string       `json:"subscriptions,omitempty"`
+	Fields        map[string]any `json:"fields,omitempty"`
+}
+
+// rocketFieldNames are the fields of the JSON of a rocket, the ones a filter can ask to change. They are read from the
+// generated model, so a field added to the API can be filtered on right away.
+var rocketFieldNames = jsonFieldNames(reflect.TypeFor[Rocket]())
+
+func jsonFieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (a RocketsAPI) SubscribeRockets(w http.ResponseWriter, r *http.Request) {
+	// The upgrader answers failed handshakes itself
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	// Subscribed to the feed before any state is read, so nothing stored meanwhile is missed
+	_, _, updates, cancel := a.feed.Subscribe(nil, "")
+	defer cancel()
+
+	s := &wsSession{
+		api:           a,
+		conn:          conn,
+		config:        a.config.WebSocket,
+		outbox:        make(chan []byte),
+		fleets:        make(chan fleetSnapshot),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]rocketFilter),
+		sent:          make(map[uuid.UUID]sentRocket),
+	}
+	commands := make(chan clientMessage)
+	go s.write()
+	go s.read(commands)
+	s.run(r, commands, updates)
+}
+
+// wsSession is a WebSocket connection. Its subscriptions, its queues and what it was sent are only used by run, the
+// reading and writing goroutines talk to it through channels.
+type wsSession struct {
+	api    RocketsAPI
+	conn   *websocket.Conn
+	config WebSocketConfig
+	// outbox hands the next message to the writer, run keeps handling updates while the client is slow to read.
+	outbox chan []byte
+	// fleets brings the rockets read for a new subscription, the storage is read apart from run.
+	fleets chan fleetSnapshot
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	subscriptions map[string]rocketFilter
+	// sent is the last state of each rocket the client got
+	sent map[uuid.UUID]sentRocket
+	// queued are the messages waiting for the writer, up to Buffer of them
+	queued [][]byte
+	// backlog are the rockets new subscriptions start with, written once nothing is queued
+	backlog []snapshotRocket
+}
+
+type sentRocket struct {
+	rocket rockets.Rocket
+	fields map[string]any
+}
+
+type fleetSnapshot struct {
+	id      string
+	rockets []rockets.Rocket
+	err     error
+}
+
+type snapshotRocket struct {
+	id     string
+	rocket rockets.Rocket
+}
+
+func (s *wsSession) run(r *http.Request, commands <-chan clientMessage, updates <-chan rockets.RocketUpdate) {
+	for {
+		// The writer is offered the next message, if any, while updates and commands keep being handled
+		var outbox chan []byte
+		next, snapshot := s.next()
+		if next != nil {
+			outbox = s.outbox
+		}
+
+		select {
+		case <-s.done:
+			return
+		case outbox <- next:
+			s.written(snapshot)
+		case command := <-commands:
+			s.handle(r, command)
+		case fleet := <-s.fleets:
+			s.queueFleet(fleet)
+		case update, open := <-updates:
+			if !open {
+				// Fell behind the feed, or the server is shutting down
+				s.close(websocket.ClosePolicyViolation, "updates ended, subscribe again")
+				return
+			}
+			s.publish(update.Rocket)
+		}
+	}
+}
+
+func (s *wsSession) handle(r *http.Request, command clientMessage) {
+	if command.invalid {
+		s.send(serverMessage{Type: "error", Error: "messages must be JSON objects with a type"})
+		return
+	}
+
+	switch command.Type {
+	case "subscribe":
+		if err := s.validate(command); err != nil {
+			s.send(serverMessage{Type: "error", ID: command.ID, Error: err.Error()})
+			return
+		}
+		s.subscriptions[command.ID] = *command.Filter
+		s.send(serverMessage{Type: "subscribed", ID: command.ID})
+		go s.readFleet(r, command.ID)
+	case "unsubscribe":
+		if _, exists := s.subscriptions[command.ID]; !exists {
+			s.send(serverMessage{Type: "error", ID: command.ID, Error: "no subscription with this id"})
+			return
+		}
+		delete(s.subscriptions, command.ID)
+		s.send(serverMessage{Type: "unsubscribed", ID: command.ID})
+	default:
+		s.send(serverMessage{Type: "error", ID: command.ID, Error: fmt.Sprintf("unknown message type %q", command.Type)})
+	}
+}
+
+func (s *wsSession) validate(command clientMessage) error {
+	switch {
+	case command.ID == "":
+		return fmt.Errorf("id is required")
+	case command.Filter == nil:
+		return fmt.Errorf("filter is required")
+	case len(s.subscriptions) >= s.config.MaxSubscriptions:
+		return fmt.Errorf("a connection can't have more than %d subscriptions", s.config.MaxSubscriptions)
+	}
+	if _, exists := s.subscriptions[command.ID]; exists {
+		return fmt.Errorf("already subscribed with this id")
+	}
+	for _, field := range command.Filter.Changes {
+		if !slices.Contains(rocketFieldNames, field) {
+			return fmt.Errorf("changes: unknown rocket field %q", field)
+		}
+	}
+	return nil
+}
+
+// publish sends the rocket to the subscriptions it matches. A rocket that matched when it was last sent is sent too,
+// so the client sees it leave the subscription.
+func (s *wsSession) publish(rocket rockets.Rocket) {
+	previous, known := s.sent[rocket.Channel]
+	diff := diffFields(previous.fields, rocketFields(rocket))
+	if len(diff) == 0 {
+		return
+	}
+
+	var ids []string
+	for id, filter := range s.subscriptions {
+		if !filter.matches(rocket) && !(known && filter.matches(previous.rocket)) {
+			continue
+		}
+		if len(filter.Changes) > 0 && !slices.ContainsFunc(filter.Changes, func(field string) bool { _, changed := diff[field]; return changed }) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		s.sendDiff(rocket, ids)
+	}
+}
+
+// readFleet reads the rockets a subscription starts with and hands them to run.
+func (s *wsSession) readFleet(r *http.Request, id string) {
+	fleet, err := s.api.rocketsService.GetAll(r.Context(), rockets.RocketQuery{})
+	snapshot := fleetSnapshot{id: id, err: err}
+	if err == nil {
+		snapshot.rockets = fleet.Rockets
+	}
+	select {
+	case s.fleets <- snapshot:
+	case <-s.done:
+	}
+}
+
+// queueFleet adds the rockets the subscription matches to the backlog.
+func (s *wsSession) queueFleet(fleet fleetSnapshot) {
+	filter, subscribed := s.subscriptions[fleet.id]
+	if !subscribed {
+		return
+	}
+	if fleet.err != nil {
+		s.send(serverMessage{Type: "error", ID: fleet.id, Error: "the current state of the rockets could not be read"})
+		return
+	}
+	for _, rocket := range fleet.rockets {
+		if filter.matches(rocket) {
+			s.backlog = append(s.backlog, snapshotRocket{id: fleet.id, rocket: rocket})
+		}
+	}
+}
+
+// next returns the message to write: the oldest queued one, or else a diff of the next rocket of the backlog along
+// with what the client will have of the rocket once it is written. The diff is taken when the rocket is written, so it
+// follows whatever updates were sent before.
+func (s *wsSession) next() ([]byte, *sentRocket) {
+	if len(s.queued) > 0 {
+		return s.queued[0], nil
+	}
+	for len(s.backlog) > 0 {
+		item := s.backlog[0]
+		previous, known := s.sent[item.rocket.Channel]
+		_, subscribed := s.subscriptions[item.id]
+		// An update sent since the fleet was read is newer than the rocket
+		if subscribed && (!known || previous.rocket.Version < item.rocket.Version) {
+			fields := rocketFields(item.rocket)
+			if diff := diffFields(previous.fields, fields); len(diff) > 0 {
+				channel := item.rocket.Channel
+				data, err := json.Marshal(serverMessage{Type: "diff", Channel: &channel, Subscriptions: []string{item.id}, Fields: diff})
+				if err == nil {
+					return data, &sentRocket{rocket: item.rocket, fields: fields}
+				}
+			}
+		}
+		s.backlog = s.backlog[1:]
+	}
+	return nil, nil
+}
+
+// written drops the message next returned once the writer took it.
+func (s *wsSession) written(snapshot *sentRocket) {
+	if snapshot == nil {
+		s.queued[0] = nil
+		s.queued = s.queued[1:]
+		return
+	}
+	s.sent[snapshot.rocket.Channel] = *snapshot
+	s.backlog = s.backlog[1:]
+}
+
+// sendDiff sends the fields of the rocket that changed since the client last got it.
+func (s *wsSession) sendDiff(rocket rockets.Rocket, ids []string) {
+	fields := rocketFields(rocket)
+	diff := diffFields(s.sent[rocket.Channel].fields, fields)
+	if len(diff) == 0 {
+		return
+	}
+	sort.Strings(ids)
+	channel := rocket.Channel
+	s.send(serverMessage{Type: "diff", Channel: &channel, Subscriptions: ids, Fields: diff})
+	s.sent[rocket.Channel] = sentRocket{rocket: rocket, fields: fields}
+}
+
+// send queues the message for the client, which is disconnected when its queue is full.
+func (s *wsSession) send(message serverMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	if len(s.queued) >= s.config.Buffer {
+		s.close(websocket.ClosePolicyViolation, "too slow")
+		return
+	}
+	s.queued = append(s.queued, data)
+}
+
+func (s *wsSession) read(commands chan<- clientMessage) {
+	defer s.close(websocket.CloseNormalClosure, "")
+
+	s.conn.SetReadLimit(maxClientMessageSize)
+	_ = s.conn.SetReadDeadline(time.Now().Add(2 * s.config.PingInterval))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(2 * s.config.PingInterval))
+	})
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var command clientMessage
+		if err := json.Unmarshal(data, &command); err != nil {
+			command = clientMessage{invalid: true}
+		}
+		select {
+		case commands <- command:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *wsSession) write() {
+	ping := time.NewTicker(s.config.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case data := <-s.outbox:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+			if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				s.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.config.WriteTimeout)); err != nil {
+				s.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// close tells the client why the connection ends, when it can still be told, and closes it.
+func (s *wsSession) close(code int, reason string) {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		message := websocket.FormatCloseMessage(code, reason)
+		_ = s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(s.config.WriteTimeout))
+		_ = s.conn.Close()
+	})
+}
+
+func (f rocketFilter) matches(rocket rockets.Rocket) bool {
+	return (len(f.Channels) == 0 || slices.Contains(f.Channels, rocket.Channel)) &&
+		(len(f.Missions) == 0 || slices.Contains(f.Missions, rocket.Mission)) &&
+		(len(f.Types) == 0 || slices.Contains(f.Types, rocket.Type)) &&
+		(len(f.Statuses) == 0 || slices.Contains(f.Statuses, rocket.Status))
+}
+
+// rocketFields returns the rocket as the JSON fields of the API.
+func rocketFields(rocket rockets.Rocket) map[string]any {
+	data, _ := json.Marshal(toAPIRocket(rocket))
+	var fields map[string]any
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
+
+// diffFields returns the fields that are new or changed in current, and the ones it lost as nil.
+func diffFields(previous, current map[string]any) map[string]any {
+	diff := make(map[string]any)
+	for field, value := range current {
+		if before, exists := previous[field]; !exists || !reflect.DeepEqual(before, value) {
+			diff[field] = value
+		}
+	}
+	for field := range previous {
+		if _, exists := current[field]; !exists {
+			diff[field] = nil
+		}
+	}
+	return diff
+}
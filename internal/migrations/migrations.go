@@ -0,0 +1,134 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All are the migrations of the service, a new one takes the next version and the existing ones are never changed.
+var All = []Migration{
+	{
+		Version:     1,
+		Description: "unique channel and message number of messages",
+		Up: createIndexes("messages", mongo.IndexModel{
+			Keys:    bson.D{{Key: "metadata.channel", Value: 1}, {Key: "metadata.messageNumber", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     2,
+		Description: "unique channel of rockets",
+		Up: createIndexes("rockets", mongo.IndexModel{
+			Keys:    bson.D{{Key: "channel", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     3,
+		Description: "message time of messages, for the fleet at a past instant",
+		Up: createIndexes("messages", mongo.IndexModel{
+			Keys: bson.D{{Key: "metadata.messageTime", Value: 1}},
+		}),
+	},
+	{
+		Version:     4,
+		Description: "sort fields of rockets",
+		Up: createIndexes("rockets",
+			mongo.IndexModel{Keys: bson.D{{Key: "type", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "speed", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "mission", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}}},
+		),
+	},
+	{
+		Version:     5,
+		Description: "version and pending messages of rockets stored before they existed",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			rockets := db.Collection("rockets")
+			if _, err := rockets.UpdateMany(ctx, bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": 0}}); err != nil {
+				return err
+			}
+			_, err := rockets.UpdateMany(ctx, bson.M{"pendingMessages": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"pendingMessages": 0}})
+			return err
+		},
+	},
+	{
+		Version:     6,
+		Description: "unique channel of archived rockets",
+		Up: createIndexes("archive", mongo.IndexModel{
+			Keys:    bson.D{{Key: "channel", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     7,
+		Description: "unique id of webhooks",
+		Up: createIndexes("webhooks", mongo.IndexModel{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     8,
+		Description: "unique id and failure time of dead letter webhook deliveries",
+		Up: createIndexes("deadLetters",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "failedAt", Value: 1}}},
+		),
+	},
+	{
+		Version:     9,
+		Description: "sort fields and channel of rockets, for pages of the fleet",
+		Up: createIndexes("rockets",
+			mongo.IndexModel{Keys: bson.D{{Key: "type", Value: 1}, {Key: "channel", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "speed", Value: 1}, {Key: "channel", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "mission", Value: 1}, {Key: "channel", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "channel", Value: 1}}},
+		),
+	},
+	{
+		Version:     10,
+		Description: "last message time of rockets, for the fleet filters",
+		Up: createIndexes("rockets", mongo.IndexModel{
+			Keys: bson.D{{Key: "lastMessageTime", Value: 1}},
+		}),
+	},
+	{
+		Version:     11,
+		Description: "derived metrics of rockets stored before they existed, and their sort fields",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// The peak speed is at least the current one, the other metrics need a rebuild of the fleet
+			missing := bson.M{"peakSpeed": bson.M{"$exists": false}}
+			derived := bson.A{bson.M{"$set": bson.M{
+				"peakSpeed":         "$speed",
+				"totalAcceleration": 0,
+				"missionChanges":    0,
+				"flightDuration":    0.0,
+				"distance":          0.0,
+			}}}
+			if _, err := db.Collection("rockets").UpdateMany(ctx, missing, derived); err != nil {
+				return err
+			}
+			return createIndexes("rockets",
+				mongo.IndexModel{Keys: bson.D{{Key: "peakSpeed", Value: 1}, {Key: "channel", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "totalAcceleration", Value: 1}, {Key: "channel", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "missionChanges", Value: 1}, {Key: "channel", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "launchTime", Value: 1}, {Key: "channel", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "flightDuration", Value: 1}, {Key: "channel", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "distance", Value: 1}, {Key: "channel", Value: 1}}},
+			)(ctx, db)
+		},
+	},
+}
+
+// createIndexes returns a migration creating the indexes on the collection. Creating an index that already exists with
+// the same keys and options does nothing, so it can run again.
+func createIndexes(collection string, indexes ...mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
@@ -0,0 +1,157 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// appliedCollection records a document per applied migration, keyed by its version.
+	appliedCollection = "migrations"
+	// lockCollection holds the lease of the replica running the migrations.
+	lockCollection = "migrationLock"
+	lockID         = "migrations"
+)
+
+// Migration changes the database from the previous version to Version. Up must be safe to run again after a crash
+// halfway through, since the migration is only recorded once it returns.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Runner applies the migrations a database is missing, in version order. Replicas starting at once take turns through
+// a lease in the database: the first one applies the migrations and the others wait, then find nothing left to do.
+type Runner struct {
+	db         *mongo.Database
+	migrations []Migration
+	// Lease is how long a replica holds the lock, a replica that crashes while migrating blocks the others until then.
+	Lease time.Duration
+	// PollInterval is how often a waiting replica checks the lock.
+	PollInterval time.Duration
+	owner        string
+}
+
+func NewRunner(db *mongo.Database, migrations []Migration) *Runner {
+	hostname, _ := os.Hostname()
+	return &Runner{
+		db:           db,
+		migrations:   migrations,
+		Lease:        5 * time.Minute,
+		PollInterval: 500 * time.Millisecond,
+		owner:        hostname + "/" + uuid.NewString(),
+	}
+}
+
+// Migrate applies every pending migration of the service to the database.
+func Migrate(ctx context.Context, db *mongo.Database) error {
+	return NewRunner(db, All).Run(ctx)
+}
+
+// Run applies the pending migrations and returns once the database is at the last version. It waits for another replica
+// migrating the same database until ctx is done.
+func (r *Runner) Run(ctx context.Context) error {
+	if err := r.lock(ctx); err != nil {
+		return fmt.Errorf("locking migrations: %w", err)
+	}
+	defer r.unlock()
+
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return fmt.Errorf("reading applied migrations: %w", err)
+	}
+
+	pending := make([]Migration, 0, len(r.migrations))
+	for _, migration := range r.migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+
+	for _, migration := range pending {
+		started := time.Now()
+		if err := migration.Up(ctx, r.db); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		_, err := r.db.Collection(appliedCollection).InsertOne(ctx, bson.M{
+			"_id":         migration.Version,
+			"description": migration.Description,
+			"appliedAt":   time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+		slog.Info("applied migration", "version", migration.Version, "description", migration.Description, "duration", time.Since(started))
+	}
+	return nil
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]bool, error) {
+	cursor, err := r.db.Collection(appliedCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []struct {
+		Version int `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(records))
+	for _, record := range records {
+		applied[record.Version] = true
+	}
+	return applied, nil
+}
+
+// lock takes the lease once it is free or expired. While another replica holds it the filter matches nothing, so the
+// upsert tries to insert a second lock document and fails on its id.
+func (r *Runner) lock(ctx context.Context) error {
+	locks := r.db.Collection(lockCollection)
+	for {
+		now := time.Now()
+		_, err := locks.UpdateOne(ctx,
+			bson.M{"_id": lockID, "expiresAt": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": r.owner, "expiresAt": now.Add(r.Lease)}},
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+
+		slog.Info("waiting for another replica to finish the migrations")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// unlock releases the lease if it is still ours, it may have expired and been taken by another replica.
+func (r *Runner) unlock() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.db.Collection(lockCollection).DeleteOne(ctx, bson.M{"_id": lockID, "owner": r.owner})
+	if err != nil {
+		slog.Error("Error releasing migration lock", "error", err)
+	}
+}
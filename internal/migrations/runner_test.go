@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestRunner_ReplicasStartingAtOnce(t *testing.T) {
+	db := setupMongoDB(t).Database("migrations_replicas")
+	ctx := context.Background()
+
+	var runs atomic.Int32
+	counted := append([]Migration(nil), All...)
+	counted = append(counted, Migration{
+		Version:     len(All) + 1,
+		Description: "counts its runs",
+		Up: func(context.Context, *mongo.Database) error {
+			runs.Add(1)
+			time.Sleep(200 * time.Millisecond)
+			return nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	for replica := 0; replica < 5; replica++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner := NewRunner(db, counted)
+			runner.PollInterval = 10 * time.Millisecond
+			assert.NoError(t, runner.Run(ctx))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), runs.Load())
+	applied, err := db.Collection(appliedCollection).CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(counted)), applied)
+	locks, err := db.Collection(lockCollection).CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Zero(t, locks)
+
+	// The unique index of migration 1 rejects a second message with the same number
+	messages := db.Collection("messages")
+	_, err = messages.InsertOne(ctx, bson.M{"metadata": bson.M{"channel": "c", "messageNumber": 1}})
+	require.NoError(t, err)
+	_, err = messages.InsertOne(ctx, bson.M{"metadata": bson.M{"channel": "c", "messageNumber": 1}})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+}
+
+func TestRunner_TakesExpiredLease(t *testing.T) {
+	db := setupMongoDB(t).Database("migrations_lease")
+	ctx := context.Background()
+
+	// A replica crashed while migrating and left its lease behind
+	_, err := db.Collection(lockCollection).InsertOne(ctx, bson.M{"_id": lockID, "owner": "crashed", "expiresAt": time.Now().Add(time.Second)})
+	require.NoError(t, err)
+
+	runner := NewRunner(db, All)
+	runner.PollInterval = 50 * time.Millisecond
+	require.NoError(t, runner.Run(ctx))
+
+	applied, err := db.Collection(appliedCollection).CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(All)), applied)
+}
+
+func TestRunner_GivesUpWhileLocked(t *testing.T) {
+	db := setupMongoDB(t).Database("migrations_locked")
+	ctx := context.Background()
+
+	_, err := db.Collection(lockCollection).InsertOne(ctx, bson.M{"_id": lockID, "owner": "other", "expiresAt": time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	timeout, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, NewRunner(db, All).Run(timeout), context.DeadlineExceeded)
+}
+
+func setupMongoDB(t *testing.T) *mongo.Client {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	mongoContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mongo:8.0",
+			ExposedPorts: []string{"27017/tcp"},
+			WaitingFor:   wait.ForLog("Waiting for connections").WithStartupTimeout(60 * time.Second),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mongoContainer.Terminate(context.Background()) })
+
+	host, err := mongoContainer.Host(ctx)
+	require.NoError(t, err)
+	port, err := mongoContainer.MappedPort(ctx, "27017")
+	require.NoError(t, err)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+host+":"+port.Port()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	require.NoError(t, client.Ping(ctx, nil))
+	return client
+}
@@ -0,0 +1,368 @@
+package rockets
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Backpressure string
+
+const (
+	// BackpressureReject fails Ingest with ErrQueueFull when the channel queue is full.
+	BackpressureReject Backpressure = "reject"
+	// BackpressureBlock makes Ingest wait until the channel queue has room again.
+	BackpressureBlock Backpressure = "block"
+)
+
+type AsyncConfig struct {
+	// Workers is the number of goroutines processing queued messages.
+	Workers int
+	// QueueSize is the maximum number of messages waiting to be processed per channel.
+	QueueSize int
+	// Backpressure decides what happens when a channel queue is full.
+	Backpressure Backpressure
+	// MaxAttempts is how many times queued messages are processed before giving up on them. They stay in the log, so
+	// the next message of the channel, or Recover on the next start, applies them.
+	MaxAttempts int
+	// RetryBackoff is the wait after the first failed attempt, it doubles after each following one.
+	RetryBackoff time.Duration
+}
+
+type channelQueue struct {
+	// batches holds the messages queued by each Ingest or IngestBatch call, every batch takes one slot.
+	batches   [][]Message
+	pending   int
+	scheduled bool
+}
+
+// AsyncMessageService stores messages synchronously and processes them in the background. Each channel has its own
+// bounded queue, and a channel is only handled by one worker at a time, so messages are processed in arrival order.
+type AsyncMessageService struct {
+	messageRepository MessageRepository
+	processor         MessageProcessor
+	config            AsyncConfig
+
+	mu       sync.Mutex
+	queues   map[uuid.UUID]*channelQueue
+	ready    []uuid.UUID
+	work     *sync.Cond
+	released chan struct{}
+	// stopped is closed on shutdown, so a retry doesn't wait for its backoff to drain the queues.
+	stopped  chan struct{}
+	closed   bool
+	draining bool
+	inflight sync.WaitGroup
+	workers  sync.WaitGroup
+}
+
+func NewAsyncMessageService(messageRepository MessageRepository, processor MessageProcessor, config AsyncConfig) *AsyncMessageService {
+	if config.Workers < 1 {
+		config.Workers = runtime.NumCPU()
+	}
+	if config.QueueSize < 1 {
+		config.QueueSize = 100
+	}
+	if config.Backpressure == "" {
+		config.Backpressure = BackpressureReject
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 100 * time.Millisecond
+	}
+
+	m := &AsyncMessageService{
+		messageRepository: messageRepository,
+		processor:         processor,
+		config:            config,
+		queues:            make(map[uuid.UUID]*channelQueue),
+		released:          make(chan struct{}),
+		stopped:           make(chan struct{}),
+	}
+	m.work = sync.NewCond(&m.mu)
+
+	m.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go m.worker()
+	}
+	return m
+}
+
+// Ingest stores the message and queues it for processing. It returns once the message is durable, before it is
+// applied to the rocket.
+func (m *AsyncMessageService) Ingest(ctx context.Context, message Message) error {
+	channel := message.Metadata.Channel
+	if err := m.reserve(ctx, channel); err != nil {
+		return err
+	}
+	defer m.inflight.Done()
+
+	storeErr := m.messageRepository.Store(ctx, message)
+	if storeErr != nil && !errors.Is(storeErr, ErrDuplicateMessage) {
+		m.release(channel)
+		return storeErr
+	}
+
+	m.enqueue([]Message{message})
+	return storeErr
+}
+
+// IngestBatch stores the messages and queues each affected channel once. A batch takes a single slot in the queue of
+// each channel and its messages are processed together.
+func (m *AsyncMessageService) IngestBatch(ctx context.Context, messages []Message) []IngestResult {
+	results := make([]IngestResult, len(messages))
+	channels, groups := groupByChannel(messages)
+	for _, channel := range channels {
+		if err := m.reserve(ctx, channel); err != nil {
+			for _, i := range groups[channel] {
+				results[i] = newIngestResult(err)
+			}
+			continue
+		}
+
+		var stored []Message
+		for _, i := range groups[channel] {
+			err := m.messageRepository.Store(ctx, messages[i])
+			results[i] = newIngestResult(err)
+			if err == nil || errors.Is(err, ErrDuplicateMessage) {
+				stored = append(stored, messages[i])
+			}
+		}
+
+		if len(stored) == 0 {
+			m.release(channel)
+		} else {
+			m.enqueue(stored)
+		}
+		m.inflight.Done()
+	}
+	return results
+}
+
+// Async is true: messages are applied by the workers after Ingest returns.
+func (m *AsyncMessageService) Async() bool {
+	return true
+}
+
+// Recover queues the channels whose log runs past the last message applied to their rocket: messages acknowledged
+// before a crash, or given up on after failing every attempt. It runs on startup and returns how many channels it
+// queued. Channels waiting behind a gap are queued too, which also sets their gap timers again.
+func (m *AsyncMessageService) Recover(ctx context.Context, rocketsRepository RocketsRepository) (int, error) {
+	channels, err := m.messageRepository.Channels(ctx)
+	if err != nil {
+		return 0, err
+	}
+	page, err := rocketsRepository.All(ctx, RocketQuery{})
+	if err != nil {
+		return 0, err
+	}
+	lastApplied := make(map[uuid.UUID]int, len(page.Rockets))
+	for _, rocket := range page.Rockets {
+		if rocket.LastMessageNumber != nil {
+			lastApplied[rocket.Channel] = *rocket.LastMessageNumber
+		}
+	}
+
+	queued := 0
+	for _, channel := range channels {
+		unapplied, err := m.messageRepository.FindAfterNumber(ctx, channel, lastApplied[channel])
+		if err != nil {
+			return queued, err
+		}
+		if len(unapplied) == 0 {
+			continue
+		}
+		if err := m.reserve(ctx, channel); err != nil {
+			return queued, err
+		}
+		// Processing only depends on the channel, it reads the messages from the log
+		m.enqueue([]Message{{Metadata: Metadata{Channel: channel}}})
+		m.inflight.Done()
+		queued++
+	}
+	return queued, nil
+}
+
+func (m *AsyncMessageService) Process(ctx context.Context, message Message) error {
+	return m.processor.Process(ctx, message)
+}
+
+func (m *AsyncMessageService) ProcessBatch(ctx context.Context, messages []Message) error {
+	return m.processor.ProcessBatch(ctx, messages)
+}
+
+// Shutdown stops accepting messages, waits until every queued message has been processed, then shuts the processor
+// down.
+func (m *AsyncMessageService) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		close(m.stopped)
+	}
+	m.closed = true
+	m.notifyReleased()
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.inflight.Wait()
+		m.mu.Lock()
+		m.draining = true
+		m.work.Broadcast()
+		m.mu.Unlock()
+		m.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return m.processor.Shutdown(ctx)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes a slot in the channel queue, applying the configured backpressure when it is full.
+func (m *AsyncMessageService) reserve(ctx context.Context, channel uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		if m.closed {
+			return ErrShuttingDown
+		}
+		queue, exists := m.queues[channel]
+		if !exists {
+			queue = &channelQueue{}
+			m.queues[channel] = queue
+		}
+		if queue.pending < m.config.QueueSize {
+			queue.pending++
+			m.inflight.Add(1)
+			return nil
+		}
+		if m.config.Backpressure != BackpressureBlock {
+			return ErrQueueFull
+		}
+
+		released := m.released
+		m.mu.Unlock()
+		select {
+		case <-released:
+			m.mu.Lock()
+		case <-ctx.Done():
+			m.mu.Lock()
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees a slot in the channel queue and forgets the queue once it is empty.
+func (m *AsyncMessageService) release(channel uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue := m.queues[channel]
+	queue.pending--
+	if queue.pending == 0 && !queue.scheduled {
+		delete(m.queues, channel)
+	}
+	m.notifyReleased()
+}
+
+func (m *AsyncMessageService) notifyReleased() {
+	close(m.released)
+	m.released = make(chan struct{})
+}
+
+func (m *AsyncMessageService) enqueue(batch []Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channel := batch[0].Metadata.Channel
+	queue := m.queues[channel]
+	queue.batches = append(queue.batches, batch)
+	if !queue.scheduled {
+		queue.scheduled = true
+		m.ready = append(m.ready, channel)
+		m.work.Signal()
+	}
+}
+
+func (m *AsyncMessageService) worker() {
+	defer m.workers.Done()
+
+	for {
+		m.mu.Lock()
+		for len(m.ready) == 0 && !m.draining {
+			m.work.Wait()
+		}
+		if len(m.ready) == 0 {
+			m.mu.Unlock()
+			return
+		}
+		channel := m.ready[0]
+		m.ready = m.ready[1:]
+		m.mu.Unlock()
+
+		m.drain(channel)
+	}
+}
+
+// drain processes the queued messages of a channel until its queue is empty.
+func (m *AsyncMessageService) drain(channel uuid.UUID) {
+	for {
+		m.mu.Lock()
+		queue := m.queues[channel]
+		if len(queue.batches) == 0 {
+			queue.scheduled = false
+			if queue.pending == 0 {
+				delete(m.queues, channel)
+			}
+			m.mu.Unlock()
+			return
+		}
+		batch := queue.batches[0]
+		queue.batches = queue.batches[1:]
+		m.mu.Unlock()
+
+		if err := m.process(batch); err != nil {
+			slog.Error("error processing queued messages", "channel", channel, "messages", len(batch), "error", err)
+		}
+		m.release(channel)
+	}
+}
+
+// process applies a queued batch, trying again with a growing wait while it fails. Retries stop on shutdown, the
+// messages are in the log for the next start.
+func (m *AsyncMessageService) process(batch []Message) error {
+	wait := m.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		// The request that queued the messages is gone, so processing runs on its own context.
+		var err error
+		if len(batch) == 1 {
+			err = m.processor.Process(context.Background(), batch[0])
+		} else {
+			err = m.processor.ProcessBatch(context.Background(), batch)
+		}
+		if err == nil || attempt == m.config.MaxAttempts {
+			return err
+		}
+
+		slog.Warn("error processing queued messages, retrying", "channel", batch[0].Metadata.Channel, "attempt", attempt, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-m.stopped:
+			timer.Stop()
+			return err
+		}
+		wait *= 2
+	}
+}
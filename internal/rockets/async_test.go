@@ -0,0 +1,195 @@
+package rockets
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProcessor struct {
+	mu        sync.Mutex
+	gate      chan struct{}
+	processed map[uuid.UUID][]int
+}
+
+func newRecordingProcessor(blocked bool) *recordingProcessor {
+	p := &recordingProcessor{gate: make(chan struct{}), processed: make(map[uuid.UUID][]int)}
+	if !blocked {
+		close(p.gate)
+	}
+	return p
+}
+
+func (p *recordingProcessor) Process(_ context.Context, message Message) error {
+	<-p.gate
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed[message.Metadata.Channel] = append(p.processed[message.Metadata.Channel], message.Metadata.MessageNumber)
+	return nil
+}
+
+func (p *recordingProcessor) ProcessBatch(ctx context.Context, messages []Message) error {
+	for _, message := range messages {
+		if err := p.Process(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *recordingProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+type discardingMessageRepository struct {
+	MessageRepository
+}
+
+func (discardingMessageRepository) Store(context.Context, Message) error {
+	return nil
+}
+
+func asyncTestMessage(channel uuid.UUID, number int) Message {
+	return Message{Metadata: Metadata{Channel: channel, MessageNumber: number, MessageTime: time.Now(), MessageType: "RocketSpeedIncreased"}}
+}
+
+func TestAsyncMessageService_KeepsChannelOrderAndDrainsOnShutdown(t *testing.T) {
+	processor := newRecordingProcessor(false)
+	service := NewAsyncMessageService(discardingMessageRepository{}, processor, AsyncConfig{Workers: 4, QueueSize: 100})
+
+	channels := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for number := 1; number <= 50; number++ {
+		for _, channel := range channels {
+			require.NoError(t, service.Ingest(context.Background(), asyncTestMessage(channel, number)))
+		}
+	}
+
+	require.NoError(t, service.Shutdown(context.Background()))
+
+	for _, channel := range channels {
+		require.Len(t, processor.processed[channel], 50)
+		for i, number := range processor.processed[channel] {
+			assert.Equal(t, i+1, number)
+		}
+	}
+
+	err := service.Ingest(context.Background(), asyncTestMessage(channels[0], 51))
+	assert.ErrorIs(t, err, ErrShuttingDown)
+}
+
+func TestAsyncMessageService_RejectsWhenChannelQueueIsFull(t *testing.T) {
+	processor := newRecordingProcessor(true)
+	service := NewAsyncMessageService(discardingMessageRepository{}, processor, AsyncConfig{Workers: 1, QueueSize: 2, Backpressure: BackpressureReject})
+
+	channel := uuid.New()
+	require.NoError(t, service.Ingest(context.Background(), asyncTestMessage(channel, 1)))
+	require.NoError(t, service.Ingest(context.Background(), asyncTestMessage(channel, 2)))
+	assert.ErrorIs(t, service.Ingest(context.Background(), asyncTestMessage(channel, 3)), ErrQueueFull)
+
+	// Other channels have their own queue
+	assert.NoError(t, service.Ingest(context.Background(), asyncTestMessage(uuid.New(), 1)))
+
+	close(processor.gate)
+	require.NoError(t, service.Shutdown(context.Background()))
+	assert.Equal(t, []int{1, 2}, processor.processed[channel])
+}
+
+func TestAsyncMessageService_BlocksUntilChannelQueueHasRoom(t *testing.T) {
+	processor := newRecordingProcessor(true)
+	service := NewAsyncMessageService(discardingMessageRepository{}, processor, AsyncConfig{Workers: 1, QueueSize: 1, Backpressure: BackpressureBlock})
+
+	channel := uuid.New()
+	require.NoError(t, service.Ingest(context.Background(), asyncTestMessage(channel, 1)))
+
+	// The wait honours the request context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, service.Ingest(ctx, asyncTestMessage(channel, 2)), context.DeadlineExceeded)
+
+	ingested := make(chan error)
+	go func() {
+		ingested <- service.Ingest(context.Background(), asyncTestMessage(channel, 2))
+	}()
+
+	select {
+	case <-ingested:
+		t.Fatal("ingest should wait while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(processor.gate)
+	require.NoError(t, <-ingested)
+	require.NoError(t, service.Shutdown(context.Background()))
+	assert.Equal(t, []int{1, 2}, processor.processed[channel])
+}
+
+// failingOnceRocketsRepository fails the first save of a rocket, like a database going away for a moment.
+type failingOnceRocketsRepository struct {
+	*MemoryRocketsRepository
+	failed atomic.Bool
+}
+
+func (r *failingOnceRocketsRepository) Upsert(ctx context.Context, rocket Rocket) error {
+	if r.failed.CompareAndSwap(false, true) {
+		return errors.New("connection reset")
+	}
+	return r.MemoryRocketsRepository.Upsert(ctx, rocket)
+}
+
+func TestAsyncMessageService_RetriesFailedProcessing(t *testing.T) {
+	ctx := context.Background()
+	messageRepository := NewMemoryMessageRepository()
+	rocketsRepository := &failingOnceRocketsRepository{MemoryRocketsRepository: NewMemoryRocketsRepository()}
+	processor := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	service := NewAsyncMessageService(messageRepository, processor, AsyncConfig{Workers: 1, RetryBackoff: time.Millisecond})
+
+	channel := uuid.New()
+	for _, message := range flight(channel, 3, time.Now()) {
+		require.NoError(t, service.Ingest(ctx, message))
+	}
+	require.NoError(t, service.Shutdown(ctx))
+
+	assert.True(t, rocketsRepository.failed.Load())
+	rocket, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, 3, *rocket.LastMessageNumber)
+	assert.Equal(t, 520, rocket.Speed)
+}
+
+func TestAsyncMessageService_RecoversUnprocessedChannels(t *testing.T) {
+	ctx := context.Background()
+	messageRepository := NewMemoryMessageRepository()
+	rocketsRepository := &failingOnceRocketsRepository{MemoryRocketsRepository: NewMemoryRocketsRepository()}
+	processor := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+
+	// A run that gave up on the channel: its messages are stored but the rocket never saved
+	service := NewAsyncMessageService(messageRepository, processor, AsyncConfig{Workers: 1, MaxAttempts: 1})
+	stuck, applied := uuid.New(), uuid.New()
+	for _, result := range service.IngestBatch(ctx, flight(stuck, 2, time.Now())) {
+		require.Equal(t, IngestAccepted, result.Status)
+	}
+	require.NoError(t, service.Shutdown(ctx))
+	for _, message := range flight(applied, 2, time.Now()) {
+		require.NoError(t, processor.Ingest(ctx, message))
+	}
+	_, err := rocketsRepository.FindByChannel(ctx, stuck)
+	require.ErrorIs(t, err, ErrRocketNotFound)
+
+	restarted := NewAsyncMessageService(messageRepository, processor, AsyncConfig{Workers: 1})
+	queued, err := restarted.Recover(ctx, rocketsRepository)
+	require.NoError(t, err)
+	assert.Equal(t, 1, queued)
+	require.NoError(t, restarted.Shutdown(ctx))
+
+	rocket, err := rocketsRepository.FindByChannel(ctx, stuck)
+	require.NoError(t, err)
+	assert.Equal(t, 2, *rocket.LastMessageNumber)
+	assert.Equal(t, 510, rocket.Speed)
+}
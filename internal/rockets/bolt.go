@@ -0,0 +1,625 @@
+package rockets
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	messagesBucket    = []byte("messages")
+	rocketsBucket     = []byte("rockets")
+	archiveBucket     = []byte("archive")
+	webhooksBucket    = []byte("webhooks")
+	deadLettersBucket = []byte("deadLetters")
+)
+
+// OpenBolt opens the database file, creating it when it does not exist. A second process opening the same file waits
+// for the timeout and fails.
+func OpenBolt(path string) (*bolt.DB, error) {
+	return bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+}
+
+// BoltMessageRepository keeps the log in an embedded bolt database, with a bucket per channel where messages are keyed
+// by number, so reading a channel in order is a cursor scan.
+type BoltMessageRepository struct {
+	db *bolt.DB
+}
+
+func NewBoltMessageRepository(db *bolt.DB) *BoltMessageRepository {
+	return &BoltMessageRepository{
+		db: db,
+	}
+}
+
+// EnsureBuckets creates the buckets the repository writes to.
+func (r BoltMessageRepository) EnsureBuckets(_ context.Context) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(messagesBucket)
+		return err
+	})
+}
+
+// boltMessage is the stored form of a message, the fields set by the repository are not part of the JSON of Message.
+type boltMessage struct {
+	Metadata      Metadata               `json:"metadata"`
+	Message       map[string]interface{} `json:"message"`
+	ReceivedAt    time.Time              `json:"receivedAt"`
+	Redeliveries  int                    `json:"redeliveries"`
+	RedeliveredAt time.Time              `json:"redeliveredAt"`
+}
+
+func (r BoltMessageRepository) Store(_ context.Context, message Message) error {
+	message.Metadata.MessageTime = storedTime(message.Metadata.MessageTime)
+	value, err := json.Marshal(boltMessage{
+		Metadata:   message.Metadata,
+		Message:    message.Message,
+		ReceivedAt: storedTime(time.Now()),
+	})
+	if err != nil {
+		slog.Error("Error encoding message", "error", err)
+		return errors.New(StoreMessageError)
+	}
+
+	// storeErr is the duplicate or conflict found. It isn't returned from the transaction, which would roll back the
+	// count of the redelivery.
+	var storeErr error
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		channel, err := tx.Bucket(messagesBucket).CreateBucketIfNotExists(message.Metadata.Channel[:])
+		if err != nil {
+			return err
+		}
+		key := messageKey(message.Metadata.MessageNumber)
+		stored := channel.Get(key)
+		if stored == nil {
+			return channel.Put(key, value)
+		}
+
+		var storedMessage boltMessage
+		if err := json.Unmarshal(stored, &storedMessage); err != nil {
+			return err
+		}
+		existing := Message{Metadata: storedMessage.Metadata, Message: storedMessage.Message}
+		if storeErr = duplicateOf(existing, message); errors.Is(storeErr, ErrConflictingMessage) {
+			return nil
+		}
+		storedMessage.Redeliveries++
+		storedMessage.RedeliveredAt = storedTime(time.Now())
+		redelivered, err := json.Marshal(storedMessage)
+		if err != nil {
+			return err
+		}
+		return channel.Put(key, redelivered)
+	})
+	if err != nil {
+		slog.Error("Error storing message", "error", err)
+		return errors.New(StoreMessageError)
+	}
+	return storeErr
+}
+
+func (r BoltMessageRepository) FindByChannel(ctx context.Context, channel uuid.UUID) ([]Message, error) {
+	return r.findAfter(channel, nil)
+}
+
+func (r BoltMessageRepository) FindAfterNumber(_ context.Context, channel uuid.UUID, number int) ([]Message, error) {
+	return r.findAfter(channel, &number)
+}
+
+func (r BoltMessageRepository) Channels(_ context.Context) ([]uuid.UUID, error) {
+	channels := []uuid.UUID{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).ForEachBucket(func(key []byte) error {
+			channel, err := uuid.FromBytes(key)
+			if err != nil {
+				return err
+			}
+			channels = append(channels, channel)
+			return nil
+		})
+	})
+	return channels, err
+}
+
+func (r BoltMessageRepository) Compact(_ context.Context, snapshot Message) (int, error) {
+	value, err := json.Marshal(boltMessage{Metadata: snapshot.Metadata, Message: snapshot.Message, ReceivedAt: snapshot.ReceivedAt})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		channel, err := tx.Bucket(messagesBucket).CreateBucketIfNotExists(snapshot.Metadata.Channel[:])
+		if err != nil {
+			return err
+		}
+		cursor := channel.Cursor()
+		for key, _ := cursor.First(); key != nil && binaryNumber(key) < snapshot.Metadata.MessageNumber; key, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return channel.Put(messageKey(snapshot.Metadata.MessageNumber), value)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (r BoltMessageRepository) DeleteChannel(_ context.Context, channel uuid.UUID) (int, error) {
+	deleted := 0
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(messagesBucket)
+		bucket := messages.Bucket(channel[:])
+		if bucket == nil {
+			return nil
+		}
+		deleted = bucket.Stats().KeyN
+		return messages.DeleteBucket(channel[:])
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// findAfter returns the messages of the channel after the number, or all of them when there is no number.
+func (r BoltMessageRepository) FindAfterNumbers(_ context.Context, after map[uuid.UUID]int) ([]Message, error) {
+	messages := []Message{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		for channel, number := range after {
+			if err := readAfter(tx, channel, &number, &messages); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r BoltMessageRepository) findAfter(channel uuid.UUID, number *int) ([]Message, error) {
+	messages := []Message{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return readAfter(tx, channel, number, &messages)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// readAfter appends the messages of the channel after the number, or all of them when it is nil.
+func readAfter(tx *bolt.Tx, channel uuid.UUID, number *int, messages *[]Message) error {
+	bucket := tx.Bucket(messagesBucket).Bucket(channel[:])
+	if bucket == nil {
+		return nil
+	}
+
+	cursor := bucket.Cursor()
+	key, value := cursor.First()
+	if number != nil {
+		key, value = cursor.Seek(messageKey(*number))
+		if key != nil && binaryNumber(key) == *number {
+			key, value = cursor.Next()
+		}
+	}
+	for ; key != nil; key, value = cursor.Next() {
+		message, err := decodeMessage(value)
+		if err != nil {
+			return err
+		}
+		*messages = append(*messages, message)
+	}
+	return nil
+}
+
+// messageKey encodes the number so the byte order of the keys is the order of the numbers, negative ones included.
+func messageKey(number int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(number)^1<<63)
+	return key
+}
+
+func binaryNumber(key []byte) int {
+	return int(binary.BigEndian.Uint64(key) ^ 1<<63)
+}
+
+func decodeMessage(value []byte) (Message, error) {
+	var stored boltMessage
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Metadata:      stored.Metadata,
+		Message:       stored.Message,
+		ReceivedAt:    stored.ReceivedAt,
+		Redeliveries:  stored.Redeliveries,
+		RedeliveredAt: stored.RedeliveredAt,
+	}, nil
+}
+
+// BoltRocketsRepository keeps the rockets in an embedded bolt database, keyed by channel. Writes are serialized by
+// bolt, so the version check of Upsert and the write happen in the same transaction.
+type BoltRocketsRepository struct {
+	db *bolt.DB
+}
+
+func NewBoltRocketsRepository(db *bolt.DB) *BoltRocketsRepository {
+	return &BoltRocketsRepository{
+		db: db,
+	}
+}
+
+// EnsureBuckets creates the buckets the repository writes to.
+func (m BoltRocketsRepository) EnsureBuckets(_ context.Context) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(rocketsBucket)
+		return err
+	})
+}
+
+// boltRocket is the stored form of a rocket, the version is not part of the JSON of Rocket.
+type boltRocket struct {
+	Rocket
+	Version int `json:"version"`
+}
+
+func (m BoltRocketsRepository) All(_ context.Context, query RocketQuery) (*RocketPage, error) {
+	rockets, err := m.all()
+	if err != nil {
+		return nil, err
+	}
+	return pageRockets(rockets, query)
+}
+
+func (m BoltRocketsRepository) Stats(_ context.Context, query StatsQuery) (*FleetStats, error) {
+	rockets, err := m.all()
+	if err != nil {
+		return nil, err
+	}
+	return fleetStats(rockets, query), nil
+}
+
+func (m BoltRocketsRepository) all() ([]Rocket, error) {
+	rockets := []Rocket{}
+	err := m.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(rocketsBucket).ForEach(func(_, value []byte) error {
+			rocket, err := decodeRocket(value)
+			if err != nil {
+				return err
+			}
+			rockets = append(rockets, rocket)
+			return nil
+		})
+	})
+	return rockets, err
+}
+
+func (m BoltRocketsRepository) FindByChannel(_ context.Context, channel uuid.UUID) (*Rocket, error) {
+	var rocket *Rocket
+	err := m.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(rocketsBucket).Get(channel[:])
+		if value == nil {
+			return ErrRocketNotFound
+		}
+		stored, err := decodeRocket(value)
+		rocket = &stored
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rocket, nil
+}
+
+func (m BoltRocketsRepository) Upsert(_ context.Context, rocket Rocket) error {
+	if rocket.LaunchTime != nil {
+		launchTime := storedTime(*rocket.LaunchTime)
+		rocket.LaunchTime = &launchTime
+	}
+	if rocket.LastMessageTime != nil {
+		lastMessageTime := storedTime(*rocket.LastMessageTime)
+		rocket.LastMessageTime = &lastMessageTime
+	}
+	value, err := json.Marshal(boltRocket{Rocket: rocket, Version: rocket.Version + 1})
+	if err != nil {
+		slog.Error("Error encoding rocket", "error", err)
+		return errors.New(UpdateRocketError)
+	}
+
+	err = m.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(rocketsBucket)
+		version := 0
+		if stored := bucket.Get(rocket.Channel[:]); stored != nil {
+			storedRocket, err := decodeRocket(stored)
+			if err != nil {
+				return err
+			}
+			version = storedRocket.Version
+		}
+		if version != rocket.Version {
+			return ErrVersionConflict
+		}
+		return bucket.Put(rocket.Channel[:], value)
+	})
+	if errors.Is(err, ErrVersionConflict) {
+		return err
+	}
+	if err != nil {
+		slog.Error("Error updating rocket", "error", err)
+		return errors.New(UpdateRocketError)
+	}
+	return nil
+}
+
+func (m BoltRocketsRepository) Delete(_ context.Context, rocket Rocket) error {
+	err := m.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(rocketsBucket)
+		stored := bucket.Get(rocket.Channel[:])
+		if stored == nil {
+			return ErrVersionConflict
+		}
+		storedRocket, err := decodeRocket(stored)
+		if err != nil {
+			return err
+		}
+		if storedRocket.Version != rocket.Version {
+			return ErrVersionConflict
+		}
+		return bucket.Delete(rocket.Channel[:])
+	})
+	if errors.Is(err, ErrVersionConflict) {
+		return err
+	}
+	if err != nil {
+		slog.Error("Error deleting rocket", "error", err)
+		return errors.New(UpdateRocketError)
+	}
+	return nil
+}
+
+func decodeRocket(value []byte) (Rocket, error) {
+	var stored boltRocket
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return Rocket{}, err
+	}
+	stored.Rocket.Version = stored.Version
+	return stored.Rocket, nil
+}
+
+// BoltArchiveRepository keeps each archived rocket with its log under the channel key.
+type BoltArchiveRepository struct {
+	db *bolt.DB
+}
+
+func NewBoltArchiveRepository(db *bolt.DB) *BoltArchiveRepository {
+	return &BoltArchiveRepository{
+		db: db,
+	}
+}
+
+// EnsureBuckets creates the buckets the repository writes to.
+func (a BoltArchiveRepository) EnsureBuckets(_ context.Context) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(archiveBucket)
+		return err
+	})
+}
+
+// boltArchivedRocket is the stored form of an archived rocket, with the fields JSON leaves out of rockets and messages.
+type boltArchivedRocket struct {
+	Rocket     boltRocket    `json:"rocket"`
+	Messages   []boltMessage `json:"messages"`
+	ArchivedAt time.Time     `json:"archivedAt"`
+}
+
+func (a BoltArchiveRepository) Store(_ context.Context, archived ArchivedRocket) error {
+	stored := boltArchivedRocket{
+		Rocket:     boltRocket{Rocket: archived.Rocket, Version: archived.Rocket.Version},
+		Messages:   make([]boltMessage, 0, len(archived.Messages)),
+		ArchivedAt: archived.ArchivedAt,
+	}
+	for _, message := range archived.Messages {
+		stored.Messages = append(stored.Messages, boltMessage{Metadata: message.Metadata, Message: message.Message, ReceivedAt: message.ReceivedAt})
+	}
+	value, err := json.Marshal(stored)
+	if err == nil {
+		err = a.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(archiveBucket).Put(archived.Rocket.Channel[:], value)
+		})
+	}
+	if err != nil {
+		slog.Error("Error archiving rocket", "error", err)
+		return errors.New(UpdateRocketError)
+	}
+	return nil
+}
+
+func (a BoltArchiveRepository) FindByChannel(_ context.Context, channel uuid.UUID) (*ArchivedRocket, error) {
+	var stored boltArchivedRocket
+	err := a.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(archiveBucket).Get(channel[:])
+		if value == nil {
+			return ErrRocketNotFound
+		}
+		return json.Unmarshal(value, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	archived := &ArchivedRocket{Rocket: stored.Rocket.Rocket, ArchivedAt: stored.ArchivedAt}
+	archived.Rocket.Version = stored.Rocket.Version
+	for _, message := range stored.Messages {
+		archived.Messages = append(archived.Messages, Message{Metadata: message.Metadata, Message: message.Message, ReceivedAt: message.ReceivedAt})
+	}
+	return archived, nil
+}
+
+// BoltWebhookRepository keeps the webhooks under their ID. Keys are not ordered by subscription, All sorts them.
+type BoltWebhookRepository struct {
+	db *bolt.DB
+}
+
+func NewBoltWebhookRepository(db *bolt.DB) *BoltWebhookRepository {
+	return &BoltWebhookRepository{
+		db: db,
+	}
+}
+
+// EnsureBuckets creates the buckets the repository writes to.
+func (w BoltWebhookRepository) EnsureBuckets(_ context.Context) error {
+	return w.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(webhooksBucket)
+		return err
+	})
+}
+
+func (w BoltWebhookRepository) Store(_ context.Context, webhook Webhook) error {
+	value, err := json.Marshal(webhook)
+	if err == nil {
+		err = w.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(webhooksBucket).Put(webhook.ID[:], value)
+		})
+	}
+	if err != nil {
+		slog.Error("Error storing webhook", "error", err)
+		return errors.New(StoreWebhookError)
+	}
+	return nil
+}
+
+func (w BoltWebhookRepository) All(_ context.Context) ([]Webhook, error) {
+	webhooks := make([]Webhook, 0)
+	err := w.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).ForEach(func(_, value []byte) error {
+			var webhook Webhook
+			if err := json.Unmarshal(value, &webhook); err != nil {
+				return err
+			}
+			webhooks = append(webhooks, webhook)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (w BoltWebhookRepository) FindByID(_ context.Context, id uuid.UUID) (*Webhook, error) {
+	var webhook Webhook
+	err := w.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(webhooksBucket).Get(id[:])
+		if value == nil {
+			return ErrWebhookNotFound
+		}
+		return json.Unmarshal(value, &webhook)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (w BoltWebhookRepository) Delete(_ context.Context, id uuid.UUID) error {
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(webhooksBucket)
+		if bucket.Get(id[:]) == nil {
+			return ErrWebhookNotFound
+		}
+		return bucket.Delete(id[:])
+	})
+}
+
+// BoltDeadLetterRepository keeps the failed deliveries under their ID.
+type BoltDeadLetterRepository struct {
+	db *bolt.DB
+}
+
+func NewBoltDeadLetterRepository(db *bolt.DB) *BoltDeadLetterRepository {
+	return &BoltDeadLetterRepository{
+		db: db,
+	}
+}
+
+// EnsureBuckets creates the buckets the repository writes to.
+func (d BoltDeadLetterRepository) EnsureBuckets(_ context.Context) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(deadLettersBucket)
+		return err
+	})
+}
+
+func (d BoltDeadLetterRepository) Store(_ context.Context, delivery WebhookDelivery) error {
+	value, err := json.Marshal(delivery)
+	if err == nil {
+		err = d.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(deadLettersBucket).Put(delivery.ID[:], value)
+		})
+	}
+	if err != nil {
+		slog.Error("Error storing webhook delivery", "error", err)
+		return errors.New(StoreWebhookError)
+	}
+	return nil
+}
+
+func (d BoltDeadLetterRepository) All(_ context.Context) ([]WebhookDelivery, error) {
+	deliveries := make([]WebhookDelivery, 0)
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deadLettersBucket).ForEach(func(_, value []byte) error {
+			var delivery WebhookDelivery
+			if err := json.Unmarshal(value, &delivery); err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (d BoltDeadLetterRepository) FindByID(_ context.Context, id uuid.UUID) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := d.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(deadLettersBucket).Get(id[:])
+		if value == nil {
+			return ErrDeliveryNotFound
+		}
+		return json.Unmarshal(value, &delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (d BoltDeadLetterRepository) Delete(_ context.Context, id uuid.UUID) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deadLettersBucket)
+		if bucket.Get(id[:]) == nil {
+			return ErrDeliveryNotFound
+		}
+		return bucket.Delete(id[:])
+	})
+}
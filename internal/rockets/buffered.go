@@ -0,0 +1,357 @@
+package rockets
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// bufferedChannels is the number of channels held in memory, across every buffered service of the process. It is
+// published in /debug/vars.
+var bufferedChannels = expvar.NewInt("rockets.bufferedChannels")
+
+// BufferedMessageService keeps a snapshot of each rocket and its out of order messages in memory. A message that
+// follows the snapshot is applied right away and the others wait in the buffer until the gap before them is filled, so
+// the log is only read when a channel is first seen, after a failure, or to rebuild a rocket.
+//
+// A channel whose buffer is empty is released after idling for a while, its next message loads it again.
+//
+// Several instances never overwrite each other, a stale snapshot loses the version check and is loaded again. A
+// message stored by another instance is only seen on the next reload though, so the messages of a channel should reach
+// the same instance.
+type BufferedMessageService struct {
+	messageRepository MessageRepository
+	rocketsRepository RocketsRepository
+	gapPolicy         GapPolicy
+	gapTimers         gapTimers
+	feed              *RocketFeed
+	webhooks          *WebhookService
+	idleTimeout       time.Duration
+
+	mu       sync.Mutex
+	channels map[uuid.UUID]*bufferedChannel
+}
+
+type bufferedChannel struct {
+	// refs counts the goroutines using the channel and idle releases it once they are gone, both guarded by the mutex
+	// of the service.
+	refs int
+	idle *time.Timer
+
+	mu sync.Mutex
+	// rocket is nil until the channel is loaded from the storage, and again after a failure leaves it unreliable.
+	rocket *Rocket
+	// buffer holds the stored messages that are not applied yet, by message number.
+	buffer map[int]Message
+}
+
+func NewBufferedMessageService(messageRepository MessageRepository, rocketsRepository RocketsRepository, gapPolicy GapPolicy) *BufferedMessageService {
+	return &BufferedMessageService{
+		messageRepository: messageRepository,
+		rocketsRepository: rocketsRepository,
+		gapPolicy:         gapPolicy,
+		idleTimeout:       5 * time.Minute,
+		channels:          make(map[uuid.UUID]*bufferedChannel),
+	}
+}
+
+// ReleaseIdleAfter sets how long a channel with an empty buffer is kept after its last message, 0 releases it right
+// away. Set it up before the first message comes in.
+func (m *BufferedMessageService) ReleaseIdleAfter(timeout time.Duration) {
+	m.idleTimeout = timeout
+}
+
+// PublishTo sends the snapshots the service stores to the feed, set it up before the first message comes in.
+func (m *BufferedMessageService) PublishTo(feed *RocketFeed) {
+	m.feed = feed
+}
+
+// NotifyWebhooks sends the lifecycle changes of the stored snapshots to the webhooks, set it up along with the feed.
+func (m *BufferedMessageService) NotifyWebhooks(webhooks *WebhookService) {
+	m.webhooks = webhooks
+}
+
+func (m *BufferedMessageService) Ingest(ctx context.Context, message Message) error {
+	return ingest(ctx, m.messageRepository, m, message)
+}
+
+func (m *BufferedMessageService) IngestBatch(ctx context.Context, messages []Message) []IngestResult {
+	return ingestBatch(ctx, m.messageRepository, m, messages)
+}
+
+func (m *BufferedMessageService) Async() bool {
+	return false
+}
+
+func (m *BufferedMessageService) Shutdown(ctx context.Context) error {
+	return m.gapTimers.stop(ctx)
+}
+
+func (m *BufferedMessageService) Process(ctx context.Context, message Message) error {
+	return m.ProcessBatch(ctx, []Message{message})
+}
+
+func (m *BufferedMessageService) ProcessBatch(ctx context.Context, messages []Message) error {
+	channel := messages[0].Metadata.Channel
+	state := m.acquire(channel)
+	defer m.release(channel, state)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	return retryOnConflict(func() error {
+		err := m.process(ctx, channel, state, messages)
+		if err != nil {
+			// The snapshot may be ahead of the storage, or behind another instance, so it is loaded again
+			state.rocket = nil
+			state.buffer = nil
+		}
+		return err
+	})
+}
+
+// acquire returns the state of the channel, counting the caller as a user until it calls release.
+func (m *BufferedMessageService) acquire(channel uuid.UUID) *bufferedChannel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.channels[channel]
+	if !exists {
+		state = &bufferedChannel{}
+		m.channels[channel] = state
+		bufferedChannels.Add(1)
+	}
+	if state.idle != nil {
+		state.idle.Stop()
+		state.idle = nil
+	}
+	state.refs++
+	return state
+}
+
+// release drops the caller as a user of the channel. A channel left without users nor buffered messages only holds
+// the snapshot, which the storage has too, so it is forgotten after the idle timeout.
+func (m *BufferedMessageService) release(channel uuid.UUID, state *bufferedChannel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state.refs--
+	if state.refs > 0 || len(state.buffer) > 0 {
+		return
+	}
+	if m.idleTimeout <= 0 {
+		delete(m.channels, channel)
+		bufferedChannels.Add(-1)
+		return
+	}
+	var idle *time.Timer
+	idle = time.AfterFunc(m.idleTimeout, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// A message that came in meanwhile stopped the timer, or replaced it when it left again
+		if state.idle == idle {
+			delete(m.channels, channel)
+			bufferedChannels.Add(-1)
+		}
+	})
+	state.idle = idle
+}
+
+// size returns the number of channels held in memory.
+func (m *BufferedMessageService) size() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.channels)
+}
+
+func (m *BufferedMessageService) process(ctx context.Context, channel uuid.UUID, state *bufferedChannel, messages []Message) error {
+	if state.rocket == nil {
+		// The log already holds the messages
+		if err := m.load(ctx, channel, state); err != nil {
+			return err
+		}
+	} else {
+		now := time.Now()
+		for _, message := range messages {
+			if message.ReceivedAt.IsZero() {
+				message.ReceivedAt = now
+			}
+			// Applied as the storage keeps it, so the snapshot and what is published match what is read back
+			message.Metadata.MessageTime = storedTime(message.Metadata.MessageTime)
+			state.add(message)
+		}
+	}
+	// The snapshot is changed in place, this is what the storage holds and tells which messages are new
+	previous := *state.rocket
+
+	// A late message changes the past, so the rocket is rebuilt from the whole log. Numbers that are still missing are
+	// skipped again up to where the rocket was.
+	replayUntil := 0
+	rebuilt := false
+	if late, exists := state.late(); exists {
+		slog.Info("skipped message arrived late, rebuilding rocket", "channel", channel, "messageNumber", late)
+		stored, err := m.messageRepository.FindByChannel(ctx, channel)
+		if err != nil {
+			slog.Error("error finding messages of channel", "error", err)
+			return errors.New(ProcessMessageError)
+		}
+		replayUntil = *state.rocket.LastMessageNumber
+		state.rocket = newRocket(channel, state.rocket.Version)
+		stored = sinceSnapshot(stored)
+		if len(stored) > 0 && stored[0].Metadata.MessageType == RocketSnapshot {
+			if err := applyMessage(state.rocket, stored[0]); err != nil {
+				slog.Error("error applying snapshot", "error", err)
+				return errors.New(ProcessMessageError)
+			}
+			stored = stored[1:]
+		}
+		state.buffer = make(map[int]Message, len(stored))
+		for _, message := range stored {
+			state.buffer[message.Metadata.MessageNumber] = message
+		}
+		rebuilt = true
+	}
+
+	rocket := state.rocket
+	applied := 0
+	var events []LifecycleEvent
+	now := time.Now()
+	for {
+		message, exists := state.buffer[*rocket.LastMessageNumber+1]
+		if !exists {
+			// The launch is never skipped, the rocket would have no type nor mission
+			if len(state.buffer) == 0 || *rocket.LastMessageNumber == 0 {
+				break
+			}
+			if *rocket.LastMessageNumber >= replayUntil && !m.gapPolicy.enabled() {
+				break
+			}
+			waiting := state.waiting()
+			next := waiting[0]
+			if next.Metadata.MessageNumber > replayUntil && !m.gapPolicy.skips(waiting, now) {
+				break
+			}
+			rocket.SkippedMessages = append(rocket.SkippedMessages, SkippedRange{From: *rocket.LastMessageNumber + 1, To: next.Metadata.MessageNumber - 1})
+			message = next
+		}
+
+		delete(state.buffer, message.Metadata.MessageNumber)
+		before := *rocket
+		if err := applyMessage(rocket, message); err != nil {
+			slog.Error("error applying message", "error", err)
+			return errors.New(ProcessMessageError)
+		}
+		if newlyApplied(previous, message.Metadata.MessageNumber) {
+			events = append(events, lifecycleEvents(before, *rocket, now)...)
+		}
+		applied++
+	}
+
+	pending := len(state.buffer)
+	if pending > 0 && m.gapPolicy.Timeout > 0 {
+		m.gapTimers.schedule(channel, openSince(state.waiting()).Add(m.gapPolicy.Timeout), m.Process)
+	}
+
+	// A rocket that is not launched yet has nothing to persist. Without a write there is no version check either, so its
+	// snapshot is not kept and the next message reads the storage again.
+	if *rocket.LastMessageNumber == 0 {
+		state.rocket = nil
+		state.buffer = nil
+		return nil
+	}
+	if applied == 0 && !rebuilt && pending == rocket.PendingMessages {
+		return nil
+	}
+	rocket.PendingMessages = pending
+
+	if err := m.rocketsRepository.Upsert(ctx, *rocket); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+		slog.Error("error persisting rocket", "error", err)
+		return errors.New(ProcessMessageError)
+	}
+	rocket.Version++
+	m.feed.Publish(*rocket)
+	m.webhooks.Notify(events)
+	return nil
+}
+
+// load reads the snapshot of the rocket and fills the buffer with the stored messages it does not include.
+func (m *BufferedMessageService) load(ctx context.Context, channel uuid.UUID, state *bufferedChannel) error {
+	rocket, err := m.rocketsRepository.FindByChannel(ctx, channel)
+	if errors.Is(err, ErrRocketNotFound) {
+		rocket = newRocket(channel, 0)
+	} else if err != nil {
+		slog.Error("error getting rocket from db", "error", err)
+		return errors.New(ProcessMessageError)
+	}
+
+	// Skipped numbers are read again, in case one of them arrived late
+	after := *rocket.LastMessageNumber
+	if len(rocket.SkippedMessages) > 0 {
+		after = rocket.SkippedMessages[0].From - 1
+	}
+	messages, err := m.messageRepository.FindAfterNumber(ctx, channel, after)
+	if err != nil {
+		slog.Error("error finding messages after last message number", "error", err)
+		return errors.New(ProcessMessageError)
+	}
+
+	state.rocket = rocket
+	state.buffer = make(map[int]Message, len(messages))
+	for _, message := range messages {
+		state.add(message)
+	}
+	return nil
+}
+
+// add buffers the message unless it is already applied or buffered.
+func (c *bufferedChannel) add(message Message) {
+	number := message.Metadata.MessageNumber
+	if _, buffered := c.buffer[number]; buffered {
+		return
+	}
+	if number > *c.rocket.LastMessageNumber || c.skipped(number) {
+		c.buffer[number] = message
+	}
+}
+
+func (c *bufferedChannel) skipped(number int) bool {
+	for _, skipped := range c.rocket.SkippedMessages {
+		if number >= skipped.From && number <= skipped.To {
+			return true
+		}
+	}
+	return false
+}
+
+// late returns the number of a buffered message the rocket skipped before.
+func (c *bufferedChannel) late() (int, bool) {
+	if len(c.rocket.SkippedMessages) == 0 {
+		return 0, false
+	}
+	for number := range c.buffer {
+		if number <= *c.rocket.LastMessageNumber {
+			return number, true
+		}
+	}
+	return 0, false
+}
+
+// waiting returns the buffered messages ordered by number.
+func (c *bufferedChannel) waiting() []Message {
+	messages := make([]Message, 0, len(c.buffer))
+	for _, message := range c.buffer {
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Metadata.MessageNumber < messages[j].Metadata.MessageNumber
+	})
+	return messages
+}
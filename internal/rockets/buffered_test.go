@@ -0,0 +1,68 @@
+package rockets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedMessageService_ReleasesIdleChannels(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	service := NewBufferedMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	service.ReleaseIdleAfter(0)
+	live := bufferedChannels.Value()
+
+	for i := 0; i < 100; i++ {
+		for _, message := range flight(uuid.New(), 3, time.Now()) {
+			require.NoError(t, service.Ingest(ctx, message))
+		}
+	}
+	assert.Equal(t, 0, service.size())
+	assert.Equal(t, live, bufferedChannels.Value())
+
+	// A channel waiting behind a gap keeps its buffer until the gap is filled
+	channel := uuid.New()
+	messages := flight(channel, 4, time.Now())
+	for _, message := range append(messages[:2:2], messages[3]) {
+		require.NoError(t, service.Ingest(ctx, message))
+	}
+	assert.Equal(t, 1, service.size())
+	require.NoError(t, service.Ingest(ctx, messages[2]))
+	assert.Equal(t, 0, service.size())
+
+	// The next message loads the released channel from the storage
+	more := flight(channel, 5, time.Now())
+	require.NoError(t, service.Ingest(ctx, more[4]))
+	rocket, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, 5, *rocket.LastMessageNumber)
+	assert.Equal(t, 540, rocket.Speed)
+}
+
+func TestBufferedMessageService_KeepsChannelUntilIdleTimeout(t *testing.T) {
+	ctx := context.Background()
+	service := NewBufferedMessageService(NewMemoryMessageRepository(), NewMemoryRocketsRepository(), GapPolicy{})
+	service.ReleaseIdleAfter(time.Second)
+
+	channel := uuid.New()
+	messages := flight(channel, 2, time.Now())
+	require.NoError(t, service.Ingest(ctx, messages[0]))
+	service.mu.Lock()
+	first := service.channels[channel].idle
+	service.mu.Unlock()
+	require.NotNil(t, first)
+
+	// The next message stops the timer and a new one starts when it leaves
+	require.NoError(t, service.Ingest(ctx, messages[1]))
+	service.mu.Lock()
+	assert.NotSame(t, first, service.channels[channel].idle)
+	service.mu.Unlock()
+	assert.Equal(t, 1, service.size())
+
+	require.Eventually(t, func() bool { return service.size() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
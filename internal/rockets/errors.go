@@ -1,5 +1,74 @@
 package rockets
 
+import "errors"
+
 const ProcessMessageError = "error processing message"
 const StoreMessageError = "error storing message"
 const UpdateRocketError = "error updating rocket"
+const RocketNotFoundError = "rocket not found"
+const InvalidCursorError = "invalid cursor"
+const DuplicateMessageError = "message already received"
+const ConflictingMessageError = "message number already received with a different content"
+
+// ErrRocketNotFound is returned when no rocket exists for a channel.
+var ErrRocketNotFound = errors.New(RocketNotFoundError)
+
+// ErrInvalidCursor is returned for a page cursor that is malformed or was made for another ordering.
+var ErrInvalidCursor = errors.New(InvalidCursorError)
+
+// ErrDuplicateMessage is returned when the same message was already stored for its channel and number.
+var ErrDuplicateMessage = errors.New(DuplicateMessageError)
+
+// ErrConflictingMessage is returned when a different message was already stored for its channel and number.
+var ErrConflictingMessage = errors.New(ConflictingMessageError)
+
+const QueueFullError = "message queue is full"
+const ShuttingDownError = "message service is shutting down"
+
+// ErrQueueFull is returned when a channel has too many messages waiting to be processed.
+var ErrQueueFull = errors.New(QueueFullError)
+
+// ErrShuttingDown is returned when a message arrives after the service started shutting down.
+var ErrShuttingDown = errors.New(ShuttingDownError)
+
+const VersionConflictError = "rocket was updated concurrently"
+
+// ErrVersionConflict is returned when a rocket is written from a state another writer already replaced.
+var ErrVersionConflict = errors.New(VersionConflictError)
+
+const IncompleteLogError = "message log does not reach the last message applied to the rocket"
+const RebuildRunningError = "a rebuild is already running"
+const RebuildNotFoundError = "rebuild not found"
+
+const CompactedLogError = "the log was compacted past that point"
+
+// ErrCompactedLog is returned for a past state that the retention folded into a snapshot, the message tells the
+// earliest point left.
+var ErrCompactedLog = errors.New(CompactedLogError)
+
+// ErrIncompleteLog is returned when a rocket can't be replayed because messages it applied are missing from the log.
+var ErrIncompleteLog = errors.New(IncompleteLogError)
+
+// ErrRebuildRunning is returned when a fleet rebuild is started while another one is running.
+var ErrRebuildRunning = errors.New(RebuildRunningError)
+
+// ErrRebuildNotFound is returned for a rebuild job that never existed or was forgotten.
+var ErrRebuildNotFound = errors.New(RebuildNotFoundError)
+
+const InvalidWebhookError = "invalid webhook"
+const StoreWebhookError = "error storing webhook"
+const WebhookNotFoundError = "webhook not found"
+const DeliveryNotFoundError = "webhook delivery not found"
+const DeliveryFailedError = "webhook delivery failed"
+
+// ErrInvalidWebhook is returned when a webhook is subscribed with a bad URL or events.
+var ErrInvalidWebhook = errors.New(InvalidWebhookError)
+
+// ErrWebhookNotFound is returned for a webhook that was never subscribed or was deleted.
+var ErrWebhookNotFound = errors.New(WebhookNotFoundError)
+
+// ErrDeliveryNotFound is returned for a delivery that is not among the dead letters.
+var ErrDeliveryNotFound = errors.New(DeliveryNotFoundError)
+
+// ErrDeliveryFailed is returned when the webhook refused a redelivery, or could not be reached.
+var ErrDeliveryFailed = errors.New(DeliveryFailedError)
@@ -0,0 +1,140 @@
+package rockets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RocketUpdate is a rocket state published to the feed. IDs grow with every update of the feed.
+type RocketUpdate struct {
+	ID     string
+	Rocket Rocket
+}
+
+// subscriberBuffer is how many updates a subscriber can fall behind before it is dropped.
+const subscriberBuffer = 64
+
+// RocketFeed hands the rockets stored by the services of this instance to its subscribers. It remembers the last
+// updates so a subscriber that reconnects can catch up from the last one it got. IDs are prefixed with when the feed
+// started, so the ones of a previous process are never mistaken for the current ones.
+type RocketFeed struct {
+	epoch string
+
+	mu          sync.Mutex
+	last        uint64
+	history     []RocketUpdate
+	historySize int
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+type subscriber struct {
+	channel *uuid.UUID
+	updates chan RocketUpdate
+}
+
+func NewRocketFeed(historySize int) *RocketFeed {
+	return &RocketFeed{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Publish sends the rocket to the subscribers. A subscriber that is too far behind is dropped, its updates channel is
+// closed and it can subscribe again from its last update. A nil feed publishes nothing.
+func (f *RocketFeed) Publish(rocket Rocket) {
+	if f == nil {
+		return
+	}
+	rocket = cloneRocket(rocket)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.last++
+	update := RocketUpdate{ID: f.id(f.last), Rocket: rocket}
+	f.history = append(f.history, update)
+	if len(f.history) > f.historySize {
+		f.history = f.history[len(f.history)-f.historySize:]
+	}
+
+	for s := range f.subscribers {
+		if s.channel != nil && *s.channel != rocket.Channel {
+			continue
+		}
+		select {
+		case s.updates <- update:
+		default:
+			delete(f.subscribers, s)
+			close(s.updates)
+		}
+	}
+}
+
+// Subscribe returns the updates of the channel, or of every rocket when channel is nil, published after lastID, and
+// the channel of the following ones. The replay is nil when lastID is empty or no longer remembered, then the caller
+// has to send the current state instead; current is the ID that state goes with. cancel stops the updates.
+func (f *RocketFeed) Subscribe(channel *uuid.UUID, lastID string) (replay []RocketUpdate, current string, updates <-chan RocketUpdate, cancel func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if after, ok := f.sequence(lastID); ok && (after >= f.last-uint64(len(f.history))) {
+		replay = make([]RocketUpdate, 0)
+		for _, update := range f.history[len(f.history)-int(f.last-after):] {
+			if channel == nil || update.Rocket.Channel == *channel {
+				replay = append(replay, update)
+			}
+		}
+	}
+
+	s := &subscriber{channel: channel, updates: make(chan RocketUpdate, subscriberBuffer)}
+	if f.closed {
+		close(s.updates)
+		return replay, f.id(f.last), s.updates, func() {}
+	}
+	f.subscribers[s] = struct{}{}
+	cancel = func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, exists := f.subscribers[s]; exists {
+			delete(f.subscribers, s)
+			close(s.updates)
+		}
+	}
+	return replay, f.id(f.last), s.updates, cancel
+}
+
+// Close ends the updates of every subscriber, and of the ones subscribing later, so streams finish on shutdown.
+func (f *RocketFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for s := range f.subscribers {
+		delete(f.subscribers, s)
+		close(s.updates)
+	}
+}
+
+func (f *RocketFeed) id(sequence uint64) string {
+	return fmt.Sprintf("%s-%d", f.epoch, sequence)
+}
+
+// sequence returns the number of an ID of this feed that is not ahead of it.
+func (f *RocketFeed) sequence(id string) (uint64, bool) {
+	epoch, number, found := strings.Cut(id, "-")
+	if !found || epoch != f.epoch {
+		return 0, false
+	}
+	sequence, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || sequence > f.last {
+		return 0, false
+	}
+	return sequence, true
+}
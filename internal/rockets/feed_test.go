@@ -0,0 +1,64 @@
+package rockets
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRocketFeed_Resume(t *testing.T) {
+	feed := NewRocketFeed(3)
+	tracked, other := uuid.New(), uuid.New()
+
+	replay, first, updates, cancel := feed.Subscribe(&tracked, "")
+	defer cancel()
+	assert.Nil(t, replay)
+
+	feed.Publish(Rocket{Channel: tracked, Speed: 1})
+	feed.Publish(Rocket{Channel: other, Speed: 2})
+	feed.Publish(Rocket{Channel: tracked, Speed: 3})
+	update := <-updates
+	assert.Equal(t, 1, update.Rocket.Speed)
+	assert.Equal(t, 3, (<-updates).Rocket.Speed)
+
+	// Resuming replays what came after, for the channel only
+	replay, _, _, cancelResumed := feed.Subscribe(&tracked, update.ID)
+	defer cancelResumed()
+	require.Len(t, replay, 1)
+	assert.Equal(t, 3, replay[0].Rocket.Speed)
+
+	replay, _, _, cancelFirst := feed.Subscribe(nil, first)
+	defer cancelFirst()
+	assert.Len(t, replay, 3)
+
+	// Once forgotten, or from another feed, the caller sends the current state instead
+	feed.Publish(Rocket{Channel: other, Speed: 4})
+	replay, _, _, cancelForgotten := feed.Subscribe(nil, first)
+	defer cancelForgotten()
+	assert.Nil(t, replay)
+	replay, _, _, cancelOther := NewRocketFeed(3).Subscribe(nil, update.ID)
+	defer cancelOther()
+	assert.Nil(t, replay)
+}
+
+func TestRocketFeed_DropsSlowSubscribers(t *testing.T) {
+	feed := NewRocketFeed(0)
+	_, _, slow, cancel := feed.Subscribe(nil, "")
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		feed.Publish(Rocket{Channel: uuid.New(), Speed: i})
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received)
+
+	_, _, updates, _ := feed.Subscribe(nil, "")
+	feed.Close()
+	_, open := <-updates
+	assert.False(t, open)
+}
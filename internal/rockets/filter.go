@@ -0,0 +1,111 @@
+package rockets
+
+import (
+	"cmp"
+	"strings"
+	"time"
+)
+
+// RocketFilter selects rockets of the fleet, every rocket when empty. Set conditions must all hold.
+type RocketFilter struct {
+	Status        string
+	Type          string
+	Mission       string
+	MissionPrefix string
+	MinSpeed      *int
+	MaxSpeed      *int
+	// LastMessageFrom and LastMessageTo bound the time of the last applied message, both included. Rockets that never
+	// applied a message are left out when either is set.
+	LastMessageFrom *time.Time
+	LastMessageTo   *time.Time
+	// The ranges of the derived metrics, both ends included. Rockets that never launched are left out when
+	// LaunchedFrom or LaunchedTo is set.
+	MinPeakSpeed         *int
+	MaxPeakSpeed         *int
+	MinTotalAcceleration *int
+	MaxTotalAcceleration *int
+	MinMissionChanges    *int
+	MaxMissionChanges    *int
+	LaunchedFrom         *time.Time
+	LaunchedTo           *time.Time
+	MinFlightDuration    *float64
+	MaxFlightDuration    *float64
+	MinDistance          *float64
+	MaxDistance          *float64
+	// Search is looked up, ignoring case, in the channel, type, mission and explosion reason.
+	Search string
+}
+
+// Validate reports the conditions that can't match any rocket with a ValidationError.
+func (f RocketFilter) Validate() error {
+	switch {
+	case f.Status != "" && f.Status != "active" && f.Status != "exploded":
+		return &ValidationError{Field: "status", Reason: `must be "active" or "exploded"`}
+	case emptyRange(f.MinSpeed, f.MaxSpeed):
+		return &ValidationError{Field: "minSpeed", Reason: "must not be greater than maxSpeed"}
+	case f.LastMessageFrom != nil && f.LastMessageTo != nil && f.LastMessageFrom.After(*f.LastMessageTo):
+		return &ValidationError{Field: "lastMessageFrom", Reason: "must not be after lastMessageTo"}
+	case emptyRange(f.MinPeakSpeed, f.MaxPeakSpeed):
+		return &ValidationError{Field: "minPeakSpeed", Reason: "must not be greater than maxPeakSpeed"}
+	case emptyRange(f.MinTotalAcceleration, f.MaxTotalAcceleration):
+		return &ValidationError{Field: "minTotalAcceleration", Reason: "must not be greater than maxTotalAcceleration"}
+	case emptyRange(f.MinMissionChanges, f.MaxMissionChanges):
+		return &ValidationError{Field: "minMissionChanges", Reason: "must not be greater than maxMissionChanges"}
+	case f.LaunchedFrom != nil && f.LaunchedTo != nil && f.LaunchedFrom.After(*f.LaunchedTo):
+		return &ValidationError{Field: "launchedFrom", Reason: "must not be after launchedTo"}
+	case emptyRange(f.MinFlightDuration, f.MaxFlightDuration):
+		return &ValidationError{Field: "minFlightDuration", Reason: "must not be greater than maxFlightDuration"}
+	case emptyRange(f.MinDistance, f.MaxDistance):
+		return &ValidationError{Field: "minDistance", Reason: "must not be greater than maxDistance"}
+	}
+	return nil
+}
+
+func emptyRange[T cmp.Ordered](from, to *T) bool {
+	return from != nil && to != nil && *from > *to
+}
+
+func outsideRange[T cmp.Ordered](value T, from, to *T) bool {
+	return from != nil && value < *from || to != nil && value > *to
+}
+
+// outsideTimes reports a time outside of the range, a missing time is outside of any range with an end set.
+func outsideTimes(value, from, to *time.Time) bool {
+	if from == nil && to == nil {
+		return false
+	}
+	return value == nil || from != nil && value.Before(*from) || to != nil && value.After(*to)
+}
+
+func (f RocketFilter) matches(rocket Rocket) bool {
+	switch {
+	case f.Status != "" && rocket.Status != f.Status,
+		f.Type != "" && rocket.Type != f.Type,
+		f.Mission != "" && rocket.Mission != f.Mission,
+		f.MissionPrefix != "" && !strings.HasPrefix(rocket.Mission, f.MissionPrefix),
+		outsideRange(rocket.Speed, f.MinSpeed, f.MaxSpeed),
+		outsideRange(rocket.PeakSpeed, f.MinPeakSpeed, f.MaxPeakSpeed),
+		outsideRange(rocket.TotalAcceleration, f.MinTotalAcceleration, f.MaxTotalAcceleration),
+		outsideRange(rocket.MissionChanges, f.MinMissionChanges, f.MaxMissionChanges),
+		outsideRange(rocket.FlightDuration, f.MinFlightDuration, f.MaxFlightDuration),
+		outsideRange(rocket.Distance, f.MinDistance, f.MaxDistance),
+		outsideTimes(rocket.LastMessageTime, f.LastMessageFrom, f.LastMessageTo),
+		outsideTimes(rocket.LaunchTime, f.LaunchedFrom, f.LaunchedTo):
+		return false
+	}
+
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		fields := []string{rocket.Channel.String(), rocket.Type, rocket.Mission}
+		if rocket.ExplosionReason != nil {
+			fields = append(fields, *rocket.ExplosionReason)
+		}
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), search) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+package rockets
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Gap is a range of missing message numbers holding back the messages stored after it. OpenSince is when the first
+// message after the range was received, which is when the range became a gap.
+type Gap struct {
+	Channel   uuid.UUID
+	From      int
+	To        int
+	OpenSince time.Time
+}
+
+// Missing is the number of message numbers in the gap.
+func (g Gap) Missing() int {
+	return g.To - g.From + 1
+}
+
+// Gaps returns the missing message numbers of a channel, lowest first. It returns ErrRocketNotFound when the channel
+// has no messages at all.
+func (r RocketsService) Gaps(ctx context.Context, channel uuid.UUID) ([]Gap, error) {
+	lastApplied := 0
+	rocket, err := r.repository.FindByChannel(ctx, channel)
+	switch {
+	case err == nil:
+		if rocket.LastMessageNumber != nil {
+			lastApplied = *rocket.LastMessageNumber
+		}
+	case errors.Is(err, ErrRocketNotFound):
+		messages, err := r.messageRepository.FindByChannel(ctx, channel)
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) == 0 {
+			return nil, ErrRocketNotFound
+		}
+	default:
+		return nil, err
+	}
+
+	pending, err := r.messageRepository.FindAfterNumber(ctx, channel, lastApplied)
+	if err != nil {
+		return nil, err
+	}
+	return findGaps(channel, lastApplied, pending), nil
+}
+
+// AllGaps returns the gaps of every stuck channel, the ones open for longer first. The rockets tell which channels have
+// pending messages, and those and the channels that are not launched yet are read from the log in a single query.
+func (r RocketsService) AllGaps(ctx context.Context) ([]Gap, error) {
+	channels, err := r.messageRepository.Channels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fleet, err := r.repository.All(ctx, RocketQuery{})
+	if err != nil {
+		return nil, err
+	}
+	launched := make(map[uuid.UUID]Rocket, len(fleet.Rockets))
+	for _, rocket := range fleet.Rockets {
+		launched[rocket.Channel] = rocket
+	}
+
+	// The last applied number of each stuck channel, 0 when it is not launched
+	stuck := make(map[uuid.UUID]int)
+	for _, channel := range channels {
+		lastApplied := 0
+		if rocket, exists := launched[channel]; exists {
+			if rocket.InSync() {
+				continue
+			}
+			if rocket.LastMessageNumber != nil {
+				lastApplied = *rocket.LastMessageNumber
+			}
+		}
+		stuck[channel] = lastApplied
+	}
+
+	messages, err := r.messageRepository.FindAfterNumbers(ctx, stuck)
+	if err != nil {
+		return nil, err
+	}
+	gaps := make([]Gap, 0)
+	stuckChannels, groups := groupByChannel(messages)
+	for _, channel := range stuckChannels {
+		pending := make([]Message, 0, len(groups[channel]))
+		for _, i := range groups[channel] {
+			pending = append(pending, messages[i])
+		}
+		gaps = append(gaps, findGaps(channel, stuck[channel], pending)...)
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool {
+		return gaps[i].OpenSince.Before(gaps[j].OpenSince)
+	})
+	return gaps, nil
+}
+
+// findGaps returns the ranges of numbers missing between the last applied message and the pending messages, which are
+// ordered by number.
+func findGaps(channel uuid.UUID, lastApplied int, pending []Message) []Gap {
+	// The earliest reception among the messages from each position onwards
+	receivedFrom := make([]time.Time, len(pending))
+	for i := len(pending) - 1; i >= 0; i-- {
+		receivedFrom[i] = pending[i].ReceivedAt
+		if i+1 < len(pending) && receivedFrom[i+1].Before(receivedFrom[i]) {
+			receivedFrom[i] = receivedFrom[i+1]
+		}
+	}
+
+	var gaps []Gap
+	expected := lastApplied + 1
+	for i, message := range pending {
+		number := message.Metadata.MessageNumber
+		if number > expected {
+			gaps = append(gaps, Gap{Channel: channel, From: expected, To: number - 1, OpenSince: receivedFrom[i]})
+		}
+		if number >= expected {
+			expected = number + 1
+		}
+	}
+	return gaps
+}
+
+// GapPolicy decides when the resequencer gives up on missing messages and applies the ones stored after them. The zero
+// value waits forever.
+type GapPolicy struct {
+	// Timeout is how long a gap is waited for, counted from when the first message after it was received.
+	Timeout time.Duration
+	// MaxPending is how many messages can wait behind a gap.
+	MaxPending int
+}
+
+func (p GapPolicy) enabled() bool {
+	return p.Timeout > 0 || p.MaxPending > 0
+}
+
+// skips reports whether the gap before the waiting messages, ordered by number, should be skipped.
+func (p GapPolicy) skips(waiting []Message, now time.Time) bool {
+	if p.MaxPending > 0 && len(waiting) >= p.MaxPending {
+		return true
+	}
+	return p.Timeout > 0 && len(waiting) > 0 && now.Sub(openSince(waiting)) >= p.Timeout
+}
+
+// openSince returns when the earliest of the messages was received.
+func openSince(messages []Message) time.Time {
+	var earliest time.Time
+	for _, message := range messages {
+		if earliest.IsZero() || message.ReceivedAt.Before(earliest) {
+			earliest = message.ReceivedAt
+		}
+	}
+	return earliest
+}
+
+// firstSkipped returns the number of the first message that the rocket skipped but is now stored.
+func firstSkipped(rocket *Rocket, messages []Message) (int, bool) {
+	for _, message := range messages {
+		number := message.Metadata.MessageNumber
+		for _, skipped := range rocket.SkippedMessages {
+			if number >= skipped.From && number <= skipped.To {
+				return number, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// gapTimers processes channels again when their gap timeout expires, since no new message may come to do it. A channel
+// has at most one timer.
+type gapTimers struct {
+	mu      sync.Mutex
+	timers  map[uuid.UUID]*time.Timer
+	stopped bool
+	// running counts the timers that expired and are processing their channel.
+	running sync.WaitGroup
+}
+
+func (g *gapTimers) schedule(channel uuid.UUID, at time.Time, process func(ctx context.Context, message Message) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, scheduled := g.timers[channel]; scheduled || g.stopped {
+		return
+	}
+	if g.timers == nil {
+		g.timers = make(map[uuid.UUID]*time.Timer)
+	}
+
+	g.timers[channel] = time.AfterFunc(time.Until(at), func() {
+		g.mu.Lock()
+		delete(g.timers, channel)
+		if g.stopped {
+			g.mu.Unlock()
+			return
+		}
+		g.running.Add(1)
+		g.mu.Unlock()
+		defer g.running.Done()
+
+		if err := process(context.Background(), Message{Metadata: Metadata{Channel: channel}}); err != nil {
+			slog.Error("error processing channel after gap timeout", "channel", channel, "error", err)
+		}
+	})
+}
+
+// stop cancels the timers and waits for the ones already processing their channel, so none of them touches the
+// storage once it is closed. Nothing is scheduled afterwards, the gaps are timed again by the next start.
+func (g *gapTimers) stop(ctx context.Context) error {
+	g.mu.Lock()
+	g.stopped = true
+	for channel, timer := range g.timers {
+		timer.Stop()
+		delete(g.timers, channel)
+	}
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,67 @@
+package rockets
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGapTimers_StopCancelsAndWaits(t *testing.T) {
+	var timers gapTimers
+	var processed atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+
+	// One timer is processing its channel when the timers stop, the other one has not expired yet
+	timers.schedule(uuid.New(), time.Now(), func(context.Context, Message) error {
+		close(started)
+		<-release
+		processed.Add(1)
+		return nil
+	})
+	timers.schedule(uuid.New(), time.Now().Add(50*time.Millisecond), func(context.Context, Message) error {
+		processed.Add(1)
+		return nil
+	})
+	<-started
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- timers.stop(context.Background())
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("stop returned while a channel was being processed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	require.NoError(t, <-stopped)
+
+	// Nothing runs after stop, not even timers scheduled later
+	timers.schedule(uuid.New(), time.Now(), func(context.Context, Message) error {
+		processed.Add(1)
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), processed.Load())
+}
+
+func TestGapTimers_StopGivesUpWithTheContext(t *testing.T) {
+	var timers gapTimers
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	timers.schedule(uuid.New(), time.Now(), func(context.Context, Message) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, timers.stop(ctx), context.DeadlineExceeded)
+}
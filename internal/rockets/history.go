@@ -0,0 +1,98 @@
+package rockets
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventStatus string
+
+const (
+	// EventApplied messages are part of the current rocket state.
+	EventApplied EventStatus = "applied"
+	// EventPending messages are stored but wait behind a missing message number.
+	EventPending EventStatus = "pending"
+	// EventDuplicate stands for the redeliveries of a stored message, which were answered without being stored again.
+	EventDuplicate EventStatus = "duplicate"
+)
+
+type Event struct {
+	Message Message
+	Status  EventStatus
+}
+
+type EventFilter struct {
+	MessageType string
+	From        *time.Time
+	To          *time.Time
+	Offset      int
+	Limit       int
+}
+
+type EventPage struct {
+	Events []Event
+	Total  int
+}
+
+// Events returns the message log of a rocket, oldest first, telling for each message whether it was applied. A message
+// that was redelivered is followed by a duplicate event.
+func (r RocketsService) Events(ctx context.Context, channel uuid.UUID, filter EventFilter) (*EventPage, error) {
+	messages, err := r.messageRepository.FindByChannel(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrRocketNotFound
+	}
+
+	lastApplied := 0
+	rocket, err := r.repository.FindByChannel(ctx, channel)
+	if err != nil && !errors.Is(err, ErrRocketNotFound) {
+		return nil, err
+	}
+	if rocket != nil && rocket.LastMessageNumber != nil {
+		lastApplied = *rocket.LastMessageNumber
+	}
+
+	// The log holds a message number once, its redeliveries are only counted on the stored message
+	events := make([]Event, 0, len(messages))
+	for _, message := range messages {
+		status := EventPending
+		if message.Metadata.MessageNumber <= lastApplied {
+			status = EventApplied
+		}
+
+		if filter.matches(message) {
+			events = append(events, Event{Message: message, Status: status})
+			if message.Redeliveries > 0 {
+				events = append(events, Event{Message: message, Status: EventDuplicate})
+			}
+		}
+	}
+
+	page := &EventPage{Total: len(events)}
+	if filter.Offset < len(events) {
+		end := len(events)
+		if filter.Limit > 0 && filter.Offset+filter.Limit < end {
+			end = filter.Offset + filter.Limit
+		}
+		page.Events = events[filter.Offset:end]
+	}
+	return page, nil
+}
+
+func (f EventFilter) matches(message Message) bool {
+	if f.MessageType != "" && message.Metadata.MessageType != f.MessageType {
+		return false
+	}
+	if f.From != nil && message.Metadata.MessageTime.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && message.Metadata.MessageTime.After(*f.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package rockets
+
+import (
+	"expvar"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// liveChannelLocks is the number of channel locks held or waited for, across every registry of the process. It is
+// published in /debug/vars.
+var liveChannelLocks = expvar.NewInt("rockets.liveChannelLocks")
+
+// lockRegistry hands out a mutex per channel while the channel is in use. Each entry counts the goroutines holding or
+// waiting for it and is dropped when the last one leaves, so idle channels take no memory.
+type lockRegistry struct {
+	mu    sync.Mutex
+	locks map[uuid.UUID]*channelLock
+}
+
+type channelLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newLockRegistry() *lockRegistry {
+	return &lockRegistry{locks: make(map[uuid.UUID]*channelLock)}
+}
+
+// lock blocks until the channel is free, and returns the function that frees it.
+func (r *lockRegistry) lock(channel uuid.UUID) (unlock func()) {
+	r.mu.Lock()
+	entry, exists := r.locks[channel]
+	if !exists {
+		entry = &channelLock{}
+		r.locks[channel] = entry
+		liveChannelLocks.Add(1)
+	}
+	entry.refs++
+	r.mu.Unlock()
+
+	entry.Lock()
+	return func() {
+		entry.Unlock()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(r.locks, channel)
+			liveChannelLocks.Add(-1)
+		}
+	}
+}
+
+// size returns the number of channels locked or waited for.
+func (r *lockRegistry) size() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.locks)
+}
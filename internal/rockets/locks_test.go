@@ -0,0 +1,119 @@
+package rockets
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockRegistry_ExcludesPerChannelUnderLoad(t *testing.T) {
+	registry := newLockRegistry()
+	live := liveChannelLocks.Value()
+
+	channels := make([]uuid.UUID, 8)
+	for i := range channels {
+		channels[i] = uuid.New()
+	}
+	// Plain ints, the race detector and the totals catch two goroutines inside the same channel
+	counters := make(map[uuid.UUID]*int, len(channels))
+	for _, channel := range channels {
+		counters[channel] = new(int)
+	}
+
+	const goroutines = 64
+	const iterations = 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				channel := channels[(g+i)%len(channels)]
+				unlock := registry.lock(channel)
+				*counters[channel]++
+				unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, counter := range counters {
+		total += *counter
+	}
+	assert.Equal(t, goroutines*iterations, total)
+	assert.Equal(t, 0, registry.size())
+	assert.Equal(t, live, liveChannelLocks.Value())
+}
+
+func TestLockRegistry_ReleasesIdleChannels(t *testing.T) {
+	registry := newLockRegistry()
+	live := liveChannelLocks.Value()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				registry.lock(uuid.New())()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, registry.size())
+	assert.Equal(t, live, liveChannelLocks.Value())
+}
+
+func TestLockRegistry_KeepsEntryWhileWaited(t *testing.T) {
+	registry := newLockRegistry()
+	live := liveChannelLocks.Value()
+	channel := uuid.New()
+
+	unlock := registry.lock(channel)
+	acquired := make(chan func())
+	go func() {
+		acquired <- registry.lock(channel)
+	}()
+
+	// The waiter holds a reference, so releasing the first holder can't drop the entry under it
+	require.Eventually(t, func() bool {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		return registry.locks[channel].refs == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, live+1, liveChannelLocks.Value())
+
+	unlock()
+	(<-acquired)()
+	assert.Equal(t, 0, registry.size())
+	assert.Equal(t, live, liveChannelLocks.Value())
+}
+
+// BenchmarkLockRegistry locks many distinct channels once, like a simulator creating channels, and reports the memory
+// still held afterwards.
+func BenchmarkLockRegistry(b *testing.B) {
+	registry := newLockRegistry()
+	b.ReportAllocs()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	for i := 0; i < b.N; i++ {
+		registry.lock(uuid.New())()
+	}
+
+	b.StopTimer()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	b.ReportMetric(float64(max(retained, 0))/float64(b.N), "retained-B/channel")
+	b.ReportMetric(float64(registry.size()), "live-locks")
+}
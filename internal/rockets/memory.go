@@ -0,0 +1,393 @@
+package rockets
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryMessageRepository keeps the log in memory, each channel sorted by message number. Times are kept with the
+// millisecond precision mongo stores, so both backends return the same messages.
+type MemoryMessageRepository struct {
+	mu   sync.RWMutex
+	logs map[uuid.UUID][]Message
+}
+
+func NewMemoryMessageRepository() *MemoryMessageRepository {
+	return &MemoryMessageRepository{
+		logs: make(map[uuid.UUID][]Message),
+	}
+}
+
+func (r *MemoryMessageRepository) Store(_ context.Context, message Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channel := message.Metadata.Channel
+	log := r.logs[channel]
+	i := sort.Search(len(log), func(i int) bool { return log[i].Metadata.MessageNumber >= message.Metadata.MessageNumber })
+	if i < len(log) && log[i].Metadata.MessageNumber == message.Metadata.MessageNumber {
+		err := duplicateOf(log[i], message)
+		if errors.Is(err, ErrDuplicateMessage) {
+			log[i].Redeliveries++
+			log[i].RedeliveredAt = storedTime(time.Now())
+		}
+		return err
+	}
+
+	message.Metadata.MessageTime = storedTime(message.Metadata.MessageTime)
+	message.Message = maps.Clone(message.Message)
+	message.ReceivedAt = storedTime(time.Now())
+	r.logs[channel] = slices.Insert(log, i, message)
+	return nil
+}
+
+func (r *MemoryMessageRepository) FindByChannel(_ context.Context, channel uuid.UUID) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return cloneMessages(r.logs[channel]), nil
+}
+
+func (r *MemoryMessageRepository) FindAfterNumber(_ context.Context, channel uuid.UUID, number int) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log := r.logs[channel]
+	i := sort.Search(len(log), func(i int) bool { return log[i].Metadata.MessageNumber > number })
+	return cloneMessages(log[i:]), nil
+}
+
+func (r *MemoryMessageRepository) FindAfterNumbers(_ context.Context, after map[uuid.UUID]int) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []Message{}
+	for channel, number := range after {
+		log := r.logs[channel]
+		i := sort.Search(len(log), func(i int) bool { return log[i].Metadata.MessageNumber > number })
+		messages = append(messages, cloneMessages(log[i:])...)
+	}
+	return messages, nil
+}
+
+func (r *MemoryMessageRepository) Channels(_ context.Context) ([]uuid.UUID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	channels := make([]uuid.UUID, 0, len(r.logs))
+	for channel := range r.logs {
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+func (r *MemoryMessageRepository) Compact(_ context.Context, snapshot Message) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channel := snapshot.Metadata.Channel
+	log := r.logs[channel]
+	deleted := sort.Search(len(log), func(i int) bool { return log[i].Metadata.MessageNumber >= snapshot.Metadata.MessageNumber })
+	after := log[deleted:]
+	if len(after) > 0 && after[0].Metadata.MessageNumber == snapshot.Metadata.MessageNumber {
+		after = after[1:]
+	}
+
+	snapshot.Message = maps.Clone(snapshot.Message)
+	r.logs[channel] = append([]Message{snapshot}, after...)
+	return deleted, nil
+}
+
+func (r *MemoryMessageRepository) DeleteChannel(_ context.Context, channel uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := len(r.logs[channel])
+	delete(r.logs, channel)
+	return deleted, nil
+}
+
+// MemoryRocketsRepository keeps the rockets in memory in the order they were first stored, which is the order mongo
+// returns them in when no sort is asked for.
+type MemoryRocketsRepository struct {
+	mu       sync.RWMutex
+	rockets  map[uuid.UUID]Rocket
+	channels []uuid.UUID
+}
+
+func NewMemoryRocketsRepository() *MemoryRocketsRepository {
+	return &MemoryRocketsRepository{
+		rockets: make(map[uuid.UUID]Rocket),
+	}
+}
+
+func (m *MemoryRocketsRepository) All(_ context.Context, query RocketQuery) (*RocketPage, error) {
+	return pageRockets(m.all(), query)
+}
+
+func (m *MemoryRocketsRepository) Stats(_ context.Context, query StatsQuery) (*FleetStats, error) {
+	return fleetStats(m.all(), query), nil
+}
+
+func (m *MemoryRocketsRepository) all() []Rocket {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rockets := make([]Rocket, 0, len(m.channels))
+	for _, channel := range m.channels {
+		rockets = append(rockets, cloneRocket(m.rockets[channel]))
+	}
+	return rockets
+}
+
+func (m *MemoryRocketsRepository) FindByChannel(_ context.Context, channel uuid.UUID) (*Rocket, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rocket, exists := m.rockets[channel]
+	if !exists {
+		return nil, ErrRocketNotFound
+	}
+	rocket = cloneRocket(rocket)
+	return &rocket, nil
+}
+
+func (m *MemoryRocketsRepository) Upsert(_ context.Context, rocket Rocket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.rockets[rocket.Channel]
+	if exists && stored.Version != rocket.Version || !exists && rocket.Version != 0 {
+		return ErrVersionConflict
+	}
+	if !exists {
+		m.channels = append(m.channels, rocket.Channel)
+	}
+
+	rocket = cloneRocket(rocket)
+	if rocket.LaunchTime != nil {
+		*rocket.LaunchTime = storedTime(*rocket.LaunchTime)
+	}
+	if rocket.LastMessageTime != nil {
+		*rocket.LastMessageTime = storedTime(*rocket.LastMessageTime)
+	}
+	rocket.Version++
+	m.rockets[rocket.Channel] = rocket
+	return nil
+}
+
+func (m *MemoryRocketsRepository) Delete(_ context.Context, rocket Rocket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.rockets[rocket.Channel]
+	if !exists || stored.Version != rocket.Version {
+		return ErrVersionConflict
+	}
+	delete(m.rockets, rocket.Channel)
+	m.channels = slices.DeleteFunc(m.channels, func(channel uuid.UUID) bool { return channel == rocket.Channel })
+	return nil
+}
+
+type MemoryArchiveRepository struct {
+	mu       sync.RWMutex
+	archived map[uuid.UUID]ArchivedRocket
+}
+
+func NewMemoryArchiveRepository() *MemoryArchiveRepository {
+	return &MemoryArchiveRepository{
+		archived: make(map[uuid.UUID]ArchivedRocket),
+	}
+}
+
+func (a *MemoryArchiveRepository) Store(_ context.Context, archived ArchivedRocket) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	archived.Rocket = cloneRocket(archived.Rocket)
+	archived.Messages = cloneMessages(archived.Messages)
+	a.archived[archived.Rocket.Channel] = archived
+	return nil
+}
+
+func (a *MemoryArchiveRepository) FindByChannel(_ context.Context, channel uuid.UUID) (*ArchivedRocket, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	archived, exists := a.archived[channel]
+	if !exists {
+		return nil, ErrRocketNotFound
+	}
+	archived.Rocket = cloneRocket(archived.Rocket)
+	archived.Messages = cloneMessages(archived.Messages)
+	return &archived, nil
+}
+
+type MemoryWebhookRepository struct {
+	mu       sync.RWMutex
+	webhooks []Webhook
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{}
+}
+
+func (w *MemoryWebhookRepository) Store(_ context.Context, webhook Webhook) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	webhook.Events = slices.Clone(webhook.Events)
+	if i := slices.IndexFunc(w.webhooks, func(stored Webhook) bool { return stored.ID == webhook.ID }); i >= 0 {
+		w.webhooks[i] = webhook
+		return nil
+	}
+	w.webhooks = append(w.webhooks, webhook)
+	return nil
+}
+
+func (w *MemoryWebhookRepository) All(_ context.Context) ([]Webhook, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	webhooks := make([]Webhook, 0, len(w.webhooks))
+	for _, webhook := range w.webhooks {
+		webhook.Events = slices.Clone(webhook.Events)
+		webhooks = append(webhooks, webhook)
+	}
+	sort.SliceStable(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (w *MemoryWebhookRepository) FindByID(_ context.Context, id uuid.UUID) (*Webhook, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	i := slices.IndexFunc(w.webhooks, func(stored Webhook) bool { return stored.ID == id })
+	if i < 0 {
+		return nil, ErrWebhookNotFound
+	}
+	webhook := w.webhooks[i]
+	webhook.Events = slices.Clone(webhook.Events)
+	return &webhook, nil
+}
+
+func (w *MemoryWebhookRepository) Delete(_ context.Context, id uuid.UUID) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := slices.IndexFunc(w.webhooks, func(stored Webhook) bool { return stored.ID == id })
+	if i < 0 {
+		return ErrWebhookNotFound
+	}
+	w.webhooks = slices.Delete(w.webhooks, i, i+1)
+	return nil
+}
+
+type MemoryDeadLetterRepository struct {
+	mu         sync.RWMutex
+	deliveries map[uuid.UUID]WebhookDelivery
+}
+
+func NewMemoryDeadLetterRepository() *MemoryDeadLetterRepository {
+	return &MemoryDeadLetterRepository{
+		deliveries: make(map[uuid.UUID]WebhookDelivery),
+	}
+}
+
+func (d *MemoryDeadLetterRepository) Store(_ context.Context, delivery WebhookDelivery) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delivery.Event.Rocket = cloneRocket(delivery.Event.Rocket)
+	d.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (d *MemoryDeadLetterRepository) All(_ context.Context) ([]WebhookDelivery, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	deliveries := make([]WebhookDelivery, 0, len(d.deliveries))
+	for _, delivery := range d.deliveries {
+		delivery.Event.Rocket = cloneRocket(delivery.Event.Rocket)
+		deliveries = append(deliveries, delivery)
+	}
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+func (d *MemoryDeadLetterRepository) FindByID(_ context.Context, id uuid.UUID) (*WebhookDelivery, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	delivery, exists := d.deliveries[id]
+	if !exists {
+		return nil, ErrDeliveryNotFound
+	}
+	delivery.Event.Rocket = cloneRocket(delivery.Event.Rocket)
+	return &delivery, nil
+}
+
+func (d *MemoryDeadLetterRepository) Delete(_ context.Context, id uuid.UUID) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.deliveries[id]; !exists {
+		return ErrDeliveryNotFound
+	}
+	delete(d.deliveries, id)
+	return nil
+}
+
+// sortDeliveries orders the deliveries by failure, the oldest first.
+func sortDeliveries(deliveries []WebhookDelivery) {
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].FailedAt.Before(deliveries[j].FailedAt)
+	})
+}
+
+// storedTime drops what mongo would not keep of the instant.
+func storedTime(t time.Time) time.Time {
+	return t.Truncate(time.Millisecond).UTC()
+}
+
+// cloneMessages copies the messages so callers can't change the stored payloads.
+func cloneMessages(messages []Message) []Message {
+	clones := make([]Message, len(messages))
+	for i, message := range messages {
+		message.Message = maps.Clone(message.Message)
+		clones[i] = message
+	}
+	return clones
+}
+
+// cloneRocket copies the fields the rocket shares through pointers and slices.
+func cloneRocket(rocket Rocket) Rocket {
+	if rocket.ExplosionReason != nil {
+		reason := *rocket.ExplosionReason
+		rocket.ExplosionReason = &reason
+	}
+	if rocket.LastMessageNumber != nil {
+		number := *rocket.LastMessageNumber
+		rocket.LastMessageNumber = &number
+	}
+	if rocket.LaunchTime != nil {
+		launchTime := *rocket.LaunchTime
+		rocket.LaunchTime = &launchTime
+	}
+	if rocket.LastMessageTime != nil {
+		messageTime := *rocket.LastMessageTime
+		rocket.LastMessageTime = &messageTime
+	}
+	rocket.SkippedMessages = slices.Clone(rocket.SkippedMessages)
+	return rocket
+}
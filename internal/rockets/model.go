@@ -1,6 +1,7 @@
 package rockets
 
 import (
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,22 @@ type Metadata struct {
 type Message struct {
 	Metadata Metadata               `json:"metadata"`
 	Message  map[string]interface{} `json:"message"`
+	// ReceivedAt is when the message was stored, it is set by the repository.
+	ReceivedAt time.Time `json:"-"`
+	// Redeliveries counts the times the message was received again after being stored, the last one at RedeliveredAt.
+	// Both are kept by the repository.
+	Redeliveries  int       `json:"-"`
+	RedeliveredAt time.Time `json:"-"`
+}
+
+// SameContent reports whether both messages describe the same event. Message times are compared with millisecond
+// precision, which is what the storage keeps.
+func (m Message) SameContent(other Message) bool {
+	return m.Metadata.Channel == other.Metadata.Channel &&
+		m.Metadata.MessageNumber == other.Metadata.MessageNumber &&
+		m.Metadata.MessageType == other.Metadata.MessageType &&
+		m.Metadata.MessageTime.Truncate(time.Millisecond).Equal(other.Metadata.MessageTime.Truncate(time.Millisecond)) &&
+		reflect.DeepEqual(m.Message, other.Message)
 }
 
 type Rocket struct {
@@ -27,4 +44,34 @@ type Rocket struct {
 	ExplosionReason   *string    `json:"explosionReason,omitempty"`
 	LastMessageNumber *int       `json:"lastMessageNumber,omitempty"`
 	LastMessageTime   *time.Time `json:"lastMessageTime,omitempty"`
+	// PeakSpeed is the highest speed the rocket reached.
+	PeakSpeed int `json:"peakSpeed"`
+	// TotalAcceleration adds up the speed increases, decreases don't take from it.
+	TotalAcceleration int `json:"totalAcceleration"`
+	// MissionChanges counts the mission changes since the launch.
+	MissionChanges int        `json:"missionChanges"`
+	LaunchTime     *time.Time `json:"launchTime,omitempty"`
+	// FlightDuration is the time in seconds from the launch to the latest message applied, it never goes back when a
+	// message was sent before an earlier one.
+	FlightDuration float64 `json:"flightDuration"`
+	// Distance estimates the distance travelled, as the speed held between messages times the time in seconds between
+	// them.
+	Distance float64 `json:"distance"`
+	// PendingMessages is the number of stored messages waiting behind a missing message number.
+	PendingMessages int `json:"pendingMessages"`
+	// SkippedMessages are the message numbers given up on by the gap policy, lowest first.
+	SkippedMessages []SkippedRange `json:"skippedMessages,omitempty"`
+	// Version counts the writes of the rocket, it is 0 until the rocket is stored.
+	Version int `json:"-"`
+}
+
+// SkippedRange is a range of message numbers, both ends included.
+type SkippedRange struct {
+	From int `json:"from" bson:"from"`
+	To   int `json:"to" bson:"to"`
+}
+
+// InSync reports whether every stored message of the rocket has been applied.
+func (r Rocket) InSync() bool {
+	return r.PendingMessages == 0
 }
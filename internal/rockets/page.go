@@ -0,0 +1,292 @@
+package rockets
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RocketSortFields are the fields rockets can be sorted by, named as in the API and the stored documents.
+var RocketSortFields = []string{
+	"type", "speed", "mission", "status", "lastMessageTime", "lastMessageNumber", "channel",
+	"peakSpeed", "totalAcceleration", "missionChanges", "launchTime", "flightDuration", "distance",
+}
+
+// SortKey is a field the rockets are sorted by, ascending unless Descending. Rockets without a value for the field,
+// like a rocket that never applied a message, sort first.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// RocketQuery asks for a page of the fleet. Rockets are ordered by the sort keys and then by channel, so no two rockets
+// tie and a cursor keeps its place while rockets are launched or change in between pages.
+type RocketQuery struct {
+	Filter RocketFilter
+	Sort   []SortKey
+	// Limit is the most rockets in the page, zero returns every rocket after the cursor.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first page.
+	Cursor string
+}
+
+// RocketPage is a page of the fleet. Total counts every rocket matching the filter and NextCursor is empty on the last
+// page.
+type RocketPage struct {
+	Rockets    []Rocket
+	NextCursor string
+	Total      int
+}
+
+// rocketCursor is the position of the last rocket of a page: its value of every sort key. It keeps the ordering it was
+// made for, as it means nothing in another one.
+type rocketCursor struct {
+	Sort   string            `json:"sort"`
+	Values []json.RawMessage `json:"values"`
+}
+
+// ParseRocketSort reads sort keys like "status,-speed,lastMessageTime", where a minus sorts the field in descending
+// order, and a descending order reverses every key. The channel ends the keys when it is not among them. Fields outside
+// RocketSortFields, or repeated, are reported with a ValidationError.
+func ParseRocketSort(sortBy string, descending bool) ([]SortKey, error) {
+	var keys []SortKey
+	if sortBy != "" {
+		for _, field := range strings.Split(sortBy, ",") {
+			var key SortKey
+			key.Field, key.Descending = strings.CutPrefix(field, "-")
+			if !slices.Contains(RocketSortFields, key.Field) {
+				return nil, &ValidationError{Field: "sortBy", Reason: fmt.Sprintf("has unknown field %q", field)}
+			}
+			if slices.ContainsFunc(keys, func(other SortKey) bool { return other.Field == key.Field }) {
+				return nil, &ValidationError{Field: "sortBy", Reason: fmt.Sprintf("repeats field %q", key.Field)}
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	keys = withChannel(keys)
+	if descending {
+		for i := range keys {
+			keys[i].Descending = !keys[i].Descending
+		}
+	}
+	return keys, nil
+}
+
+// sortKeys are the keys of the query that are in RocketSortFields, ended by the channel. Repositories only sort by
+// these, whatever the query was built from.
+func (q RocketQuery) sortKeys() []SortKey {
+	keys := make([]SortKey, 0, len(q.Sort)+1)
+	for _, key := range q.Sort {
+		if slices.Contains(RocketSortFields, key.Field) {
+			keys = append(keys, key)
+		}
+	}
+	return withChannel(keys)
+}
+
+func withChannel(keys []SortKey) []SortKey {
+	if slices.ContainsFunc(keys, func(key SortKey) bool { return key.Field == "channel" }) {
+		return keys
+	}
+	return append(keys, SortKey{Field: "channel"})
+}
+
+func formatSort(keys []SortKey) string {
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.Descending {
+			fields = append(fields, "-"+key.Field)
+		} else {
+			fields = append(fields, key.Field)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// after decodes the cursor of the query into the sort values it points after, nil when it starts from the first
+// rocket. It returns ErrInvalidCursor when the cursor is malformed or was made for another ordering.
+func (q RocketQuery) after() ([]any, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor rocketCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	keys := q.sortKeys()
+	if cursor.Sort != formatSort(keys) || len(cursor.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		if values[i], err = decodeSortValue(key.Field, cursor.Values[i]); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// page cuts the rockets following the cursor, in order, down to the limit and points the next cursor at the last one.
+func (q RocketQuery) page(rockets []Rocket, total int) *RocketPage {
+	page := &RocketPage{Rockets: rockets, Total: total}
+	if q.Limit > 0 && len(rockets) > q.Limit {
+		page.Rockets = rockets[:q.Limit]
+		page.NextCursor = q.cursorAt(page.Rockets[q.Limit-1])
+	}
+	return page
+}
+
+func (q RocketQuery) cursorAt(rocket Rocket) string {
+	keys := q.sortKeys()
+	cursor := rocketCursor{Sort: formatSort(keys)}
+	for _, value := range sortValues(rocket, keys) {
+		data, _ := json.Marshal(value)
+		cursor.Values = append(cursor.Values, data)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sortValue is the value of the field compared when sorting: a string, a number, a time or nil when the rocket has none.
+// The channel is compared as its string, which sorts like its bytes and like the stored channels.
+func sortValue(rocket Rocket, field string) any {
+	switch field {
+	case "type":
+		return rocket.Type
+	case "speed":
+		return rocket.Speed
+	case "mission":
+		return rocket.Mission
+	case "status":
+		return rocket.Status
+	case "lastMessageTime":
+		if rocket.LastMessageTime != nil {
+			return *rocket.LastMessageTime
+		}
+	case "lastMessageNumber":
+		if rocket.LastMessageNumber != nil {
+			return *rocket.LastMessageNumber
+		}
+	case "channel":
+		return rocket.Channel.String()
+	case "peakSpeed":
+		return rocket.PeakSpeed
+	case "totalAcceleration":
+		return rocket.TotalAcceleration
+	case "missionChanges":
+		return rocket.MissionChanges
+	case "launchTime":
+		if rocket.LaunchTime != nil {
+			return *rocket.LaunchTime
+		}
+	case "flightDuration":
+		return rocket.FlightDuration
+	case "distance":
+		return rocket.Distance
+	}
+	return nil
+}
+
+func decodeSortValue(field string, data json.RawMessage) (any, error) {
+	if string(data) == "null" {
+		return nil, nil
+	}
+	switch field {
+	case "speed", "lastMessageNumber", "peakSpeed", "totalAcceleration", "missionChanges":
+		var number int
+		err := json.Unmarshal(data, &number)
+		return number, err
+	case "flightDuration", "distance":
+		var number float64
+		err := json.Unmarshal(data, &number)
+		return number, err
+	case "lastMessageTime", "launchTime":
+		var instant time.Time
+		err := json.Unmarshal(data, &instant)
+		return instant, err
+	default:
+		var text string
+		err := json.Unmarshal(data, &text)
+		return text, err
+	}
+}
+
+func sortValues(rocket Rocket, keys []SortKey) []any {
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		values[i] = sortValue(rocket, key.Field)
+	}
+	return values
+}
+
+// compareSortValues compares the sort values of two rockets key by key. Nil values come first, as in mongo.
+func compareSortValues(a, b []any, keys []SortKey) int {
+	for i, key := range keys {
+		var order int
+		switch {
+		case a[i] == nil && b[i] == nil:
+		case a[i] == nil:
+			order = -1
+		case b[i] == nil:
+			order = 1
+		default:
+			switch value := a[i].(type) {
+			case string:
+				order = strings.Compare(value, b[i].(string))
+			case int:
+				order = cmp.Compare(value, b[i].(int))
+			case float64:
+				order = cmp.Compare(value, b[i].(float64))
+			case time.Time:
+				order = value.Compare(b[i].(time.Time))
+			}
+		}
+		if key.Descending {
+			order = -order
+		}
+		if order != 0 {
+			return order
+		}
+	}
+	return 0
+}
+
+// pageRockets filters and sorts the rockets and returns the page of the query, the way RocketsRepository.All does in
+// the database.
+func pageRockets(rockets []Rocket, query RocketQuery) (*RocketPage, error) {
+	after, err := query.after()
+	if err != nil {
+		return nil, err
+	}
+
+	rockets = slices.DeleteFunc(rockets, func(rocket Rocket) bool {
+		return !query.Filter.matches(rocket)
+	})
+	keys := query.sortKeys()
+	slices.SortFunc(rockets, func(a, b Rocket) int {
+		return compareSortValues(sortValues(a, keys), sortValues(b, keys), keys)
+	})
+
+	following := rockets
+	if after != nil {
+		// The channel is among the keys, so only the cursor rocket itself, if it is still there, compares equal
+		start, found := slices.BinarySearchFunc(rockets, after, func(rocket Rocket, after []any) int {
+			return compareSortValues(sortValues(rocket, keys), after, keys)
+		})
+		if found {
+			start++
+		}
+		following = rockets[start:]
+	}
+	return query.page(following, len(rockets)), nil
+}
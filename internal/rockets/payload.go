@@ -0,0 +1,307 @@
+package rockets
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RocketLaunched       = "RocketLaunched"
+	RocketSpeedIncreased = "RocketSpeedIncreased"
+	RocketSpeedDecreased = "RocketSpeedDecreased"
+	RocketExploded       = "RocketExploded"
+	RocketMissionChanged = "RocketMissionChanged"
+	// RocketSnapshot is written by the compaction in place of the messages it folds, it is never received.
+	RocketSnapshot = "RocketSnapshot"
+)
+
+type RocketLaunchedPayload struct {
+	Type        string
+	LaunchSpeed int
+	Mission     string
+}
+
+type RocketSpeedIncreasedPayload struct {
+	By int
+}
+
+type RocketSpeedDecreasedPayload struct {
+	By int
+}
+
+type RocketExplodedPayload struct {
+	Reason string
+}
+
+type RocketMissionChangedPayload struct {
+	NewMission string
+}
+
+// RocketSnapshotPayload is the state of the rocket after the message the snapshot replaces.
+type RocketSnapshotPayload struct {
+	Type            string
+	Speed           int
+	Mission         string
+	Status          string
+	ExplosionReason *string
+	// Snapshots written before the derived metrics existed lack them, their peak speed is then the speed.
+	PeakSpeed         int
+	TotalAcceleration int
+	MissionChanges    int
+	LaunchTime        *time.Time
+	FlightDuration    float64
+	Distance          float64
+}
+
+// ValidationError describes why a message is rejected. Field is the JSON path of the offending field.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
+
+// ValidateMessage checks the metadata and the payload of a message before it is stored.
+func ValidateMessage(message Message) error {
+	switch {
+	case message.Metadata.Channel == uuid.Nil:
+		return &ValidationError{Field: "metadata.channel", Reason: "is required"}
+	case message.Metadata.MessageNumber <= 0:
+		return &ValidationError{Field: "metadata.messageNumber", Reason: "must be positive"}
+	case message.Metadata.MessageTime.IsZero():
+		return &ValidationError{Field: "metadata.messageTime", Reason: "is required"}
+	case message.Metadata.MessageType == "":
+		return &ValidationError{Field: "metadata.messageType", Reason: "is required"}
+	case message.Metadata.MessageType == RocketSnapshot:
+		return &ValidationError{Field: "metadata.messageType", Reason: fmt.Sprintf("has unknown value %q", RocketSnapshot)}
+	}
+
+	_, err := DecodePayload(message)
+	return err
+}
+
+// DecodePayload returns the typed payload of the message, one of the *Payload types of this package.
+func DecodePayload(message Message) (any, error) {
+	fields := message.Message
+	switch message.Metadata.MessageType {
+	case RocketLaunched:
+		rocketType, err := stringField(fields, "type")
+		if err != nil {
+			return nil, err
+		}
+		launchSpeed, err := nonNegativeIntField(fields, "launchSpeed")
+		if err != nil {
+			return nil, err
+		}
+		mission, err := stringField(fields, "mission")
+		if err != nil {
+			return nil, err
+		}
+		return RocketLaunchedPayload{Type: rocketType, LaunchSpeed: launchSpeed, Mission: mission}, nil
+
+	case RocketSpeedIncreased:
+		by, err := nonNegativeIntField(fields, "by")
+		if err != nil {
+			return nil, err
+		}
+		return RocketSpeedIncreasedPayload{By: by}, nil
+
+	case RocketSpeedDecreased:
+		by, err := nonNegativeIntField(fields, "by")
+		if err != nil {
+			return nil, err
+		}
+		return RocketSpeedDecreasedPayload{By: by}, nil
+
+	case RocketExploded:
+		reason, err := stringField(fields, "reason")
+		if err != nil {
+			return nil, err
+		}
+		return RocketExplodedPayload{Reason: reason}, nil
+
+	case RocketMissionChanged:
+		newMission, err := stringField(fields, "newMission")
+		if err != nil {
+			return nil, err
+		}
+		return RocketMissionChangedPayload{NewMission: newMission}, nil
+
+	case RocketSnapshot:
+		return decodeSnapshot(fields)
+
+	default:
+		return nil, &ValidationError{Field: "metadata.messageType", Reason: fmt.Sprintf("has unknown value %q", message.Metadata.MessageType)}
+	}
+}
+
+// replayPayload decodes a stored message to apply it. Messages stored before payloads were validated may break the
+// rules of DecodePayload, and the log they are in must still replay: strings read as stored, empty when missing, and
+// numbers as stored, negative or fractional ones included. Only a message that can't be applied at all fails, a speed
+// or mission change without its value or a type that isn't known.
+func replayPayload(message Message) (any, error) {
+	fields := message.Message
+	switch message.Metadata.MessageType {
+	case RocketLaunched:
+		launchSpeed, _ := numberField(fields, "launchSpeed")
+		return RocketLaunchedPayload{Type: storedString(fields, "type"), LaunchSpeed: int(launchSpeed), Mission: storedString(fields, "mission")}, nil
+
+	case RocketSpeedIncreased:
+		by, err := numberField(fields, "by")
+		if err != nil {
+			return nil, err
+		}
+		return RocketSpeedIncreasedPayload{By: int(by)}, nil
+
+	case RocketSpeedDecreased:
+		by, err := numberField(fields, "by")
+		if err != nil {
+			return nil, err
+		}
+		return RocketSpeedDecreasedPayload{By: int(by)}, nil
+
+	case RocketExploded:
+		return RocketExplodedPayload{Reason: storedString(fields, "reason")}, nil
+
+	case RocketMissionChanged:
+		newMission, ok := fields["newMission"].(string)
+		if !ok {
+			return nil, &ValidationError{Field: "message.newMission", Reason: "must be a string"}
+		}
+		return RocketMissionChangedPayload{NewMission: newMission}, nil
+
+	default:
+		return DecodePayload(message)
+	}
+}
+
+// storedString reads a string of a stored message, empty when it is missing or isn't a string.
+func storedString(fields map[string]interface{}, name string) string {
+	str, _ := fields[name].(string)
+	return str
+}
+
+func stringField(fields map[string]interface{}, name string) (string, error) {
+	value, exists := fields[name]
+	if !exists || value == nil {
+		return "", &ValidationError{Field: "message." + name, Reason: "is required"}
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", &ValidationError{Field: "message." + name, Reason: "must be a string"}
+	}
+	if str == "" {
+		return "", &ValidationError{Field: "message." + name, Reason: "must not be empty"}
+	}
+	return str, nil
+}
+
+func nonNegativeIntField(fields map[string]interface{}, name string) (int, error) {
+	number, err := intField(fields, name)
+	if err != nil {
+		return 0, err
+	}
+	if number < 0 {
+		return 0, &ValidationError{Field: "message." + name, Reason: "must not be negative"}
+	}
+	return number, nil
+}
+
+func intField(fields map[string]interface{}, name string) (int, error) {
+	number, err := numberField(fields, name)
+	if err != nil {
+		return 0, err
+	}
+	if number != math.Trunc(number) {
+		return 0, &ValidationError{Field: "message." + name, Reason: "must be an integer"}
+	}
+	if number > math.MaxInt32 {
+		return 0, &ValidationError{Field: "message." + name, Reason: "is too large"}
+	}
+	if number < math.MinInt32 {
+		return 0, &ValidationError{Field: "message." + name, Reason: "is too small"}
+	}
+	return int(number), nil
+}
+
+func numberField(fields map[string]interface{}, name string) (float64, error) {
+	value, exists := fields[name]
+	if !exists || value == nil {
+		return 0, &ValidationError{Field: "message." + name, Reason: "is required"}
+	}
+
+	// JSON numbers decode to float64, while the storage may hand back integers
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	default:
+		return 0, &ValidationError{Field: "message." + name, Reason: "must be a number"}
+	}
+}
+
+// timeField reads a time written as RFC 3339 text, which every storage hands back as it was written.
+func timeField(fields map[string]interface{}, name string) (time.Time, error) {
+	text, err := stringField(fields, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return time.Time{}, &ValidationError{Field: "message." + name, Reason: "must be an RFC 3339 time"}
+	}
+	return parsed, nil
+}
+
+func decodeSnapshot(fields map[string]interface{}) (RocketSnapshotPayload, error) {
+	// The strings come from a replayed rocket, which holds them empty when its messages lacked them
+	snapshot := RocketSnapshotPayload{Type: storedString(fields, "type"), Mission: storedString(fields, "mission")}
+	var err error
+	if snapshot.Speed, err = intField(fields, "speed"); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Status, err = stringField(fields, "status"); err != nil {
+		return snapshot, err
+	}
+	if reason, ok := fields["explosionReason"].(string); ok {
+		snapshot.ExplosionReason = &reason
+	}
+
+	snapshot.PeakSpeed = snapshot.Speed
+	if _, exists := fields["peakSpeed"]; !exists {
+		return snapshot, nil
+	}
+	if snapshot.PeakSpeed, err = intField(fields, "peakSpeed"); err != nil {
+		return snapshot, err
+	}
+	if snapshot.TotalAcceleration, err = intField(fields, "totalAcceleration"); err != nil {
+		return snapshot, err
+	}
+	if snapshot.MissionChanges, err = intField(fields, "missionChanges"); err != nil {
+		return snapshot, err
+	}
+	if _, exists := fields["launchTime"]; exists {
+		launchTime, err := timeField(fields, "launchTime")
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.LaunchTime = &launchTime
+	}
+	if snapshot.FlightDuration, err = numberField(fields, "flightDuration"); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Distance, err = numberField(fields, "distance"); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,384 @@
+package rockets
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RocketRebuild compares a stored rocket with the one replayed from its message log.
+type RocketRebuild struct {
+	Channel uuid.UUID `json:"channel"`
+	// Changes names the fields the replay changed, empty when the stored rocket was right.
+	Changes []string `json:"changes"`
+	Before  Rocket   `json:"before"`
+	After   Rocket   `json:"after"`
+}
+
+func (r RocketRebuild) Changed() bool {
+	return len(r.Changes) > 0
+}
+
+// RebuildOptions tune a fleet rebuild. The zero value writes every rocket, one at a time.
+type RebuildOptions struct {
+	// DryRun only reports the rockets that would change.
+	DryRun bool
+	// Concurrency is how many rockets are rebuilt at once.
+	Concurrency int
+	// Progress is called after every rocket with the report so far.
+	Progress func(RebuildReport)
+}
+
+// RebuildReport tells how far a fleet rebuild went and which rockets it changed, or would change in a dry run.
+type RebuildReport struct {
+	DryRun   bool             `json:"dryRun"`
+	Total    int              `json:"total"`
+	Done     int              `json:"done"`
+	Changed  int              `json:"changed"`
+	Failed   int              `json:"failed"`
+	Rockets  []RocketRebuild  `json:"rockets"`
+	Failures []RebuildFailure `json:"failures"`
+}
+
+type RebuildFailure struct {
+	Channel uuid.UUID `json:"channel"`
+	Error   string    `json:"error"`
+}
+
+// RebuildJob is a fleet rebuild running in the background. Report is updated as rockets are rebuilt.
+type RebuildJob struct {
+	ID         uuid.UUID
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	// Error is why the rebuild stopped before going through the fleet.
+	Error  string
+	Report RebuildReport
+}
+
+func (j RebuildJob) Running() bool {
+	return j.FinishedAt == nil
+}
+
+// maxRebuildJobs is how many rebuild jobs are remembered, the oldest ones are forgotten first.
+const maxRebuildJobs = 10
+
+// RebuildService recomputes stored rockets from their message logs, after a fix to how messages are applied. A rocket
+// is replayed up to the last message it applied and written with the version check, so a message processed meanwhile
+// makes the rebuild start over instead of being lost.
+type RebuildService struct {
+	messageRepository MessageRepository
+	rocketsRepository RocketsRepository
+	feed              *RocketFeed
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs []*RebuildJob
+}
+
+func NewRebuildService(messageRepository MessageRepository, rocketsRepository RocketsRepository) *RebuildService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RebuildService{
+		messageRepository: messageRepository,
+		rocketsRepository: rocketsRepository,
+		ctx:               ctx,
+		cancel:            cancel,
+	}
+}
+
+// PublishTo sends the rockets a rebuild changes to the feed, so subscribers see the fix too.
+func (s *RebuildService) PublishTo(feed *RocketFeed) {
+	s.feed = feed
+}
+
+// Rebuild recomputes the rocket of the channel. A dry run returns the same comparison without writing the rocket.
+func (s *RebuildService) Rebuild(ctx context.Context, channel uuid.UUID, dryRun bool) (*RocketRebuild, error) {
+	var result *RocketRebuild
+	err := retryOnConflict(func() error {
+		stored, err := s.rocketsRepository.FindByChannel(ctx, channel)
+		if err != nil {
+			return err
+		}
+		messages, err := s.messageRepository.FindByChannel(ctx, channel)
+		if err != nil {
+			return err
+		}
+		rebuilt, err := replay(*stored, messages)
+		if err != nil {
+			return err
+		}
+
+		result = &RocketRebuild{Channel: channel, Changes: changedFields(*stored, *rebuilt), Before: *stored, After: *rebuilt}
+		if dryRun || !result.Changed() {
+			return nil
+		}
+		if err := s.rocketsRepository.Upsert(ctx, *rebuilt); err != nil {
+			return err
+		}
+		s.feed.Publish(*rebuilt)
+		slog.Info("rebuilt rocket", "channel", channel, "changes", result.Changes)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RebuildAll recomputes every rocket, the given number at a time. A rocket that fails is reported and the others go
+// on; only failing to list the fleet or a cancelled context stops the rebuild.
+func (s *RebuildService) RebuildAll(ctx context.Context, options RebuildOptions) (*RebuildReport, error) {
+	page, err := s.rocketsRepository.All(ctx, RocketQuery{})
+	if err != nil {
+		return nil, err
+	}
+	fleet := page.Rockets
+
+	report := &RebuildReport{
+		DryRun:   options.DryRun,
+		Total:    len(fleet),
+		Rockets:  make([]RocketRebuild, 0),
+		Failures: make([]RebuildFailure, 0),
+	}
+	if options.Progress != nil {
+		options.Progress(report.clone())
+	}
+
+	channels := make(chan uuid.UUID)
+	var mu sync.Mutex
+	var workers sync.WaitGroup
+	for i := 0; i < max(options.Concurrency, 1); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for channel := range channels {
+				result, err := s.Rebuild(ctx, channel, options.DryRun)
+
+				mu.Lock()
+				report.Done++
+				switch {
+				case err != nil:
+					report.Failed++
+					report.Failures = append(report.Failures, RebuildFailure{Channel: channel, Error: err.Error()})
+				case result.Changed():
+					report.Changed++
+					report.Rockets = append(report.Rockets, *result)
+				}
+				if options.Progress != nil {
+					options.Progress(report.clone())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, rocket := range fleet {
+		select {
+		case channels <- rocket.Channel:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(channels)
+	workers.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// Start runs a fleet rebuild in the background and returns its job. Only one rebuild runs at a time.
+func (s *RebuildService) Start(options RebuildOptions) (RebuildJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return RebuildJob{}, ErrShuttingDown
+	}
+	for _, job := range s.jobs {
+		if job.Running() {
+			return RebuildJob{}, ErrRebuildRunning
+		}
+	}
+
+	job := &RebuildJob{ID: uuid.New(), StartedAt: time.Now(), Report: RebuildReport{DryRun: options.DryRun}}
+	s.jobs = append(s.jobs, job)
+	if len(s.jobs) > maxRebuildJobs {
+		s.jobs = s.jobs[1:]
+	}
+
+	progress := options.Progress
+	options.Progress = func(report RebuildReport) {
+		s.mu.Lock()
+		job.Report = report
+		s.mu.Unlock()
+		if progress != nil {
+			progress(report)
+		}
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		report, err := s.RebuildAll(s.ctx, options)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		finishedAt := time.Now()
+		job.FinishedAt = &finishedAt
+		if report != nil {
+			job.Report = *report
+		}
+		if err != nil {
+			job.Error = err.Error()
+			slog.Error("fleet rebuild stopped", "job", job.ID, "error", err)
+			return
+		}
+		slog.Info("fleet rebuild finished", "job", job.ID, "rockets", job.Report.Total, "changed", job.Report.Changed, "failed", job.Report.Failed)
+	}()
+
+	return job.clone(), nil
+}
+
+// Job returns the state of a rebuild started with Start, or ErrRebuildNotFound once it is forgotten.
+func (s *RebuildService) Job(id uuid.UUID) (RebuildJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return job.clone(), nil
+		}
+	}
+	return RebuildJob{}, ErrRebuildNotFound
+}
+
+// Shutdown stops the running rebuild and waits for it, or for ctx to be done. Rockets rebuilt so far stay written.
+func (s *RebuildService) Shutdown(ctx context.Context) error {
+	s.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replay computes the rocket from its log up to the last message it applied. Numbers missing below that point are the
+// ones the gap policy skipped, they are skipped again, and the messages after it are still pending.
+func replay(stored Rocket, messages []Message) (*Rocket, error) {
+	if stored.LastMessageNumber == nil {
+		return nil, ErrIncompleteLog
+	}
+	last := *stored.LastMessageNumber
+
+	messages = sinceSnapshot(messages)
+	applied := 0
+	for applied < len(messages) && messages[applied].Metadata.MessageNumber <= last {
+		applied++
+	}
+	// The launch, or the snapshot that folded it, has to be there, and so does the last message
+	if applied == 0 || messages[applied-1].Metadata.MessageNumber != last ||
+		messages[0].Metadata.MessageNumber != 1 && messages[0].Metadata.MessageType != RocketSnapshot {
+		return nil, ErrIncompleteLog
+	}
+
+	rocket, err := buildRocketState(stored.Channel, messages[:applied])
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < applied; i++ {
+		previous, number := messages[i-1].Metadata.MessageNumber, messages[i].Metadata.MessageNumber
+		if number > previous+1 {
+			rocket.SkippedMessages = append(rocket.SkippedMessages, SkippedRange{From: previous + 1, To: number - 1})
+		}
+	}
+	rocket.PendingMessages = len(messages) - applied
+	rocket.Version = stored.Version
+	return rocket, nil
+}
+
+// changedFields names, as in the JSON of a rocket, the fields that differ between both states.
+func changedFields(before, after Rocket) []string {
+	changes := make([]string, 0)
+	if before.Type != after.Type {
+		changes = append(changes, "type")
+	}
+	if before.Speed != after.Speed {
+		changes = append(changes, "speed")
+	}
+	if before.Mission != after.Mission {
+		changes = append(changes, "mission")
+	}
+	if before.Status != after.Status {
+		changes = append(changes, "status")
+	}
+	if !equalPointers(before.ExplosionReason, after.ExplosionReason, func(a, b string) bool { return a == b }) {
+		changes = append(changes, "explosionReason")
+	}
+	if !equalPointers(before.LastMessageNumber, after.LastMessageNumber, func(a, b int) bool { return a == b }) {
+		changes = append(changes, "lastMessageNumber")
+	}
+	if !equalPointers(before.LastMessageTime, after.LastMessageTime, time.Time.Equal) {
+		changes = append(changes, "lastMessageTime")
+	}
+	if before.PeakSpeed != after.PeakSpeed {
+		changes = append(changes, "peakSpeed")
+	}
+	if before.TotalAcceleration != after.TotalAcceleration {
+		changes = append(changes, "totalAcceleration")
+	}
+	if before.MissionChanges != after.MissionChanges {
+		changes = append(changes, "missionChanges")
+	}
+	if !equalPointers(before.LaunchTime, after.LaunchTime, time.Time.Equal) {
+		changes = append(changes, "launchTime")
+	}
+	if before.FlightDuration != after.FlightDuration {
+		changes = append(changes, "flightDuration")
+	}
+	if before.Distance != after.Distance {
+		changes = append(changes, "distance")
+	}
+	if before.PendingMessages != after.PendingMessages {
+		changes = append(changes, "pendingMessages")
+	}
+	if !slices.Equal(before.SkippedMessages, after.SkippedMessages) {
+		changes = append(changes, "skippedMessages")
+	}
+	return changes
+}
+
+func equalPointers[T any](a, b *T, equal func(T, T) bool) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return equal(*a, *b)
+}
+
+func (r RebuildReport) clone() RebuildReport {
+	r.Rockets = slices.Clone(r.Rockets)
+	r.Failures = slices.Clone(r.Failures)
+	return r
+}
+
+func (j *RebuildJob) clone() RebuildJob {
+	clone := *j
+	clone.Report = j.Report.clone()
+	return clone
+}
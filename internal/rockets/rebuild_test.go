@@ -0,0 +1,212 @@
+package rockets
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corrupt stores the rocket of the channel with a wrong speed, like a bug in applyMessage would have.
+func corrupt(t *testing.T, rocketsRepository RocketsRepository, channel uuid.UUID) Rocket {
+	rocket, err := rocketsRepository.FindByChannel(context.Background(), channel)
+	require.NoError(t, err)
+	rocket.Speed = 0
+	require.NoError(t, rocketsRepository.Upsert(context.Background(), *rocket))
+	rocket.Version++
+	return *rocket
+}
+
+func TestRebuildService_Rebuild(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{MaxPending: 2})
+	channel := uuid.New()
+
+	// Message 4 is skipped and message 8 is pending behind 7
+	for _, message := range flight(channel, 8, time.Now()) {
+		if number := message.Metadata.MessageNumber; number != 4 && number != 7 {
+			require.NoError(t, messageService.Ingest(ctx, message))
+		}
+	}
+	right, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	require.Equal(t, []SkippedRange{{From: 4, To: 4}}, right.SkippedMessages)
+	require.Equal(t, 1, right.PendingMessages)
+	wrong := corrupt(t, rocketsRepository, channel)
+
+	rebuilds := NewRebuildService(messageRepository, rocketsRepository)
+	diff, err := rebuilds.Rebuild(ctx, channel, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"speed"}, diff.Changes)
+	assert.Equal(t, wrong, diff.Before)
+	assert.Equal(t, right.Speed, diff.After.Speed)
+	stored, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, wrong, *stored)
+
+	rebuilt, err := rebuilds.Rebuild(ctx, channel, false)
+	require.NoError(t, err)
+	assert.Equal(t, diff, rebuilt)
+	stored, err = rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	right.Version = stored.Version
+	assert.Equal(t, *right, *stored)
+
+	// A right rocket is left as it is
+	rebuilt, err = rebuilds.Rebuild(ctx, channel, false)
+	require.NoError(t, err)
+	assert.False(t, rebuilt.Changed())
+	unchanged, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Version, unchanged.Version)
+
+	_, err = rebuilds.Rebuild(ctx, uuid.New(), false)
+	assert.ErrorIs(t, err, ErrRocketNotFound)
+}
+
+func TestRebuildService_RebuildsCompactedLog(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	channel := uuid.New()
+	for _, message := range flight(channel, 6, time.Now()) {
+		require.NoError(t, messageService.Ingest(ctx, message))
+	}
+	retention := NewRetentionService(messageRepository, rocketsRepository, NewMemoryArchiveRepository(), RetentionPolicy{KeepMessages: 2})
+	_, err := retention.Run(ctx, false)
+	require.NoError(t, err)
+	corrupt(t, rocketsRepository, channel)
+
+	rebuilt, err := NewRebuildService(messageRepository, rocketsRepository).Rebuild(ctx, channel, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"speed"}, rebuilt.Changes)
+	assert.Equal(t, 550, rebuilt.After.Speed)
+}
+
+func TestRebuildService_RebuildAll(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	var channels []uuid.UUID
+	for i := 0; i < 10; i++ {
+		channel := uuid.New()
+		channels = append(channels, channel)
+		for _, message := range flight(channel, 3, time.Now()) {
+			require.NoError(t, messageService.Ingest(ctx, message))
+		}
+	}
+	corrupt(t, rocketsRepository, channels[2])
+	corrupt(t, rocketsRepository, channels[7])
+
+	// A rocket whose log was lost can't be replayed
+	lost := uuid.New()
+	lostRocket := newRocket(lost, 0)
+	*lostRocket.LastMessageNumber = 1
+	require.NoError(t, rocketsRepository.Upsert(ctx, *lostRocket))
+
+	var mu sync.Mutex
+	var progress []int
+	report, err := NewRebuildService(messageRepository, rocketsRepository).RebuildAll(ctx, RebuildOptions{
+		DryRun:      true,
+		Concurrency: 3,
+		Progress: func(report RebuildReport) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, report.Done)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 11, report.Total)
+	assert.Equal(t, 11, report.Done)
+	assert.Equal(t, 2, report.Changed)
+	assert.ElementsMatch(t, []uuid.UUID{channels[2], channels[7]}, []uuid.UUID{report.Rockets[0].Channel, report.Rockets[1].Channel})
+	assert.Equal(t, []RebuildFailure{{Channel: lost, Error: IncompleteLogError}}, report.Failures)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, progress)
+
+	// The dry run wrote nothing
+	stored, err := rocketsRepository.FindByChannel(ctx, channels[2])
+	require.NoError(t, err)
+	assert.Equal(t, 0, stored.Speed)
+}
+
+func TestRebuildService_Start(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	channel := uuid.New()
+	for _, message := range flight(channel, 3, time.Now()) {
+		require.NoError(t, messageService.Ingest(ctx, message))
+	}
+	corrupt(t, rocketsRepository, channel)
+
+	rebuilds := NewRebuildService(messageRepository, rocketsRepository)
+	job, err := rebuilds.Start(RebuildOptions{Concurrency: 2})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		job, err = rebuilds.Job(job.ID)
+		return err == nil && !job.Running()
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, job.Error)
+	assert.Equal(t, 1, job.Report.Done)
+	assert.Equal(t, 1, job.Report.Changed)
+
+	stored, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, 520, stored.Speed)
+
+	_, err = rebuilds.Job(uuid.New())
+	assert.ErrorIs(t, err, ErrRebuildNotFound)
+
+	require.NoError(t, rebuilds.Shutdown(ctx))
+	_, err = rebuilds.Start(RebuildOptions{})
+	assert.ErrorIs(t, err, ErrShuttingDown)
+}
+
+func TestRebuildService_ReplaysMessagesStoredBeforeValidation(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	channel := uuid.New()
+	sent := time.Now()
+
+	// Stored by a version that didn't validate payloads: no mission, a fractional and a negative speed change
+	for _, message := range []Message{
+		{Metadata: Metadata{Channel: channel, MessageNumber: 1, MessageTime: sent, MessageType: RocketLaunched}, Message: map[string]interface{}{"type": "Falcon-9", "launchSpeed": float64(500)}},
+		{Metadata: Metadata{Channel: channel, MessageNumber: 2, MessageTime: sent.Add(time.Second), MessageType: RocketSpeedIncreased}, Message: map[string]interface{}{"by": 10.5}},
+		{Metadata: Metadata{Channel: channel, MessageNumber: 3, MessageTime: sent.Add(2 * time.Second), MessageType: RocketSpeedDecreased}, Message: map[string]interface{}{"by": float64(-20)}},
+	} {
+		require.Error(t, ValidateMessage(message))
+		require.NoError(t, messageRepository.Store(ctx, message))
+	}
+
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	require.NoError(t, messageService.Process(ctx, Message{Metadata: Metadata{Channel: channel}}))
+	rocket, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, 3, *rocket.LastMessageNumber)
+	assert.Equal(t, 530, rocket.Speed)
+	assert.Empty(t, rocket.Mission)
+
+	rebuilt, err := NewRebuildService(messageRepository, rocketsRepository).Rebuild(ctx, channel, true)
+	require.NoError(t, err)
+	assert.False(t, rebuilt.Changed())
+
+	timeseries, err := NewRocketsServiceImpl(rocketsRepository, messageRepository).Timeseries(ctx, channel, TimeseriesQuery{Field: "speed"})
+	require.NoError(t, err)
+	assert.Len(t, timeseries.Points, 3)
+
+	// The compacted log replays from a snapshot that holds the empty mission
+	retention := NewRetentionService(messageRepository, rocketsRepository, NewMemoryArchiveRepository(), RetentionPolicy{KeepMessages: 1})
+	_, err = retention.Run(ctx, false)
+	require.NoError(t, err)
+	compacted, err := messageRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	require.Equal(t, RocketSnapshot, compacted[0].Metadata.MessageType)
+	rebuilt, err = NewRebuildService(messageRepository, rocketsRepository).Rebuild(ctx, channel, true)
+	require.NoError(t, err)
+	assert.False(t, rebuilt.Changed())
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,10 +15,26 @@ import (
 )
 
 type MessageRepository interface {
+	// Store appends the message to the log. It returns ErrDuplicateMessage when the message was already stored, after
+	// counting the redelivery on the stored message, and ErrConflictingMessage when its channel and number were already
+	// used by a different message.
 	Store(ctx context.Context, message Message) error
 	FindByChannel(ctx context.Context, channel uuid.UUID) ([]Message, error)
 	FindAfterNumber(ctx context.Context, channel uuid.UUID, number int) ([]Message, error)
+	// FindAfterNumbers reads several channels at once, each after its own number. Messages are ordered by number
+	// within their channel.
+	FindAfterNumbers(ctx context.Context, after map[uuid.UUID]int) ([]Message, error)
+	// Channels returns every channel with at least one stored message.
+	Channels(ctx context.Context) ([]uuid.UUID, error)
+	// Compact stores the snapshot, a RocketSnapshot message, in place of the messages of its channel up to its number.
+	// It returns how many messages before that number were deleted.
+	Compact(ctx context.Context, snapshot Message) (int, error)
+	// DeleteChannel deletes the whole log of the channel and returns how many messages it had.
+	DeleteChannel(ctx context.Context, channel uuid.UUID) (int, error)
 }
+
+// MongoMessageRepository relies on the unique index on channel and message number created by the migrations, which
+// makes Store idempotent.
 type MongoMessageRepository struct {
 	collection *mongo.Collection
 }
@@ -28,74 +46,123 @@ func NewMongoMessageRepository(collection *mongo.Collection) *MongoMessageReposi
 }
 
 func (r MongoMessageRepository) Store(ctx context.Context, message Message) error {
-
-	doc := bson.M{
-		"metadata": bson.M{
-			"channel":       message.Metadata.Channel.String(),
-			"messageNumber": message.Metadata.MessageNumber,
-			"messageTime":   message.Metadata.MessageTime,
-			"messageType":   message.Metadata.MessageType,
-		},
-		"message":   message.Message,
-		"createdAt": time.Now(),
-	}
-
+	doc := messageDocument(message, time.Now())
 	if _, err := r.collection.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return r.duplicateError(ctx, message)
+		}
 		slog.Error("Error storing message", "error", err)
 		return errors.New(StoreMessageError)
 	}
 	return nil
 }
 
-func (r MongoMessageRepository) FindByChannel(ctx context.Context, channel uuid.UUID) ([]Message, error) {
-	filter := bson.M{"metadata.channel": channel.String()}
-	opts := options.Find().SetSort(bson.D{{Key: "metadata.messageNumber", Value: 1}})
-	cursor, err := r.collection.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, err
+// duplicateError tells apart a redelivery of the stored message from a different message reusing its number, and
+// counts the redelivery on the stored message.
+func (r MongoMessageRepository) duplicateError(ctx context.Context, message Message) error {
+	key := bson.M{
+		"metadata.channel":       message.Metadata.Channel.String(),
+		"metadata.messageNumber": message.Metadata.MessageNumber,
+	}
+	stored, err := r.find(ctx, key)
+	if err != nil || len(stored) == 0 {
+		slog.Error("Error reading duplicated message", "error", err)
+		return errors.New(StoreMessageError)
+	}
+	if err := duplicateOf(stored[0], message); !errors.Is(err, ErrDuplicateMessage) {
+		return err
 	}
-	defer cursor.Close(ctx)
 
-	// Decode into raw format with string channel
-	var rawMessages []struct {
-		Metadata struct {
-			Channel       string    `bson:"channel"`
-			MessageNumber int       `bson:"messageNumber"`
-			MessageTime   time.Time `bson:"messageTime"`
-			MessageType   string    `bson:"messageType"`
-		} `bson:"metadata"`
-		Message map[string]interface{} `bson:"message"`
+	update := bson.M{"$inc": bson.M{"redeliveries": 1}, "$set": bson.M{"redeliveredAt": time.Now()}}
+	if _, err := r.collection.UpdateOne(ctx, key, update); err != nil {
+		slog.Error("Error counting redelivered message", "error", err)
+		return errors.New(StoreMessageError)
 	}
-	if err := cursor.All(ctx, &rawMessages); err != nil {
+	return ErrDuplicateMessage
+}
+
+// duplicateOf returns the error of storing the message when the stored one already has its channel and number. A
+// snapshot took the place of a message that was received, so the message is a redelivery.
+func duplicateOf(stored Message, message Message) error {
+	if stored.Metadata.MessageType != RocketSnapshot && !stored.SameContent(message) {
+		return ErrConflictingMessage
+	}
+	return ErrDuplicateMessage
+}
+
+func (r MongoMessageRepository) FindByChannel(ctx context.Context, channel uuid.UUID) ([]Message, error) {
+	return r.find(ctx, bson.M{"metadata.channel": channel.String()})
+}
+
+func (r MongoMessageRepository) FindAfterNumber(ctx context.Context, channel uuid.UUID, number int) ([]Message, error) {
+	return r.find(ctx, bson.M{
+		"metadata.channel":       channel.String(),
+		"metadata.messageNumber": bson.M{"$gt": number},
+	})
+}
+
+func (r MongoMessageRepository) FindAfterNumbers(ctx context.Context, after map[uuid.UUID]int) ([]Message, error) {
+	if len(after) == 0 {
+		return []Message{}, nil
+	}
+	conditions := make(bson.A, 0, len(after))
+	for channel, number := range after {
+		conditions = append(conditions, bson.M{
+			"metadata.channel":       channel.String(),
+			"metadata.messageNumber": bson.M{"$gt": number},
+		})
+	}
+	return r.find(ctx, bson.M{"$or": conditions})
+}
+
+func (r MongoMessageRepository) Channels(ctx context.Context) ([]uuid.UUID, error) {
+	values, err := r.collection.Distinct(ctx, "metadata.channel", bson.M{})
+	if err != nil {
 		return nil, err
 	}
 
-	// Convert to domain model with UUID
-	messages := make([]Message, 0, len(rawMessages))
-	for _, raw := range rawMessages {
-		parsedUUID, err := uuid.Parse(raw.Metadata.Channel)
+	channels := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		raw, _ := value.(string)
+		channel, err := uuid.Parse(raw)
 		if err != nil {
 			return nil, err
 		}
-		messages = append(messages, Message{
-			Metadata: Metadata{
-				Channel:       parsedUUID,
-				MessageNumber: raw.Metadata.MessageNumber,
-				MessageTime:   raw.Metadata.MessageTime,
-				MessageType:   raw.Metadata.MessageType,
-			},
-			Message: raw.Message,
-		})
+		channels = append(channels, channel)
 	}
+	return channels, nil
+}
 
-	return messages, nil
+// Compact replaces the message at the number of the snapshot with the snapshot, then deletes the messages before it. A
+// compaction interrupted in between leaves the snapshot and older messages, which replays ignore.
+func (r MongoMessageRepository) Compact(ctx context.Context, snapshot Message) (int, error) {
+	channel := snapshot.Metadata.Channel.String()
+	number := snapshot.Metadata.MessageNumber
+	_, err := r.collection.ReplaceOne(ctx,
+		bson.M{"metadata.channel": channel, "metadata.messageNumber": number},
+		messageDocument(snapshot, snapshot.ReceivedAt),
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"metadata.channel": channel, "metadata.messageNumber": bson.M{"$lt": number}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
 }
 
-func (r MongoMessageRepository) FindAfterNumber(ctx context.Context, channel uuid.UUID, number int) ([]Message, error) {
-	filter := bson.M{
-		"metadata.channel":       channel.String(),
-		"metadata.messageNumber": bson.M{"$gt": number},
+func (r MongoMessageRepository) DeleteChannel(ctx context.Context, channel uuid.UUID) (int, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"metadata.channel": channel.String()})
+	if err != nil {
+		return 0, err
 	}
+	return int(result.DeletedCount), nil
+}
+
+func (r MongoMessageRepository) find(ctx context.Context, filter bson.M) ([]Message, error) {
 	opts := options.Find().SetSort(bson.D{{Key: "metadata.messageNumber", Value: 1}})
 	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
@@ -103,21 +170,41 @@ func (r MongoMessageRepository) FindAfterNumber(ctx context.Context, channel uui
 	}
 	defer cursor.Close(ctx)
 
-	// Decode into raw format with string channel
-	var rawMessages []struct {
-		Metadata struct {
-			Channel       string    `bson:"channel"`
-			MessageNumber int       `bson:"messageNumber"`
-			MessageTime   time.Time `bson:"messageTime"`
-			MessageType   string    `bson:"messageType"`
-		} `bson:"metadata"`
-		Message map[string]interface{} `bson:"message"`
-	}
+	var rawMessages []mongoMessage
 	if err := cursor.All(ctx, &rawMessages); err != nil {
 		return nil, err
 	}
+	return toMessages(rawMessages)
+}
+
+func messageDocument(message Message, createdAt time.Time) bson.M {
+	return bson.M{
+		"metadata": bson.M{
+			"channel":       message.Metadata.Channel.String(),
+			"messageNumber": message.Metadata.MessageNumber,
+			"messageTime":   message.Metadata.MessageTime,
+			"messageType":   message.Metadata.MessageType,
+		},
+		"message":   message.Message,
+		"createdAt": createdAt,
+	}
+}
+
+// mongoMessage is the raw format of a stored message, with a string channel.
+type mongoMessage struct {
+	Metadata struct {
+		Channel       string    `bson:"channel"`
+		MessageNumber int       `bson:"messageNumber"`
+		MessageTime   time.Time `bson:"messageTime"`
+		MessageType   string    `bson:"messageType"`
+	} `bson:"metadata"`
+	Message       map[string]interface{} `bson:"message"`
+	CreatedAt     time.Time              `bson:"createdAt"`
+	Redeliveries  int                    `bson:"redeliveries"`
+	RedeliveredAt time.Time              `bson:"redeliveredAt"`
+}
 
-	// Convert to domain model with UUID
+func toMessages(rawMessages []mongoMessage) ([]Message, error) {
 	messages := make([]Message, 0, len(rawMessages))
 	for _, raw := range rawMessages {
 		parsedUUID, err := uuid.Parse(raw.Metadata.Channel)
@@ -131,19 +218,32 @@ func (r MongoMessageRepository) FindAfterNumber(ctx context.Context, channel uui
 				MessageTime:   raw.Metadata.MessageTime,
 				MessageType:   raw.Metadata.MessageType,
 			},
-			Message: raw.Message,
+			Message:       raw.Message,
+			ReceivedAt:    raw.CreatedAt,
+			Redeliveries:  raw.Redeliveries,
+			RedeliveredAt: raw.RedeliveredAt,
 		})
 	}
-
 	return messages, nil
 }
 
 type RocketsRepository interface {
-	All(ctx context.Context, sortBy *string, order *string) ([]Rocket, error)
+	// All returns the page of the fleet the query asks for, and ErrInvalidCursor when its cursor can't be used.
+	All(ctx context.Context, query RocketQuery) (*RocketPage, error)
+	// Stats aggregates the rockets the query selects.
+	Stats(ctx context.Context, query StatsQuery) (*FleetStats, error)
+	// FindByChannel returns ErrRocketNotFound when the rocket has not been launched yet.
 	FindByChannel(ctx context.Context, channel uuid.UUID) (*Rocket, error)
+	// Upsert stores the rocket when the stored version is still rocket.Version, and moves the stored version to the next
+	// one. It returns ErrVersionConflict when another writer stored the rocket in between.
 	Upsert(ctx context.Context, rocket Rocket) error
+	// Delete removes the rocket when the stored version is still rocket.Version, and returns ErrVersionConflict
+	// otherwise.
+	Delete(ctx context.Context, rocket Rocket) error
 }
 
+// MongoRocketsRepository relies on the unique index on channel created by the migrations, which keeps two writers from
+// creating the same rocket.
 type MongoRocketsRepository struct {
 	collection *mongo.Collection
 }
@@ -154,84 +254,362 @@ func NewMongoRocketsRepository(collection *mongo.Collection) *MongoRocketsReposi
 	}
 }
 
-func (m MongoRocketsRepository) All(ctx context.Context, sortBy *string, order *string) ([]Rocket, error) {
-	// Build MongoDB sort options
-	findOptions := options.Find()
-	if sortBy != nil {
-		sortOrder := 1 // ascending by default
-		if order != nil && *order == "desc" {
+func (m MongoRocketsRepository) All(ctx context.Context, query RocketQuery) (*RocketPage, error) {
+	after, err := query.after()
+	if err != nil {
+		return nil, err
+	}
+	matching := filterRockets(query.Filter)
+	total, err := m.collection.CountDocuments(ctx, matching)
+	if err != nil {
+		return nil, err
+	}
+
+	// The keys only hold allowed fields, which are named as in the documents
+	keys := query.sortKeys()
+	sort := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		sortOrder := 1
+		if key.Descending {
 			sortOrder = -1
 		}
-		findOptions.SetSort(bson.D{{Key: *sortBy, Value: sortOrder}})
+		sort = append(sort, bson.E{Key: key.Field, Value: sortOrder})
+	}
+	findOptions := options.Find().SetSort(sort)
+	if query.Limit > 0 {
+		// One more than the limit tells whether there is a next page
+		findOptions.SetLimit(int64(query.Limit) + 1)
+	}
+	filter := matching
+	if after != nil {
+		filter = bson.M{"$and": bson.A{matching, afterCursor(keys, after)}}
 	}
 
-	cursor, err := m.collection.Find(ctx, bson.M{}, findOptions)
+	cursor, err := m.collection.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	// Decode into raw format with string channel
-	var rawRockets []struct {
-		Channel           string     `bson:"channel"`
-		Type              string     `bson:"type"`
-		Speed             int        `bson:"speed"`
-		Mission           string     `bson:"mission"`
-		Status            string     `bson:"status"`
-		ExplosionReason   *string    `bson:"explosionReason,omitempty"`
-		LastMessageNumber *int       `bson:"lastMessageNumber,omitempty"`
-		LastMessageTime   *time.Time `bson:"lastMessageTime,omitempty"`
-	}
+	var rawRockets []mongoRocket
 	if err := cursor.All(ctx, &rawRockets); err != nil {
 		return nil, err
 	}
 
-	// Convert to domain model with UUID
 	rockets := make([]Rocket, 0, len(rawRockets))
 	for _, raw := range rawRockets {
-		parsedUUID, err := uuid.Parse(raw.Channel)
+		rocket, err := raw.rocket()
 		if err != nil {
 			return nil, err
 		}
-		rockets = append(rockets, Rocket{
-			Channel:           parsedUUID,
-			Type:              raw.Type,
-			Speed:             raw.Speed,
-			Mission:           raw.Mission,
-			Status:            raw.Status,
-			ExplosionReason:   raw.ExplosionReason,
-			LastMessageNumber: raw.LastMessageNumber,
-			LastMessageTime:   raw.LastMessageTime,
-		})
+		rockets = append(rockets, rocket)
 	}
 
-	return rockets, nil
+	return query.page(rockets, int(total)), nil
 }
 
-func (m MongoRocketsRepository) FindByChannel(ctx context.Context, channel uuid.UUID) (*Rocket, error) {
-	// Decode into raw format with string channel
-	var raw struct {
-		Channel           string     `bson:"channel"`
-		Type              string     `bson:"type"`
-		Speed             int        `bson:"speed"`
-		Mission           string     `bson:"mission"`
-		Status            string     `bson:"status"`
-		ExplosionReason   *string    `bson:"explosionReason,omitempty"`
-		LastMessageNumber *int       `bson:"lastMessageNumber,omitempty"`
-		LastMessageTime   *time.Time `bson:"lastMessageTime,omitempty"`
+// filterRockets matches the rockets RocketFilter.matches keeps. Text is matched with anchored or case-insensitive
+// regular expressions of the quoted values.
+func filterRockets(filter RocketFilter) bson.M {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	mission := bson.M{}
+	if filter.Mission != "" {
+		mission["$eq"] = filter.Mission
+	}
+	if filter.MissionPrefix != "" {
+		mission["$regex"] = "^" + regexp.QuoteMeta(filter.MissionPrefix)
+	}
+	if len(mission) > 0 {
+		query["mission"] = mission
+	}
+	addRange(query, "speed", filter.MinSpeed, filter.MaxSpeed)
+	addRange(query, "lastMessageTime", filter.LastMessageFrom, filter.LastMessageTo)
+	addRange(query, "peakSpeed", filter.MinPeakSpeed, filter.MaxPeakSpeed)
+	addRange(query, "totalAcceleration", filter.MinTotalAcceleration, filter.MaxTotalAcceleration)
+	addRange(query, "missionChanges", filter.MinMissionChanges, filter.MaxMissionChanges)
+	addRange(query, "launchTime", filter.LaunchedFrom, filter.LaunchedTo)
+	addRange(query, "flightDuration", filter.MinFlightDuration, filter.MaxFlightDuration)
+	addRange(query, "distance", filter.MinDistance, filter.MaxDistance)
+	if filter.Search != "" {
+		search := bson.M{"$regex": regexp.QuoteMeta(filter.Search), "$options": "i"}
+		query["$or"] = bson.A{
+			bson.M{"channel": search},
+			bson.M{"type": search},
+			bson.M{"mission": search},
+			bson.M{"explosionReason": search},
+		}
+	}
+	return query
+}
+
+// addRange matches the field between both ends, included, when either is set.
+func addRange[T any](query bson.M, field string, from, to *T) {
+	bounds := bson.M{}
+	if from != nil {
+		bounds["$gte"] = *from
+	}
+	if to != nil {
+		bounds["$lte"] = *to
+	}
+	if len(bounds) > 0 {
+		query[field] = bounds
+	}
+}
+
+// afterCursor matches the rockets ordered after the sort values of the cursor: level with it on the first keys and
+// further on the next one. Missing values sort first in mongo, like nil ones in compareSortValues.
+func afterCursor(keys []SortKey, after []any) bson.M {
+	var following bson.A
+	level := bson.A{}
+	for i, key := range keys {
+		if further := furtherThan(key, after[i]); further != nil {
+			following = append(following, bson.M{"$and": append(slices.Clone(level), further)})
+		}
+		// A nil value matches both null and missing fields
+		level = append(level, bson.M{key.Field: after[i]})
+	}
+	return bson.M{"$or": following}
+}
+
+// furtherThan matches the values of the key ordered after the value, nil when none is.
+func furtherThan(key SortKey, value any) bson.M {
+	switch {
+	case value == nil && key.Descending:
+		return nil
+	case value == nil:
+		return bson.M{key.Field: bson.M{"$ne": nil}}
+	case key.Descending:
+		return bson.M{"$or": bson.A{bson.M{key.Field: bson.M{"$lt": value}}, bson.M{key.Field: nil}}}
+	default:
+		return bson.M{key.Field: bson.M{"$gt": value}}
+	}
+}
+
+// mongoStatsGroup is a group of the stats pipeline, the counts only fill the key and the count.
+type mongoStatsGroup struct {
+	Key          string  `bson:"_id"`
+	Count        int     `bson:"count"`
+	Active       int     `bson:"active"`
+	AverageSpeed float64 `bson:"averageSpeed"`
+	MinSpeed     int     `bson:"minSpeed"`
+	MaxSpeed     int     `bson:"maxSpeed"`
+}
+
+// Stats runs every aggregation in one pipeline, as facets over the rockets matched once.
+func (m MongoRocketsRepository) Stats(ctx context.Context, query StatsQuery) (*FleetStats, error) {
+	// A missing time is lower than any date, so rockets that never applied a message are not active
+	active := bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$lastMessageTime", query.ActiveSince}}, 1, 0}}
+	countBy := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		}
+	}
+	// A nil key puts every rocket in one group
+	groupBy := func(key any) bson.A {
+		return bson.A{
+			bson.M{"$group": bson.M{
+				"_id":          key,
+				"count":        bson.M{"$sum": 1},
+				"active":       bson.M{"$sum": active},
+				"averageSpeed": bson.M{"$avg": "$speed"},
+				"minSpeed":     bson.M{"$min": "$speed"},
+				"maxSpeed":     bson.M{"$max": "$speed"},
+			}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		}
+	}
+
+	facets := bson.M{
+		"totals":      groupBy(nil),
+		"byStatus":    countBy("status"),
+		"byType":      countBy("type"),
+		"byMission":   countBy("mission"),
+		"speedByType": groupBy("$type"),
+		"explosions":  append(bson.A{bson.M{"$match": bson.M{"status": "exploded"}}}, countBy("explosionReason")...),
+	}
+	// The field was checked against StatsGroupFields, which are named as in the documents
+	if slices.Contains(StatsGroupFields, query.GroupBy) {
+		facets["groups"] = groupBy("$" + query.GroupBy)
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filterRockets(RocketFilter{LastMessageFrom: query.From, LastMessageTo: query.To})}},
+		{{Key: "$facet", Value: facets}},
+	}
+
+	cursor, err := m.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// A facet stage always returns one document, even when no rocket matched
+	var result struct {
+		Totals      []mongoStatsGroup `bson:"totals"`
+		ByStatus    []mongoStatsGroup `bson:"byStatus"`
+		ByType      []mongoStatsGroup `bson:"byType"`
+		ByMission   []mongoStatsGroup `bson:"byMission"`
+		SpeedByType []mongoStatsGroup `bson:"speedByType"`
+		Explosions  []mongoStatsGroup `bson:"explosions"`
+		Groups      []mongoStatsGroup `bson:"groups"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
 	}
+
+	stats := &FleetStats{
+		ByStatus:    statsCounts(result.ByStatus),
+		ByType:      statsCounts(result.ByType),
+		ByMission:   statsCounts(result.ByMission),
+		SpeedByType: statsGroups(result.SpeedByType),
+		Explosions:  statsCounts(result.Explosions),
+	}
+	if len(result.Totals) > 0 {
+		stats.Total, stats.Active = result.Totals[0].Count, result.Totals[0].Active
+	}
+	if query.GroupBy != "" {
+		stats.Groups = statsGroups(result.Groups)
+	}
+	return stats, nil
+}
+
+func statsCounts(groups []mongoStatsGroup) []StatsCount {
+	counts := make([]StatsCount, 0, len(groups))
+	for _, group := range groups {
+		counts = append(counts, StatsCount{Key: group.Key, Count: group.Count})
+	}
+	return counts
+}
+
+func statsGroups(groups []mongoStatsGroup) []StatsGroup {
+	result := make([]StatsGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, StatsGroup(group))
+	}
+	return result
+}
+
+func (m MongoRocketsRepository) FindByChannel(ctx context.Context, channel uuid.UUID) (*Rocket, error) {
+	var raw mongoRocket
 	err := m.collection.FindOne(ctx, bson.M{"channel": channel.String()}).Decode(&raw)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRocketNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to domain model with UUID
-	parsedUUID, err := uuid.Parse(raw.Channel)
+	rocket, err := raw.rocket()
 	if err != nil {
 		return nil, err
 	}
+	return &rocket, nil
+}
+
+func (m MongoRocketsRepository) Upsert(ctx context.Context, rocket Rocket) error {
+	document := rocketDocument(rocket)
+	document["version"] = rocket.Version + 1
+	update := bson.M{"$set": document}
 
-	rocket := &Rocket{
+	if rocket.Version == 0 {
+		// A new rocket is inserted, the unique index on the channel turns a concurrent insert into a duplicate key.
+		// Rockets stored before versioning have no version and are taken as version 0.
+		filter := bson.M{"channel": rocket.Channel.String(), "version": bson.M{"$in": bson.A{0, nil}}}
+		_, err := m.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrVersionConflict
+		}
+		if err != nil {
+			slog.Error("Error updating rocket", "error", err)
+			return errors.New(UpdateRocketError)
+		}
+		return nil
+	}
+
+	filter := bson.M{"channel": rocket.Channel.String(), "version": rocket.Version}
+	result, err := m.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		slog.Error("Error updating rocket", "error", err)
+		return errors.New(UpdateRocketError)
+	}
+	if result.MatchedCount == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (m MongoRocketsRepository) Delete(ctx context.Context, rocket Rocket) error {
+	result, err := m.collection.DeleteOne(ctx, bson.M{"channel": rocket.Channel.String(), "version": rocket.Version})
+	if err != nil {
+		slog.Error("Error deleting rocket", "error", err)
+		return errors.New(UpdateRocketError)
+	}
+	if result.DeletedCount == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func rocketDocument(rocket Rocket) bson.M {
+	return bson.M{
+		"channel":           rocket.Channel.String(),
+		"type":              rocket.Type,
+		"speed":             rocket.Speed,
+		"mission":           rocket.Mission,
+		"status":            rocket.Status,
+		"explosionReason":   rocket.ExplosionReason,
+		"lastMessageNumber": rocket.LastMessageNumber,
+		"lastMessageTime":   rocket.LastMessageTime,
+		"peakSpeed":         rocket.PeakSpeed,
+		"totalAcceleration": rocket.TotalAcceleration,
+		"missionChanges":    rocket.MissionChanges,
+		"launchTime":        rocket.LaunchTime,
+		"flightDuration":    rocket.FlightDuration,
+		"distance":          rocket.Distance,
+		"pendingMessages":   rocket.PendingMessages,
+		"skippedMessages":   rocket.SkippedMessages,
+		"version":           rocket.Version,
+	}
+}
+
+// mongoRocket is the raw format of a stored rocket, with a string channel.
+type mongoRocket struct {
+	Channel           string         `bson:"channel"`
+	Type              string         `bson:"type"`
+	Speed             int            `bson:"speed"`
+	Mission           string         `bson:"mission"`
+	Status            string         `bson:"status"`
+	ExplosionReason   *string        `bson:"explosionReason,omitempty"`
+	LastMessageNumber *int           `bson:"lastMessageNumber,omitempty"`
+	LastMessageTime   *time.Time     `bson:"lastMessageTime,omitempty"`
+	PeakSpeed         int            `bson:"peakSpeed"`
+	TotalAcceleration int            `bson:"totalAcceleration"`
+	MissionChanges    int            `bson:"missionChanges"`
+	LaunchTime        *time.Time     `bson:"launchTime,omitempty"`
+	FlightDuration    float64        `bson:"flightDuration"`
+	Distance          float64        `bson:"distance"`
+	PendingMessages   int            `bson:"pendingMessages"`
+	SkippedMessages   []SkippedRange `bson:"skippedMessages,omitempty"`
+	Version           int            `bson:"version"`
+}
+
+func (raw mongoRocket) rocket() (Rocket, error) {
+	parsedUUID, err := uuid.Parse(raw.Channel)
+	if err != nil {
+		return Rocket{}, err
+	}
+	return Rocket{
 		Channel:           parsedUUID,
 		Type:              raw.Type,
 		Speed:             raw.Speed,
@@ -240,30 +618,318 @@ func (m MongoRocketsRepository) FindByChannel(ctx context.Context, channel uuid.
 		ExplosionReason:   raw.ExplosionReason,
 		LastMessageNumber: raw.LastMessageNumber,
 		LastMessageTime:   raw.LastMessageTime,
+		PeakSpeed:         raw.PeakSpeed,
+		TotalAcceleration: raw.TotalAcceleration,
+		MissionChanges:    raw.MissionChanges,
+		LaunchTime:        raw.LaunchTime,
+		FlightDuration:    raw.FlightDuration,
+		Distance:          raw.Distance,
+		PendingMessages:   raw.PendingMessages,
+		SkippedMessages:   raw.SkippedMessages,
+		Version:           raw.Version,
+	}, nil
+}
+
+// ArchivedRocket is a rocket moved out of the live collections, with the log it had left.
+type ArchivedRocket struct {
+	Rocket     Rocket
+	Messages   []Message
+	ArchivedAt time.Time
+}
+
+type ArchiveRepository interface {
+	// Store saves the archived rocket, replacing an earlier archive of its channel.
+	Store(ctx context.Context, archived ArchivedRocket) error
+	// FindByChannel returns ErrRocketNotFound when the channel was never archived.
+	FindByChannel(ctx context.Context, channel uuid.UUID) (*ArchivedRocket, error)
+}
+
+// MongoArchiveRepository keeps a document per archived rocket, holding its log. Logs are compacted before archiving
+// so the documents stay small.
+type MongoArchiveRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoArchiveRepository(collection *mongo.Collection) *MongoArchiveRepository {
+	return &MongoArchiveRepository{
+		collection: collection,
 	}
-	return rocket, nil
 }
 
-func (m MongoRocketsRepository) Upsert(ctx context.Context, rocket Rocket) error {
-	filter := bson.M{"channel": rocket.Channel.String()}
-	update := bson.M{
-		"$set": bson.M{
-			"channel":           rocket.Channel.String(),
-			"type":              rocket.Type,
-			"speed":             rocket.Speed,
-			"mission":           rocket.Mission,
-			"status":            rocket.Status,
-			"explosionReason":   rocket.ExplosionReason,
-			"lastMessageNumber": rocket.LastMessageNumber,
-			"lastMessageTime":   rocket.LastMessageTime,
+func (a MongoArchiveRepository) Store(ctx context.Context, archived ArchivedRocket) error {
+	messages := make([]bson.M, 0, len(archived.Messages))
+	for _, message := range archived.Messages {
+		messages = append(messages, messageDocument(message, message.ReceivedAt))
+	}
+	doc := bson.M{
+		"channel":    archived.Rocket.Channel.String(),
+		"rocket":     rocketDocument(archived.Rocket),
+		"messages":   messages,
+		"archivedAt": archived.ArchivedAt,
+	}
+
+	filter := bson.M{"channel": archived.Rocket.Channel.String()}
+	if _, err := a.collection.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true)); err != nil {
+		slog.Error("Error archiving rocket", "error", err)
+		return errors.New(UpdateRocketError)
+	}
+	return nil
+}
+
+func (a MongoArchiveRepository) FindByChannel(ctx context.Context, channel uuid.UUID) (*ArchivedRocket, error) {
+	var raw struct {
+		Rocket     mongoRocket    `bson:"rocket"`
+		Messages   []mongoMessage `bson:"messages"`
+		ArchivedAt time.Time      `bson:"archivedAt"`
+	}
+	err := a.collection.FindOne(ctx, bson.M{"channel": channel.String()}).Decode(&raw)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRocketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rocket, err := raw.Rocket.rocket()
+	if err != nil {
+		return nil, err
+	}
+	messages, err := toMessages(raw.Messages)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchivedRocket{Rocket: rocket, Messages: messages, ArchivedAt: raw.ArchivedAt}, nil
+}
+
+type WebhookRepository interface {
+	Store(ctx context.Context, webhook Webhook) error
+	// All returns the webhooks in the order they were subscribed.
+	All(ctx context.Context) ([]Webhook, error)
+	// FindByID returns ErrWebhookNotFound when there is no such webhook.
+	FindByID(ctx context.Context, id uuid.UUID) (*Webhook, error)
+	// Delete returns ErrWebhookNotFound when there is no such webhook.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// DeadLetterRepository keeps the webhook deliveries that failed every attempt.
+type DeadLetterRepository interface {
+	// Store saves the delivery, replacing the previous failure of the same delivery.
+	Store(ctx context.Context, delivery WebhookDelivery) error
+	// All returns the deliveries, the oldest failure first.
+	All(ctx context.Context) ([]WebhookDelivery, error)
+	// FindByID returns ErrDeliveryNotFound when the delivery is not a dead letter.
+	FindByID(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error)
+	// Delete returns ErrDeliveryNotFound when the delivery is not a dead letter.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// MongoWebhookRepository keeps a document per webhook, identified by the string form of its ID.
+type MongoWebhookRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoWebhookRepository(collection *mongo.Collection) *MongoWebhookRepository {
+	return &MongoWebhookRepository{
+		collection: collection,
+	}
+}
+
+type mongoWebhook struct {
+	ID        string    `bson:"id"`
+	URL       string    `bson:"url"`
+	Secret    string    `bson:"secret"`
+	Events    []string  `bson:"events"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+func (w MongoWebhookRepository) Store(ctx context.Context, webhook Webhook) error {
+	doc := mongoWebhook{ID: webhook.ID.String(), URL: webhook.URL, Secret: webhook.Secret, Events: webhook.Events, CreatedAt: webhook.CreatedAt}
+	if _, err := w.collection.ReplaceOne(ctx, bson.M{"id": doc.ID}, doc, options.Replace().SetUpsert(true)); err != nil {
+		slog.Error("Error storing webhook", "error", err)
+		return errors.New(StoreWebhookError)
+	}
+	return nil
+}
+
+func (w MongoWebhookRepository) All(ctx context.Context) ([]Webhook, error) {
+	cursor, err := w.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var raw []mongoWebhook
+	if err := cursor.All(ctx, &raw); err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]Webhook, 0, len(raw))
+	for _, doc := range raw {
+		webhook, err := doc.webhook()
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func (w MongoWebhookRepository) FindByID(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	var raw mongoWebhook
+	err := w.collection.FindOne(ctx, bson.M{"id": id.String()}).Decode(&raw)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := raw.webhook()
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (w MongoWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := w.collection.DeleteOne(ctx, bson.M{"id": id.String()})
+	if err != nil {
+		slog.Error("Error deleting webhook", "error", err)
+		return errors.New(StoreWebhookError)
+	}
+	if result.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (raw mongoWebhook) webhook() (Webhook, error) {
+	id, err := uuid.Parse(raw.ID)
+	if err != nil {
+		return Webhook{}, err
+	}
+	return Webhook{ID: id, URL: raw.URL, Secret: raw.Secret, Events: raw.Events, CreatedAt: raw.CreatedAt}, nil
+}
+
+// MongoDeadLetterRepository keeps a document per failed delivery, holding its event and the rocket it carries.
+type MongoDeadLetterRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDeadLetterRepository(collection *mongo.Collection) *MongoDeadLetterRepository {
+	return &MongoDeadLetterRepository{
+		collection: collection,
+	}
+}
+
+type mongoDelivery struct {
+	ID        string    `bson:"id"`
+	WebhookID string    `bson:"webhookId"`
+	Attempts  int       `bson:"attempts"`
+	Error     string    `bson:"error"`
+	FailedAt  time.Time `bson:"failedAt"`
+	Event     struct {
+		ID              string      `bson:"id"`
+		Type            string      `bson:"type"`
+		OccurredAt      time.Time   `bson:"occurredAt"`
+		Rocket          mongoRocket `bson:"rocket"`
+		PreviousMission string      `bson:"previousMission,omitempty"`
+	} `bson:"event"`
+}
+
+func (d MongoDeadLetterRepository) Store(ctx context.Context, delivery WebhookDelivery) error {
+	doc := bson.M{
+		"id":        delivery.ID.String(),
+		"webhookId": delivery.WebhookID.String(),
+		"attempts":  delivery.Attempts,
+		"error":     delivery.Error,
+		"failedAt":  delivery.FailedAt,
+		"event": bson.M{
+			"id":              delivery.Event.ID.String(),
+			"type":            delivery.Event.Type,
+			"occurredAt":      delivery.Event.OccurredAt,
+			"rocket":          rocketDocument(delivery.Event.Rocket),
+			"previousMission": delivery.Event.PreviousMission,
 		},
 	}
+	if _, err := d.collection.ReplaceOne(ctx, bson.M{"id": delivery.ID.String()}, doc, options.Replace().SetUpsert(true)); err != nil {
+		slog.Error("Error storing webhook delivery", "error", err)
+		return errors.New(StoreWebhookError)
+	}
+	return nil
+}
 
-	opts := options.Update().SetUpsert(true)
-	_, err := m.collection.UpdateOne(ctx, filter, update, opts)
+func (d MongoDeadLetterRepository) All(ctx context.Context) ([]WebhookDelivery, error) {
+	cursor, err := d.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "failedAt", Value: 1}}))
 	if err != nil {
-		slog.Error("Error updating rocket", "error", err)
-		return errors.New(UpdateRocketError)
+		return nil, err
+	}
+	var raw []mongoDelivery
+	if err := cursor.All(ctx, &raw); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(raw))
+	for _, doc := range raw {
+		delivery, err := doc.delivery()
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+func (d MongoDeadLetterRepository) FindByID(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error) {
+	var raw mongoDelivery
+	err := d.collection.FindOne(ctx, bson.M{"id": id.String()}).Decode(&raw)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := raw.delivery()
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (d MongoDeadLetterRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := d.collection.DeleteOne(ctx, bson.M{"id": id.String()})
+	if err != nil {
+		slog.Error("Error deleting webhook delivery", "error", err)
+		return errors.New(StoreWebhookError)
+	}
+	if result.DeletedCount == 0 {
+		return ErrDeliveryNotFound
 	}
 	return nil
 }
+
+func (raw mongoDelivery) delivery() (WebhookDelivery, error) {
+	var ids [3]uuid.UUID
+	for i, id := range []string{raw.ID, raw.WebhookID, raw.Event.ID} {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return WebhookDelivery{}, err
+		}
+		ids[i] = parsed
+	}
+	rocket, err := raw.Event.Rocket.rocket()
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	return WebhookDelivery{
+		ID:        ids[0],
+		WebhookID: ids[1],
+		Attempts:  raw.Attempts,
+		Error:     raw.Error,
+		FailedAt:  raw.FailedAt,
+		Event: LifecycleEvent{
+			ID:              ids[2],
+			Type:            raw.Event.Type,
+			OccurredAt:      raw.Event.OccurredAt,
+			Rocket:          rocket,
+			PreviousMission: raw.Event.PreviousMission,
+		},
+	}, nil
+}
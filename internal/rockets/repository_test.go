@@ -0,0 +1,677 @@
+package rockets
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adrianrios/lunar-test/internal/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// repositories returns empty repositories of one backend.
+type repositories func(t *testing.T) (MessageRepository, RocketsRepository, ArchiveRepository)
+
+func TestMemoryRepositories(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) (MessageRepository, RocketsRepository, ArchiveRepository) {
+		return NewMemoryMessageRepository(), NewMemoryRocketsRepository(), NewMemoryArchiveRepository()
+	})
+}
+
+func TestBoltRepositories(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) (MessageRepository, RocketsRepository, ArchiveRepository) {
+		return openBoltRepositories(t, filepath.Join(t.TempDir(), "rockets.db"))
+	})
+}
+
+func TestBoltRepositories_SurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rockets.db")
+	channel := uuid.New()
+	message := Message{
+		Metadata: Metadata{Channel: channel, MessageNumber: 1, MessageTime: time.Now(), MessageType: RocketLaunched},
+		Message:  map[string]interface{}{"type": "Falcon-9", "launchSpeed": float64(500), "mission": "ARTEMIS"},
+	}
+
+	db, err := OpenBolt(path)
+	require.NoError(t, err)
+	messageRepository, rocketsRepository := NewBoltMessageRepository(db), NewBoltRocketsRepository(db)
+	require.NoError(t, messageRepository.EnsureBuckets(ctx))
+	require.NoError(t, rocketsRepository.EnsureBuckets(ctx))
+	require.NoError(t, NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{}).Ingest(ctx, message))
+	require.NoError(t, db.Close())
+
+	messageRepository2, rocketsRepository2, _ := openBoltRepositories(t, path)
+	stored, err := messageRepository2.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.True(t, stored[0].SameContent(message))
+	assert.ErrorIs(t, messageRepository2.Store(ctx, message), ErrDuplicateMessage)
+
+	rocket, err := rocketsRepository2.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, 500, rocket.Speed)
+	assert.Equal(t, 1, rocket.Version)
+}
+
+func TestMongoRepositories(t *testing.T) {
+	client := setupMongoDB(t)
+	testRepositoryContract(t, func(t *testing.T) (MessageRepository, RocketsRepository, ArchiveRepository) {
+		db := client.Database("rockets_" + uuid.NewString()[:8])
+		require.NoError(t, migrations.Migrate(context.Background(), db))
+		return NewMongoMessageRepository(db.Collection("messages")), NewMongoRocketsRepository(db.Collection("rockets")),
+			NewMongoArchiveRepository(db.Collection("archive"))
+	})
+}
+
+// testRepositoryContract checks the behaviour every backend must share, the services rely on it.
+func testRepositoryContract(t *testing.T, setup repositories) {
+	ctx := context.Background()
+	sent := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	message := func(channel uuid.UUID, number int) Message {
+		return Message{
+			Metadata: Metadata{
+				Channel:       channel,
+				MessageNumber: number,
+				MessageTime:   sent.Add(time.Duration(number) * time.Second),
+				MessageType:   RocketLaunched,
+			},
+			Message: map[string]interface{}{"type": "Falcon-9", "mission": "ARTEMIS"},
+		}
+	}
+
+	t.Run("messages are returned by number", func(t *testing.T) {
+		messageRepository, _, _ := setup(t)
+		channel := uuid.New()
+		for _, number := range []int{3, 1, 2} {
+			require.NoError(t, messageRepository.Store(ctx, message(channel, number)))
+		}
+		require.NoError(t, messageRepository.Store(ctx, message(uuid.New(), 1)))
+
+		stored, err := messageRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+		require.Len(t, stored, 3)
+		for i, storedMessage := range stored {
+			assert.True(t, storedMessage.SameContent(message(channel, i+1)))
+			assert.False(t, storedMessage.ReceivedAt.IsZero())
+		}
+
+		after, err := messageRepository.FindAfterNumber(ctx, channel, 1)
+		require.NoError(t, err)
+		require.Len(t, after, 2)
+		assert.Equal(t, 2, after[0].Metadata.MessageNumber)
+		assert.Equal(t, 3, after[1].Metadata.MessageNumber)
+
+		other := uuid.New()
+		require.NoError(t, messageRepository.Store(ctx, message(other, 1)))
+		require.NoError(t, messageRepository.Store(ctx, message(other, 2)))
+		after, err = messageRepository.FindAfterNumbers(ctx, map[uuid.UUID]int{channel: 2, other: 0, uuid.New(): 0})
+		require.NoError(t, err)
+		require.Len(t, after, 3)
+		_, groups := groupByChannel(after)
+		require.Len(t, groups[other], 2)
+		assert.Equal(t, 1, after[groups[other][0]].Metadata.MessageNumber)
+		assert.Equal(t, 2, after[groups[other][1]].Metadata.MessageNumber)
+		require.Len(t, groups[channel], 1)
+		assert.Equal(t, 3, after[groups[channel][0]].Metadata.MessageNumber)
+
+		unknown, err := messageRepository.FindByChannel(ctx, uuid.New())
+		require.NoError(t, err)
+		assert.Empty(t, unknown)
+	})
+
+	t.Run("a number is stored once", func(t *testing.T) {
+		messageRepository, _, _ := setup(t)
+		channel := uuid.New()
+		require.NoError(t, messageRepository.Store(ctx, message(channel, 1)))
+
+		assert.ErrorIs(t, messageRepository.Store(ctx, message(channel, 1)), ErrDuplicateMessage)
+		conflicting := message(channel, 1)
+		conflicting.Message = map[string]interface{}{"type": "Saturn-V", "mission": "APOLLO"}
+		assert.ErrorIs(t, messageRepository.Store(ctx, conflicting), ErrConflictingMessage)
+
+		// Only the redelivery is counted, not the conflict
+		stored, err := messageRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+		require.Len(t, stored, 1)
+		assert.True(t, stored[0].SameContent(message(channel, 1)))
+		assert.Equal(t, 1, stored[0].Redeliveries)
+		assert.WithinDuration(t, time.Now(), stored[0].RedeliveredAt, time.Minute)
+	})
+
+	t.Run("channels with messages", func(t *testing.T) {
+		messageRepository, _, _ := setup(t)
+		first, second := uuid.New(), uuid.New()
+		for number := 1; number <= 3; number++ {
+			require.NoError(t, messageRepository.Store(ctx, message(first, number)))
+		}
+		require.NoError(t, messageRepository.Store(ctx, message(second, 1)))
+
+		channels, err := messageRepository.Channels(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []uuid.UUID{first, second}, channels)
+	})
+
+	t.Run("rockets are written with versions", func(t *testing.T) {
+		_, rocketsRepository, _ := setup(t)
+		channel := uuid.New()
+		_, err := rocketsRepository.FindByChannel(ctx, channel)
+		assert.ErrorIs(t, err, ErrRocketNotFound)
+
+		rocket := *newRocket(channel, 0)
+		lastMessageTime := sent
+		launchTime := sent.Add(-time.Minute + 1234567*time.Nanosecond).In(time.FixedZone("CET", 3600))
+		*rocket.LastMessageNumber = 1
+		rocket.LastMessageTime = &lastMessageTime
+		rocket.LaunchTime = &launchTime
+		rocket.Type, rocket.Mission, rocket.Status = "Falcon-9", "ARTEMIS", "launched"
+		rocket.PendingMessages = 2
+		rocket.SkippedMessages = []SkippedRange{{From: 2, To: 3}}
+		require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+		assert.ErrorIs(t, rocketsRepository.Upsert(ctx, rocket), ErrVersionConflict)
+		launchTime = launchTime.Add(time.Hour)
+
+		stored, err := rocketsRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stored.Version)
+		assert.Equal(t, 1, *stored.LastMessageNumber)
+		assert.True(t, sent.Equal(*stored.LastMessageTime))
+		// Every backend keeps the launch time to the millisecond in UTC, and not the caller's copy
+		assert.Equal(t, sent.Add(-time.Minute+time.Millisecond), *stored.LaunchTime)
+		assert.Equal(t, "ARTEMIS", stored.Mission)
+		assert.Equal(t, 2, stored.PendingMessages)
+		assert.Equal(t, []SkippedRange{{From: 2, To: 3}}, stored.SkippedMessages)
+
+		stored.Speed = 100
+		require.NoError(t, rocketsRepository.Upsert(ctx, *stored))
+		assert.ErrorIs(t, rocketsRepository.Upsert(ctx, *stored), ErrVersionConflict)
+
+		updated, err := rocketsRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+		assert.Equal(t, 2, updated.Version)
+		assert.Equal(t, 100, updated.Speed)
+	})
+
+	t.Run("all rockets sorted", func(t *testing.T) {
+		_, rocketsRepository, _ := setup(t)
+		for _, speed := range []int{200, 100, 300} {
+			rocket := *newRocket(uuid.New(), 0)
+			rocket.Speed = speed
+			require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+		}
+
+		speeds := func(sortBy string, descending bool) []int {
+			sort, err := ParseRocketSort(sortBy, descending)
+			require.NoError(t, err)
+			page, err := rocketsRepository.All(ctx, RocketQuery{Sort: sort})
+			require.NoError(t, err)
+			assert.Equal(t, 3, page.Total)
+			assert.Empty(t, page.NextCursor)
+			result := make([]int, 0, len(page.Rockets))
+			for _, rocket := range page.Rockets {
+				result = append(result, rocket.Speed)
+			}
+			return result
+		}
+		assert.ElementsMatch(t, []int{100, 200, 300}, speeds("", false))
+		assert.Equal(t, []int{100, 200, 300}, speeds("speed", false))
+		assert.Equal(t, []int{300, 200, 100}, speeds("speed", true))
+		assert.Equal(t, []int{300, 200, 100}, speeds("-speed", false))
+		assert.Equal(t, []int{100, 200, 300}, speeds("-speed", true))
+	})
+
+	t.Run("all rockets sorted by several keys", func(t *testing.T) {
+		_, rocketsRepository, _ := setup(t)
+		for i, fields := range []struct {
+			status string
+			speed  int
+			hours  int
+		}{
+			{"active", 100, 1},
+			{"active", 100, 2},
+			{"active", 200, 0},
+			{"exploded", 300, 1},
+			{"exploded", 300, 1},
+			{"active", 100, 0},
+		} {
+			rocket := *newRocket(uuid.New(), 0)
+			rocket.Status, rocket.Speed, rocket.Mission = fields.status, fields.speed, strconv.Itoa(i)
+			// Rockets that never applied a message have no time and sort first
+			if fields.hours > 0 {
+				lastMessageTime := sent.Add(time.Duration(fields.hours) * time.Hour)
+				rocket.LastMessageTime = &lastMessageTime
+			}
+			require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+		}
+
+		sort, err := ParseRocketSort("status,-speed,lastMessageTime", false)
+		require.NoError(t, err)
+		page, err := rocketsRepository.All(ctx, RocketQuery{Sort: sort})
+		require.NoError(t, err)
+		missions := func(rockets []Rocket) []string {
+			result := make([]string, 0, len(rockets))
+			for _, rocket := range rockets {
+				result = append(result, rocket.Mission)
+			}
+			return result
+		}
+		sorted := missions(page.Rockets)
+		assert.Equal(t, []string{"2", "5", "0", "1"}, sorted[:4])
+		assert.ElementsMatch(t, []string{"3", "4"}, sorted[4:])
+
+		// Paging one rocket at a time goes through the same order, nil values included
+		query := RocketQuery{Sort: sort, Limit: 1}
+		var paged []Rocket
+		for len(paged) <= 6 {
+			page, err := rocketsRepository.All(ctx, query)
+			require.NoError(t, err)
+			paged = append(paged, page.Rockets...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, sorted, missions(paged))
+
+		reversed, err := ParseRocketSort("status,-speed,lastMessageTime", true)
+		require.NoError(t, err)
+		query = RocketQuery{Sort: reversed, Limit: 1}
+		paged = nil
+		for len(paged) <= 6 {
+			page, err := rocketsRepository.All(ctx, query)
+			require.NoError(t, err)
+			paged = append(paged, page.Rockets...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"1", "0", "5", "2"}, missions(paged)[2:])
+	})
+
+	t.Run("all rockets paged", func(t *testing.T) {
+		_, rocketsRepository, _ := setup(t)
+		// Ties on the sort field are ordered by channel, in the same direction
+		for _, speed := range []int{200, 100, 200, 300, 200} {
+			rocket := *newRocket(uuid.New(), 0)
+			rocket.Speed = speed
+			require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+		}
+
+		sort, err := ParseRocketSort("speed", true)
+		require.NoError(t, err)
+		query := RocketQuery{Sort: sort, Limit: 2}
+		var visited []Rocket
+		var totals []int
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 3)
+			page, err := rocketsRepository.All(ctx, query)
+			require.NoError(t, err)
+			totals = append(totals, page.Total)
+			visited = append(visited, page.Rockets...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+
+			if pages == 0 {
+				// A rocket launched before the cursor doesn't move the rockets after it
+				rocket := *newRocket(uuid.New(), 0)
+				rocket.Speed = 400
+				require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+			}
+		}
+
+		assert.Equal(t, []int{5, 6, 6}, totals)
+		require.Len(t, visited, 5)
+		for i := 0; i < 4; i++ {
+			previous, next := visited[i], visited[i+1]
+			assert.True(t, previous.Speed > next.Speed || previous.Speed == next.Speed && previous.Channel.String() > next.Channel.String())
+		}
+
+		_, err = rocketsRepository.All(ctx, RocketQuery{Cursor: query.Cursor})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		_, err = rocketsRepository.All(ctx, RocketQuery{Sort: sort, Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("all rockets filtered", func(t *testing.T) {
+		_, rocketsRepository, _ := setup(t)
+		reason := "PRESSURE_VESSEL_FAILURE"
+		for i, fields := range []struct {
+			rocketType, mission, status string
+			speed                       int
+		}{
+			{"Falcon-9", "ARTEMIS", "active", 100},
+			{"Falcon-9", "ARTEMIS-2", "exploded", 300},
+			{"Saturn-V", "APOLLO", "active", 500},
+			{"Saturn-V", "art.+", "active", 700},
+		} {
+			rocket := *newRocket(uuid.New(), 0)
+			lastMessageTime := sent.Add(time.Duration(i) * time.Hour)
+			rocket.Type, rocket.Mission, rocket.Status, rocket.Speed = fields.rocketType, fields.mission, fields.status, fields.speed
+			rocket.LastMessageTime = &lastMessageTime
+			if fields.status == "exploded" {
+				rocket.ExplosionReason = &reason
+			}
+			require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+		}
+		require.NoError(t, rocketsRepository.Upsert(ctx, *newRocket(uuid.New(), 0)))
+
+		missions := func(filter RocketFilter) []string {
+			page, err := rocketsRepository.All(ctx, RocketQuery{Filter: filter, Sort: []SortKey{{Field: "speed"}}})
+			require.NoError(t, err)
+			assert.Len(t, page.Rockets, page.Total)
+			result := make([]string, 0, len(page.Rockets))
+			for _, rocket := range page.Rockets {
+				result = append(result, rocket.Mission)
+			}
+			return result
+		}
+		minSpeed, maxSpeed := 200, 500
+		from, to := sent.Add(time.Hour), sent.Add(2*time.Hour)
+		assert.Len(t, missions(RocketFilter{}), 5)
+		assert.Equal(t, []string{"", "ARTEMIS", "APOLLO", "art.+"}, missions(RocketFilter{Status: "active"}))
+		assert.Equal(t, []string{"APOLLO", "art.+"}, missions(RocketFilter{Type: "Saturn-V"}))
+		assert.Equal(t, []string{"ARTEMIS"}, missions(RocketFilter{Mission: "ARTEMIS"}))
+		assert.Equal(t, []string{"ARTEMIS", "ARTEMIS-2"}, missions(RocketFilter{MissionPrefix: "ART"}))
+		assert.Empty(t, missions(RocketFilter{Mission: "ARTEMIS", MissionPrefix: "ARTEMIS-"}))
+		assert.Equal(t, []string{"ARTEMIS-2", "APOLLO"}, missions(RocketFilter{MinSpeed: &minSpeed, MaxSpeed: &maxSpeed}))
+		assert.Equal(t, []string{"ARTEMIS-2", "APOLLO"}, missions(RocketFilter{LastMessageFrom: &from, LastMessageTo: &to}))
+		assert.Equal(t, []string{"art.+"}, missions(RocketFilter{Search: "T.+"}))
+		assert.Equal(t, []string{"ARTEMIS-2"}, missions(RocketFilter{Search: "vessel"}))
+	})
+
+	t.Run("derived metrics sorted and filtered", func(t *testing.T) {
+		_, rocketsRepository, _ := setup(t)
+		for i, distance := range []float64{2500.5, 100, 7200.25} {
+			rocket := *newRocket(uuid.New(), 0)
+			launchTime := sent.Add(time.Duration(i) * time.Hour)
+			rocket.Mission = "MISSION-" + strconv.Itoa(i)
+			rocket.PeakSpeed, rocket.TotalAcceleration, rocket.MissionChanges = 1000*(i+1), 100*i, i
+			rocket.LaunchTime, rocket.FlightDuration, rocket.Distance = &launchTime, float64(60*i), distance
+			require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+
+			stored, err := rocketsRepository.FindByChannel(ctx, rocket.Channel)
+			require.NoError(t, err)
+			assert.Equal(t, rocket.PeakSpeed, stored.PeakSpeed)
+			assert.Equal(t, rocket.TotalAcceleration, stored.TotalAcceleration)
+			assert.Equal(t, rocket.MissionChanges, stored.MissionChanges)
+			require.NotNil(t, stored.LaunchTime)
+			assert.True(t, launchTime.Equal(*stored.LaunchTime))
+			assert.Equal(t, rocket.FlightDuration, stored.FlightDuration)
+			assert.Equal(t, rocket.Distance, stored.Distance)
+		}
+		require.NoError(t, rocketsRepository.Upsert(ctx, *newRocket(uuid.New(), 0)))
+
+		missions := func(query RocketQuery) []string {
+			page, err := rocketsRepository.All(ctx, query)
+			require.NoError(t, err)
+			result := make([]string, 0, len(page.Rockets))
+			for _, rocket := range page.Rockets {
+				result = append(result, rocket.Mission)
+			}
+			return result
+		}
+		byDistance, err := ParseRocketSort("-distance", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"MISSION-2", "MISSION-0", "MISSION-1", ""}, missions(RocketQuery{Sort: byDistance}))
+		byLaunch, err := ParseRocketSort("launchTime", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"MISSION-2", "MISSION-1", "MISSION-0", ""}, missions(RocketQuery{Sort: byLaunch}))
+
+		minChanges, maxDistance, from := 1, 5000.0, sent.Add(30*time.Minute)
+		assert.Equal(t, []string{"MISSION-1"}, missions(RocketQuery{Filter: RocketFilter{MinMissionChanges: &minChanges, MaxDistance: &maxDistance}}))
+		assert.Equal(t, []string{"MISSION-1", "MISSION-2"}, missions(RocketQuery{Filter: RocketFilter{LaunchedFrom: &from}, Sort: []SortKey{{Field: "peakSpeed"}}}))
+
+		// A cursor carries the float values of the sort
+		page, err := rocketsRepository.All(ctx, RocketQuery{Sort: byDistance, Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"MISSION-0", "MISSION-1", ""}, missions(RocketQuery{Sort: byDistance, Cursor: page.NextCursor}))
+	})
+
+	t.Run("fleet stats", func(t *testing.T) {
+		_, rocketsRepository, _ := setup(t)
+		pressure, engine := "PRESSURE_VESSEL_FAILURE", "ENGINE_FAILURE"
+		for _, fields := range []struct {
+			rocketType, mission, status string
+			reason                      *string
+			speed, hoursAgo             int
+		}{
+			{"Falcon-9", "ARTEMIS", "active", nil, 100, 0},
+			{"Falcon-9", "ARTEMIS", "exploded", &pressure, 300, 1},
+			{"Falcon-9", "APOLLO", "exploded", &pressure, 200, 5},
+			{"Saturn-V", "APOLLO", "exploded", &engine, 1000, 2},
+		} {
+			rocket := *newRocket(uuid.New(), 0)
+			rocket.Type, rocket.Mission, rocket.Status, rocket.ExplosionReason = fields.rocketType, fields.mission, fields.status, fields.reason
+			rocket.Speed = fields.speed
+			lastMessageTime := sent.Add(-time.Duration(fields.hoursAgo) * time.Hour)
+			rocket.LastMessageTime = &lastMessageTime
+			require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+		}
+
+		stats, err := rocketsRepository.Stats(ctx, StatsQuery{ActiveSince: sent.Add(-90 * time.Minute), GroupBy: "mission"})
+		require.NoError(t, err)
+		assert.Equal(t, 4, stats.Total)
+		assert.Equal(t, 2, stats.Active)
+		assert.Equal(t, []StatsCount{{Key: "active", Count: 1}, {Key: "exploded", Count: 3}}, stats.ByStatus)
+		assert.Equal(t, []StatsCount{{Key: "Falcon-9", Count: 3}, {Key: "Saturn-V", Count: 1}}, stats.ByType)
+		assert.Equal(t, []StatsCount{{Key: "APOLLO", Count: 2}, {Key: "ARTEMIS", Count: 2}}, stats.ByMission)
+		assert.Equal(t, []StatsCount{{Key: engine, Count: 1}, {Key: pressure, Count: 2}}, stats.Explosions)
+		assert.Equal(t, []StatsGroup{
+			{Key: "Falcon-9", Count: 3, Active: 2, AverageSpeed: 200, MinSpeed: 100, MaxSpeed: 300},
+			{Key: "Saturn-V", Count: 1, Active: 0, AverageSpeed: 1000, MinSpeed: 1000, MaxSpeed: 1000},
+		}, stats.SpeedByType)
+		assert.Equal(t, []StatsGroup{
+			{Key: "APOLLO", Count: 2, Active: 0, AverageSpeed: 600, MinSpeed: 200, MaxSpeed: 1000},
+			{Key: "ARTEMIS", Count: 2, Active: 2, AverageSpeed: 200, MinSpeed: 100, MaxSpeed: 300},
+		}, stats.Groups)
+
+		// The window narrows every aggregate to the rockets whose last message is in it
+		from, to := sent.Add(-3*time.Hour), sent.Add(-time.Hour)
+		stats, err = rocketsRepository.Stats(ctx, StatsQuery{From: &from, To: &to, ActiveSince: sent})
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Total)
+		assert.Zero(t, stats.Active)
+		assert.Equal(t, []StatsCount{{Key: engine, Count: 1}, {Key: pressure, Count: 1}}, stats.Explosions)
+		assert.Nil(t, stats.Groups)
+
+		from = sent.Add(time.Hour)
+		stats, err = rocketsRepository.Stats(ctx, StatsQuery{From: &from})
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total)
+		assert.Empty(t, stats.ByStatus)
+	})
+
+	t.Run("logs are compacted and deleted", func(t *testing.T) {
+		messageRepository, _, _ := setup(t)
+		channel, other := uuid.New(), uuid.New()
+		for number := 1; number <= 5; number++ {
+			require.NoError(t, messageRepository.Store(ctx, message(channel, number)))
+		}
+		require.NoError(t, messageRepository.Store(ctx, message(other, 1)))
+
+		stored, err := messageRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+		snapshot := snapshotMessage(Rocket{Type: "Falcon-9", Speed: -10, Mission: "ARTEMIS", Status: "active"}, stored[2])
+		deleted, err := messageRepository.Compact(ctx, snapshot)
+		require.NoError(t, err)
+		assert.Equal(t, 2, deleted)
+
+		compacted, err := messageRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+		require.Len(t, compacted, 3)
+		assert.Equal(t, RocketSnapshot, compacted[0].Metadata.MessageType)
+		assert.Equal(t, 3, compacted[0].Metadata.MessageNumber)
+		assert.True(t, stored[2].ReceivedAt.Equal(compacted[0].ReceivedAt))
+		payload, err := DecodePayload(compacted[0])
+		require.NoError(t, err)
+		assert.Equal(t, RocketSnapshotPayload{Type: "Falcon-9", Speed: -10, Mission: "ARTEMIS", Status: "active"}, payload)
+
+		// The message the snapshot took the place of is a redelivery
+		assert.ErrorIs(t, messageRepository.Store(ctx, message(channel, 3)), ErrDuplicateMessage)
+
+		deleted, err = messageRepository.DeleteChannel(ctx, channel)
+		require.NoError(t, err)
+		assert.Equal(t, 3, deleted)
+		channels, err := messageRepository.Channels(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{other}, channels)
+	})
+
+	t.Run("rockets are deleted and archived", func(t *testing.T) {
+		messageRepository, rocketsRepository, archiveRepository := setup(t)
+		channel := uuid.New()
+		require.NoError(t, messageRepository.Store(ctx, message(channel, 1)))
+		messages, err := messageRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+
+		rocket := *newRocket(channel, 0)
+		rocket.Status = "exploded"
+		require.NoError(t, rocketsRepository.Upsert(ctx, rocket))
+		assert.ErrorIs(t, rocketsRepository.Delete(ctx, rocket), ErrVersionConflict)
+
+		_, err = archiveRepository.FindByChannel(ctx, channel)
+		assert.ErrorIs(t, err, ErrRocketNotFound)
+		rocket.Version = 1
+		require.NoError(t, archiveRepository.Store(ctx, ArchivedRocket{Rocket: rocket, Messages: messages, ArchivedAt: sent}))
+		require.NoError(t, rocketsRepository.Delete(ctx, rocket))
+		_, err = rocketsRepository.FindByChannel(ctx, channel)
+		assert.ErrorIs(t, err, ErrRocketNotFound)
+
+		archived, err := archiveRepository.FindByChannel(ctx, channel)
+		require.NoError(t, err)
+		assert.Equal(t, "exploded", archived.Rocket.Status)
+		assert.Equal(t, 1, archived.Rocket.Version)
+		assert.True(t, sent.Equal(archived.ArchivedAt))
+		require.Len(t, archived.Messages, 1)
+		assert.True(t, archived.Messages[0].SameContent(message(channel, 1)))
+	})
+}
+
+func TestWebhookRepositories(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testWebhookRepositoryContract(t, NewMemoryWebhookRepository(), NewMemoryDeadLetterRepository())
+	})
+	t.Run("bolt", func(t *testing.T) {
+		db, err := OpenBolt(filepath.Join(t.TempDir(), "rockets.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		webhooks, deadLetters := NewBoltWebhookRepository(db), NewBoltDeadLetterRepository(db)
+		require.NoError(t, webhooks.EnsureBuckets(context.Background()))
+		require.NoError(t, deadLetters.EnsureBuckets(context.Background()))
+		testWebhookRepositoryContract(t, webhooks, deadLetters)
+	})
+	t.Run("mongo", func(t *testing.T) {
+		db := setupMongoDB(t).Database("rockets_" + uuid.NewString()[:8])
+		require.NoError(t, migrations.Migrate(context.Background(), db))
+		testWebhookRepositoryContract(t, NewMongoWebhookRepository(db.Collection("webhooks")), NewMongoDeadLetterRepository(db.Collection("deadLetters")))
+	})
+}
+
+func testWebhookRepositoryContract(t *testing.T, webhooks WebhookRepository, deadLetters DeadLetterRepository) {
+	ctx := context.Background()
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first := Webhook{ID: uuid.New(), URL: "https://example.com/a", Secret: "a", Events: []string{RocketExplodedEvent}, CreatedAt: created}
+	second := Webhook{ID: uuid.New(), URL: "https://example.com/b", Secret: "b", Events: LifecycleEvents, CreatedAt: created.Add(time.Second)}
+	require.NoError(t, webhooks.Store(ctx, second))
+	require.NoError(t, webhooks.Store(ctx, first))
+	all, err := webhooks.All(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, first.ID, all[0].ID)
+	assert.Equal(t, second.Events, all[1].Events)
+
+	found, err := webhooks.FindByID(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "b", found.Secret)
+	assert.True(t, created.Add(time.Second).Equal(found.CreatedAt))
+	require.NoError(t, webhooks.Delete(ctx, second.ID))
+	_, err = webhooks.FindByID(ctx, second.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.ErrorIs(t, webhooks.Delete(ctx, second.ID), ErrWebhookNotFound)
+
+	rocket := *newRocket(uuid.New(), 3)
+	*rocket.LastMessageNumber = 4
+	rocket.Status = "exploded"
+	delivery := func(failedAt time.Time) WebhookDelivery {
+		return WebhookDelivery{
+			ID:        uuid.New(),
+			WebhookID: first.ID,
+			Event:     LifecycleEvent{ID: uuid.New(), Type: RocketExplodedEvent, OccurredAt: created, Rocket: rocket},
+			Attempts:  5,
+			Error:     "webhook answered 500 Internal Server Error",
+			FailedAt:  failedAt,
+		}
+	}
+	late, early := delivery(created.Add(time.Minute)), delivery(created)
+	require.NoError(t, deadLetters.Store(ctx, late))
+	require.NoError(t, deadLetters.Store(ctx, early))
+	parked, err := deadLetters.All(ctx)
+	require.NoError(t, err)
+	require.Len(t, parked, 2)
+	assert.Equal(t, early.ID, parked[0].ID)
+
+	late.Attempts = 6
+	require.NoError(t, deadLetters.Store(ctx, late))
+	stored, err := deadLetters.FindByID(ctx, late.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 6, stored.Attempts)
+	assert.Equal(t, late.Event.ID, stored.Event.ID)
+	assert.Equal(t, "exploded", stored.Event.Rocket.Status)
+	assert.Equal(t, 4, *stored.Event.Rocket.LastMessageNumber)
+
+	require.NoError(t, deadLetters.Delete(ctx, late.ID))
+	_, err = deadLetters.FindByID(ctx, late.ID)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+	assert.ErrorIs(t, deadLetters.Delete(ctx, late.ID), ErrDeliveryNotFound)
+}
+
+func openBoltRepositories(t *testing.T, path string) (*BoltMessageRepository, *BoltRocketsRepository, *BoltArchiveRepository) {
+	db, err := OpenBolt(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	messageRepository, rocketsRepository, archiveRepository := NewBoltMessageRepository(db), NewBoltRocketsRepository(db), NewBoltArchiveRepository(db)
+	require.NoError(t, messageRepository.EnsureBuckets(context.Background()))
+	require.NoError(t, rocketsRepository.EnsureBuckets(context.Background()))
+	require.NoError(t, archiveRepository.EnsureBuckets(context.Background()))
+	return messageRepository, rocketsRepository, archiveRepository
+}
+
+func setupMongoDB(t *testing.T) *mongo.Client {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	mongoContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mongo:8.0",
+			ExposedPorts: []string{"27017/tcp"},
+			WaitingFor:   wait.ForLog("Waiting for connections").WithStartupTimeout(60 * time.Second),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mongoContainer.Terminate(context.Background()) })
+
+	host, err := mongoContainer.Host(ctx)
+	require.NoError(t, err)
+	port, err := mongoContainer.MappedPort(ctx, "27017")
+	require.NoError(t, err)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+host+":"+port.Port()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	require.NoError(t, client.Ping(ctx, nil))
+	return client
+}
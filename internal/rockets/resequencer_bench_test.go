@@ -0,0 +1,151 @@
+package rockets
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// benchMessageRepository keeps each channel log sorted by number, like the index of the mongo collection, and counts
+// the reads so the benchmark shows the round trips a real database would pay.
+type benchMessageRepository struct {
+	MessageRepository
+	mu    sync.Mutex
+	logs  map[uuid.UUID][]Message
+	reads int
+}
+
+func (r *benchMessageRepository) Store(_ context.Context, message Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log := r.logs[message.Metadata.Channel]
+	i := sort.Search(len(log), func(i int) bool { return log[i].Metadata.MessageNumber >= message.Metadata.MessageNumber })
+	if i < len(log) && log[i].Metadata.MessageNumber == message.Metadata.MessageNumber {
+		return ErrDuplicateMessage
+	}
+	message.ReceivedAt = time.Now()
+	log = append(log, Message{})
+	copy(log[i+1:], log[i:])
+	log[i] = message
+	r.logs[message.Metadata.Channel] = log
+	return nil
+}
+
+func (r *benchMessageRepository) FindByChannel(ctx context.Context, channel uuid.UUID) ([]Message, error) {
+	return r.FindAfterNumber(ctx, channel, 0)
+}
+
+func (r *benchMessageRepository) FindAfterNumber(_ context.Context, channel uuid.UUID, number int) ([]Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reads++
+	log := r.logs[channel]
+	i := sort.Search(len(log), func(i int) bool { return log[i].Metadata.MessageNumber > number })
+	return append([]Message(nil), log[i:]...), nil
+}
+
+type benchRocketsRepository struct {
+	RocketsRepository
+	mu      sync.Mutex
+	rockets map[uuid.UUID]Rocket
+	reads   int
+}
+
+func (r *benchRocketsRepository) FindByChannel(_ context.Context, channel uuid.UUID) (*Rocket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reads++
+	rocket, exists := r.rockets[channel]
+	if !exists {
+		return nil, ErrRocketNotFound
+	}
+	return &rocket, nil
+}
+
+func (r *benchRocketsRepository) Upsert(_ context.Context, rocket Rocket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rockets[rocket.Channel] = rocket
+	return nil
+}
+
+// reorderedChannel returns the messages of a channel shuffled within windows of the given size, so most messages
+// arrive before the one they follow.
+func reorderedChannel(length, window int) []Message {
+	channel := uuid.New()
+	messages := make([]Message, length)
+	messages[0] = Message{
+		Metadata: Metadata{Channel: channel, MessageNumber: 1, MessageTime: time.Now(), MessageType: RocketLaunched},
+		Message:  map[string]interface{}{"type": "Falcon-9", "launchSpeed": 500, "mission": "ARTEMIS"},
+	}
+	for i := 1; i < length; i++ {
+		messages[i] = Message{
+			Metadata: Metadata{Channel: channel, MessageNumber: i + 1, MessageTime: time.Now(), MessageType: RocketSpeedIncreased},
+			Message:  map[string]interface{}{"by": 1},
+		}
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for start := 0; start < length; start += window {
+		end := min(start+window, length)
+		random.Shuffle(end-start, func(i, j int) {
+			messages[start+i], messages[start+j] = messages[start+j], messages[start+i]
+		})
+	}
+	return messages
+}
+
+func BenchmarkMessageServices(b *testing.B) {
+	services := []struct {
+		name string
+		new  func(MessageRepository, RocketsRepository) MessageService
+	}{
+		{"resequencer", func(mr MessageRepository, rr RocketsRepository) MessageService {
+			return NewResequencerMessageService(mr, rr, GapPolicy{})
+		}},
+		{"buffered", func(mr MessageRepository, rr RocketsRepository) MessageService {
+			return NewBufferedMessageService(mr, rr, GapPolicy{})
+		}},
+	}
+
+	for _, length := range []int{1000, 10000} {
+		for _, window := range []int{1, 50} {
+			messages := reorderedChannel(length, window)
+			for _, service := range services {
+				b.Run(fmt.Sprintf("%s/messages=%d/window=%d", service.name, length, window), func(b *testing.B) {
+					reads := 0
+					for i := 0; i < b.N; i++ {
+						b.StopTimer()
+						messageRepository := &benchMessageRepository{logs: make(map[uuid.UUID][]Message)}
+						rocketsRepository := &benchRocketsRepository{rockets: make(map[uuid.UUID]Rocket)}
+						messageService := service.new(messageRepository, rocketsRepository)
+						b.StartTimer()
+
+						for _, message := range messages {
+							_ = messageService.Ingest(context.Background(), message)
+						}
+
+						b.StopTimer()
+						if rocket := rocketsRepository.rockets[messages[0].Metadata.Channel]; *rocket.LastMessageNumber != length {
+							b.Fatalf("rocket stopped at message %d", *rocket.LastMessageNumber)
+						}
+						reads += messageRepository.reads + rocketsRepository.reads
+						b.StartTimer()
+					}
+					b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*length), "ns/msg")
+					b.ReportMetric(float64(reads)/float64(b.N*length), "reads/msg")
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package rockets
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetentionPolicy decides which messages are folded into a snapshot of their rocket and when exploded rockets leave
+// the live collections. The zero value keeps everything.
+type RetentionPolicy struct {
+	// MaxAge folds the applied messages received longer ago than this.
+	MaxAge time.Duration
+	// KeepMessages folds the applied messages of a rocket except its last KeepMessages ones.
+	KeepMessages int
+	// ArchiveAfter is how long an exploded rocket stays live after its last message, 0 never archives.
+	ArchiveAfter time.Duration
+}
+
+func (p RetentionPolicy) compacts() bool {
+	return p.MaxAge > 0 || p.KeepMessages > 0
+}
+
+// RetentionReport tells what a retention run deleted, or would delete in a dry run.
+type RetentionReport struct {
+	DryRun            bool               `json:"dryRun"`
+	CompactedChannels int                `json:"compactedChannels"`
+	CompactedMessages int                `json:"compactedMessages"`
+	ArchivedRockets   int                `json:"archivedRockets"`
+	ArchivedMessages  int                `json:"archivedMessages"`
+	Channels          []ChannelRetention `json:"channels"`
+}
+
+// ChannelRetention is what a retention run did to a single channel.
+type ChannelRetention struct {
+	Channel uuid.UUID `json:"channel"`
+	// SnapshotAt is the message number the log was compacted to, 0 when it was not compacted.
+	SnapshotAt      int  `json:"snapshotAt,omitempty"`
+	DeletedMessages int  `json:"deletedMessages"`
+	Archived        bool `json:"archived,omitempty"`
+}
+
+// RetentionService compacts message logs and archives exploded rockets. Only applied messages before the first skipped
+// number are compacted, the ones a late message would replay from the snapshot, so it can run while messages are
+// ingested.
+type RetentionService struct {
+	messageRepository MessageRepository
+	rocketsRepository RocketsRepository
+	archiveRepository ArchiveRepository
+	policy            RetentionPolicy
+}
+
+func NewRetentionService(messageRepository MessageRepository, rocketsRepository RocketsRepository, archiveRepository ArchiveRepository, policy RetentionPolicy) *RetentionService {
+	return &RetentionService{
+		messageRepository: messageRepository,
+		rocketsRepository: rocketsRepository,
+		archiveRepository: archiveRepository,
+		policy:            policy,
+	}
+}
+
+// Run applies the policy to every rocket. A dry run reads the same messages and reports without deleting anything.
+func (s *RetentionService) Run(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+	report := &RetentionReport{DryRun: dryRun, Channels: make([]ChannelRetention, 0)}
+	now := time.Now()
+
+	fleet, err := s.rocketsRepository.All(ctx, RocketQuery{})
+	if err != nil {
+		return nil, err
+	}
+	rockets := fleet.Rockets
+	live := make(map[uuid.UUID]bool, len(rockets))
+	for _, rocket := range rockets {
+		live[rocket.Channel] = true
+
+		messages, err := s.messageRepository.FindByChannel(ctx, rocket.Channel)
+		if err != nil {
+			return nil, err
+		}
+
+		var retention *ChannelRetention
+		if s.archives(rocket, now) {
+			retention, err = s.archive(ctx, rocket, messages, now, dryRun)
+		} else if s.policy.compacts() {
+			retention, err = s.compact(ctx, rocket, messages, now, dryRun)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if retention != nil {
+			report.add(*retention)
+		}
+	}
+
+	// Messages left behind by an archive that was interrupted, or that arrived after it
+	channels, err := s.messageRepository.Channels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if live[channel] {
+			continue
+		}
+		if _, err := s.archiveRepository.FindByChannel(ctx, channel); errors.Is(err, ErrRocketNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		deleted := 0
+		if dryRun {
+			messages, err := s.messageRepository.FindByChannel(ctx, channel)
+			if err != nil {
+				return nil, err
+			}
+			deleted = len(messages)
+		} else if deleted, err = s.messageRepository.DeleteChannel(ctx, channel); err != nil {
+			return nil, err
+		}
+		report.add(ChannelRetention{Channel: channel, DeletedMessages: deleted, Archived: true})
+	}
+
+	return report, nil
+}
+
+func (s *RetentionService) archives(rocket Rocket, now time.Time) bool {
+	return s.policy.ArchiveAfter > 0 && rocket.Status == "exploded" && rocket.LastMessageTime != nil &&
+		rocket.LastMessageTime.Before(now.Add(-s.policy.ArchiveAfter))
+}
+
+// archive stores the rocket with its log in the archive, then removes both from the live collections. A rocket that
+// changed meanwhile is left live until the next run.
+func (s *RetentionService) archive(ctx context.Context, rocket Rocket, messages []Message, now time.Time, dryRun bool) (*ChannelRetention, error) {
+	retention := &ChannelRetention{Channel: rocket.Channel, DeletedMessages: len(messages), Archived: true}
+	if dryRun {
+		return retention, nil
+	}
+
+	if err := s.archiveRepository.Store(ctx, ArchivedRocket{Rocket: rocket, Messages: messages, ArchivedAt: now}); err != nil {
+		return nil, err
+	}
+	if err := s.rocketsRepository.Delete(ctx, rocket); errors.Is(err, ErrVersionConflict) {
+		slog.Info("rocket changed while archiving, keeping it", "channel", rocket.Channel)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.messageRepository.DeleteChannel(ctx, rocket.Channel)
+	if err != nil {
+		return nil, err
+	}
+	retention.DeletedMessages = deleted
+	slog.Info("archived rocket", "channel", rocket.Channel, "messages", deleted)
+	return retention, nil
+}
+
+// compact folds the log of the rocket up to the compaction point into a snapshot.
+func (s *RetentionService) compact(ctx context.Context, rocket Rocket, messages []Message, now time.Time, dryRun bool) (*ChannelRetention, error) {
+	point := s.compactionPoint(rocket, sinceSnapshot(messages), now)
+	if point == 0 {
+		return nil, nil
+	}
+
+	deleted := 0
+	for _, message := range messages {
+		if message.Metadata.MessageNumber < point {
+			deleted++
+		}
+	}
+
+	var snapshot *Message
+	replayed := newRocket(rocket.Channel, 0)
+	for _, message := range sinceSnapshot(messages) {
+		number := message.Metadata.MessageNumber
+		if number > point {
+			break
+		}
+		if number != *replayed.LastMessageNumber+1 && message.Metadata.MessageType != RocketSnapshot {
+			// Only possible if the log lost messages, the rocket can't be replayed to the point
+			slog.Error("log is not contiguous, not compacting", "channel", rocket.Channel, "messageNumber", number)
+			return nil, nil
+		}
+		if err := applyMessage(replayed, message); err != nil {
+			return nil, err
+		}
+		if number == point {
+			message := snapshotMessage(*replayed, message)
+			snapshot = &message
+		}
+	}
+	if snapshot == nil || deleted == 0 {
+		return nil, nil
+	}
+
+	if !dryRun {
+		var err error
+		if deleted, err = s.messageRepository.Compact(ctx, *snapshot); err != nil {
+			return nil, err
+		}
+		slog.Info("compacted message log", "channel", rocket.Channel, "snapshotAt", point, "messages", deleted)
+	}
+	return &ChannelRetention{Channel: rocket.Channel, SnapshotAt: point, DeletedMessages: deleted}, nil
+}
+
+// compactionPoint returns the highest message number the policy folds, 0 when it folds nothing. It never goes past
+// the first skipped number, so a skipped message arriving late can still be replayed from the snapshot.
+func (s *RetentionService) compactionPoint(rocket Rocket, messages []Message, now time.Time) int {
+	if rocket.LastMessageNumber == nil || len(messages) == 0 {
+		return 0
+	}
+	last := *rocket.LastMessageNumber
+	if len(rocket.SkippedMessages) > 0 {
+		last = min(last, rocket.SkippedMessages[0].From-1)
+	}
+
+	point := 0
+	if s.policy.KeepMessages > 0 {
+		point = last - s.policy.KeepMessages
+	}
+	if s.policy.MaxAge > 0 {
+		cutoff := now.Add(-s.policy.MaxAge)
+		for _, message := range messages {
+			number := message.Metadata.MessageNumber
+			if number > last || !message.ReceivedAt.Before(cutoff) {
+				break
+			}
+			point = max(point, number)
+		}
+	}
+
+	// The log already starts there
+	if point <= messages[0].Metadata.MessageNumber {
+		return 0
+	}
+	return point
+}
+
+func (r *RetentionReport) add(retention ChannelRetention) {
+	r.Channels = append(r.Channels, retention)
+	if retention.Archived {
+		r.ArchivedRockets++
+		r.ArchivedMessages += retention.DeletedMessages
+		return
+	}
+	r.CompactedChannels++
+	r.CompactedMessages += retention.DeletedMessages
+}
+
+// snapshotMessage returns the snapshot of the rocket right after the message, which it takes the place of.
+func snapshotMessage(rocket Rocket, message Message) Message {
+	fields := map[string]interface{}{
+		"type":    rocket.Type,
+		"speed":   rocket.Speed,
+		"mission": rocket.Mission,
+		"status":  rocket.Status,
+	}
+	if rocket.ExplosionReason != nil {
+		fields["explosionReason"] = *rocket.ExplosionReason
+	}
+	fields["peakSpeed"] = rocket.PeakSpeed
+	fields["totalAcceleration"] = rocket.TotalAcceleration
+	fields["missionChanges"] = rocket.MissionChanges
+	if rocket.LaunchTime != nil {
+		fields["launchTime"] = rocket.LaunchTime.Format(time.RFC3339Nano)
+	}
+	fields["flightDuration"] = rocket.FlightDuration
+	fields["distance"] = rocket.Distance
+
+	message.Metadata.MessageType = RocketSnapshot
+	message.Message = fields
+	return message
+}
+
+// sinceSnapshot returns the log from its last snapshot on. Messages before it were folded into it, or arrived again
+// after the compaction.
+func sinceSnapshot(messages []Message) []Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Metadata.MessageType == RocketSnapshot {
+			return messages[i:]
+		}
+	}
+	return messages
+}
@@ -0,0 +1,214 @@
+package rockets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flight returns the messages of a rocket launched at 500 that speeds up by 10 with every following message.
+func flight(channel uuid.UUID, length int, sent time.Time) []Message {
+	messages := []Message{{
+		Metadata: Metadata{Channel: channel, MessageNumber: 1, MessageTime: sent, MessageType: RocketLaunched},
+		Message:  map[string]interface{}{"type": "Falcon-9", "launchSpeed": float64(500), "mission": "ARTEMIS"},
+	}}
+	for number := 2; number <= length; number++ {
+		messages = append(messages, Message{
+			Metadata: Metadata{Channel: channel, MessageNumber: number, MessageTime: sent.Add(time.Duration(number) * time.Second), MessageType: RocketSpeedIncreased},
+			Message:  map[string]interface{}{"by": float64(10)},
+		})
+	}
+	return messages
+}
+
+func numbers(messages []Message) []int {
+	result := make([]int, 0, len(messages))
+	for _, message := range messages {
+		result = append(result, message.Metadata.MessageNumber)
+	}
+	return result
+}
+
+func TestRetentionService_KeepsSnapshotAndLastMessages(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	rocketsService := NewRocketsServiceImpl(rocketsRepository, messageRepository)
+	channel := uuid.New()
+	for _, message := range flight(channel, 10, time.Now()) {
+		require.NoError(t, messageService.Ingest(ctx, message))
+	}
+	before, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+
+	retention := NewRetentionService(messageRepository, rocketsRepository, NewMemoryArchiveRepository(), RetentionPolicy{KeepMessages: 3})
+	dryRun, err := retention.Run(ctx, true)
+	require.NoError(t, err)
+	stored, err := messageRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Len(t, stored, 10)
+
+	report, err := retention.Run(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.CompactedChannels)
+	assert.Equal(t, 6, report.CompactedMessages)
+	assert.Equal(t, []ChannelRetention{{Channel: channel, SnapshotAt: 7, DeletedMessages: 6}}, report.Channels)
+	report.DryRun = true
+	assert.Equal(t, dryRun, report)
+
+	compacted, err := messageRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, []int{7, 8, 9, 10}, numbers(compacted))
+	after, err := rocketsRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	// The past after the snapshot can still be rebuilt, not before it
+	number := 8
+	past, err := rocketsService.GetByChannelAsOf(ctx, channel, AsOf{MessageNumber: &number})
+	require.NoError(t, err)
+	assert.Equal(t, 570, past.Speed)
+	number = 5
+	_, err = rocketsService.GetByChannelAsOf(ctx, channel, AsOf{MessageNumber: &number})
+	assert.ErrorIs(t, err, ErrCompactedLog)
+	assert.ErrorContains(t, err, "earliest point left is message 7")
+
+	// The derived metrics carry over the snapshot
+	assert.Equal(t, 590, before.PeakSpeed)
+	assert.Equal(t, 90, before.TotalAcceleration)
+	assert.Equal(t, 10.0, before.FlightDuration)
+	assert.Equal(t, 500*2+510+520+530+540+550+560+570+580.0, before.Distance)
+	number = 10
+	replayed, err := rocketsService.GetByChannelAsOf(ctx, channel, AsOf{MessageNumber: &number})
+	require.NoError(t, err)
+	assert.Equal(t, before.PeakSpeed, replayed.PeakSpeed)
+	assert.Equal(t, before.TotalAcceleration, replayed.TotalAcceleration)
+	assert.Equal(t, before.LaunchTime, replayed.LaunchTime)
+	assert.Equal(t, before.FlightDuration, replayed.FlightDuration)
+	assert.Equal(t, before.Distance, replayed.Distance)
+
+	// Nothing is left to fold until more messages are applied
+	report, err = retention.Run(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Channels)
+}
+
+func TestRetentionService_LateMessageReplaysFromSnapshot(t *testing.T) {
+	for _, service := range []struct {
+		name string
+		new  func(MessageRepository, RocketsRepository) MessageService
+	}{
+		{"resequencer", func(mr MessageRepository, rr RocketsRepository) MessageService {
+			return NewResequencerMessageService(mr, rr, GapPolicy{MaxPending: 2})
+		}},
+		{"buffered", func(mr MessageRepository, rr RocketsRepository) MessageService {
+			return NewBufferedMessageService(mr, rr, GapPolicy{MaxPending: 2})
+		}},
+	} {
+		t.Run(service.name, func(t *testing.T) {
+			ctx := context.Background()
+			messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+			messageService := service.new(messageRepository, rocketsRepository)
+			channel := uuid.New()
+			messages := flight(channel, 10, time.Now())
+
+			// Message 6 is skipped by the gap policy
+			for _, message := range messages {
+				if message.Metadata.MessageNumber != 6 {
+					require.NoError(t, messageService.Ingest(ctx, message))
+				}
+			}
+			rocket, err := rocketsRepository.FindByChannel(ctx, channel)
+			require.NoError(t, err)
+			require.Equal(t, []SkippedRange{{From: 6, To: 6}}, rocket.SkippedMessages)
+
+			retention := NewRetentionService(messageRepository, rocketsRepository, NewMemoryArchiveRepository(), RetentionPolicy{KeepMessages: 1})
+			report, err := retention.Run(ctx, false)
+			require.NoError(t, err)
+			// Compaction stops before the skipped number, which a late message would replay from
+			assert.Equal(t, []ChannelRetention{{Channel: channel, SnapshotAt: 4, DeletedMessages: 3}}, report.Channels)
+
+			require.NoError(t, messageService.Ingest(ctx, messages[5]))
+			rocket, err = rocketsRepository.FindByChannel(ctx, channel)
+			require.NoError(t, err)
+			assert.Empty(t, rocket.SkippedMessages)
+			assert.Equal(t, 10, *rocket.LastMessageNumber)
+			assert.Equal(t, 590, rocket.Speed)
+		})
+	}
+}
+
+func TestRetentionService_FoldsOldMessages(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository()
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	channel := uuid.New()
+	messages := flight(channel, 6, time.Now())
+	for _, message := range messages[:4] {
+		require.NoError(t, messageService.Ingest(ctx, message))
+	}
+	time.Sleep(20 * time.Millisecond)
+	for _, message := range messages[4:] {
+		require.NoError(t, messageService.Ingest(ctx, message))
+	}
+
+	retention := NewRetentionService(messageRepository, rocketsRepository, NewMemoryArchiveRepository(), RetentionPolicy{MaxAge: 10 * time.Millisecond})
+	report, err := retention.Run(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, []ChannelRetention{{Channel: channel, SnapshotAt: 4, DeletedMessages: 3}}, report.Channels)
+
+	compacted, err := messageRepository.FindByChannel(ctx, channel)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 5, 6}, numbers(compacted))
+}
+
+func TestRetentionService_ArchivesExplodedRockets(t *testing.T) {
+	ctx := context.Background()
+	messageRepository, rocketsRepository, archiveRepository := NewMemoryMessageRepository(), NewMemoryRocketsRepository(), NewMemoryArchiveRepository()
+	messageService := NewResequencerMessageService(messageRepository, rocketsRepository, GapPolicy{})
+	exploded, flying := uuid.New(), uuid.New()
+	sent := time.Now().Add(-2 * time.Hour)
+	for _, message := range append(flight(exploded, 3, sent), flight(flying, 3, sent)...) {
+		require.NoError(t, messageService.Ingest(ctx, message))
+	}
+	explosion := Message{
+		Metadata: Metadata{Channel: exploded, MessageNumber: 4, MessageTime: sent.Add(time.Minute), MessageType: RocketExploded},
+		Message:  map[string]interface{}{"reason": "PRESSURE_VESSEL_FAILURE"},
+	}
+	require.NoError(t, messageService.Ingest(ctx, explosion))
+
+	retention := NewRetentionService(messageRepository, rocketsRepository, archiveRepository, RetentionPolicy{ArchiveAfter: time.Hour})
+	dryRun, err := retention.Run(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, dryRun.ArchivedRockets)
+	assert.Equal(t, 4, dryRun.ArchivedMessages)
+	_, err = rocketsRepository.FindByChannel(ctx, exploded)
+	require.NoError(t, err)
+
+	report, err := retention.Run(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, []ChannelRetention{{Channel: exploded, DeletedMessages: 4, Archived: true}}, report.Channels)
+
+	_, err = rocketsRepository.FindByChannel(ctx, exploded)
+	assert.ErrorIs(t, err, ErrRocketNotFound)
+	_, err = rocketsRepository.FindByChannel(ctx, flying)
+	assert.NoError(t, err)
+	archived, err := archiveRepository.FindByChannel(ctx, exploded)
+	require.NoError(t, err)
+	assert.Equal(t, "exploded", archived.Rocket.Status)
+	assert.Equal(t, []int{1, 2, 3, 4}, numbers(archived.Messages))
+
+	// A message arriving after the archive is dropped by the next run
+	late := flight(exploded, 5, sent)[4]
+	require.NoError(t, messageService.Ingest(ctx, late))
+	report, err = retention.Run(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, []ChannelRetention{{Channel: exploded, DeletedMessages: 1, Archived: true}}, report.Channels)
+	stored, err := messageRepository.FindByChannel(ctx, exploded)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
@@ -4,121 +4,321 @@ import (
 	"context"
 	"errors"
 	"log/slog"
-	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type RocketsService struct {
-	repository RocketsRepository
+	repository        RocketsRepository
+	messageRepository MessageRepository
 }
 
-func NewRocketsServiceImpl(repository RocketsRepository) *RocketsService {
-	return &RocketsService{repository: repository}
+func NewRocketsServiceImpl(repository RocketsRepository, messageRepository MessageRepository) *RocketsService {
+	return &RocketsService{repository: repository, messageRepository: messageRepository}
 }
 
-func (r RocketsService) GetAll(ctx context.Context, sortBy *string, order *string) ([]Rocket, error) {
-	return r.repository.All(ctx, sortBy, order)
+func (r RocketsService) GetAll(ctx context.Context, query RocketQuery) (*RocketPage, error) {
+	return r.repository.All(ctx, query)
 }
 
 func (r RocketsService) GetByChannel(ctx context.Context, channel uuid.UUID) (*Rocket, error) {
 	return r.repository.FindByChannel(ctx, channel)
 }
 
+type MessageProcessor interface {
+	// Process applies the stored message, and whatever it unblocks, to its rocket.
+	Process(ctx context.Context, message Message) error
+	// ProcessBatch applies messages of a single channel that were stored together.
+	ProcessBatch(ctx context.Context, messages []Message) error
+	// Shutdown stops the processing started in the background, like gap timers, and waits for what is running.
+	Shutdown(ctx context.Context) error
+}
+
 type MessageService interface {
+	MessageProcessor
+	// Ingest stores and processes a message. Redelivered messages are reported with ErrDuplicateMessage.
 	Ingest(ctx context.Context, message Message) error
-	Process(ctx context.Context, message Message) error
+	// IngestBatch stores every message and processes each affected channel once. Results follow the message order.
+	IngestBatch(ctx context.Context, messages []Message) []IngestResult
+	// Async reports whether Ingest returns before the message is applied to its rocket.
+	Async() bool
+}
+
+type IngestStatus string
+
+const (
+	IngestAccepted  IngestStatus = "accepted"
+	IngestDuplicate IngestStatus = "duplicate"
+	IngestConflict  IngestStatus = "conflict"
+	IngestInvalid   IngestStatus = "invalid"
+	IngestRejected  IngestStatus = "rejected"
+	IngestFailed    IngestStatus = "failed"
+)
+
+type IngestResult struct {
+	Status IngestStatus
+	Reason string
+}
+
+func newIngestResult(err error) IngestResult {
+	switch {
+	case err == nil:
+		return IngestResult{Status: IngestAccepted}
+	case errors.Is(err, ErrDuplicateMessage):
+		return IngestResult{Status: IngestDuplicate}
+	case errors.Is(err, ErrConflictingMessage):
+		return IngestResult{Status: IngestConflict, Reason: err.Error()}
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrShuttingDown):
+		return IngestResult{Status: IngestRejected, Reason: err.Error()}
+	default:
+		return IngestResult{Status: IngestFailed, Reason: err.Error()}
+	}
+}
+
+// groupByChannel returns the position of the messages of each channel, with channels in order of first appearance.
+func groupByChannel(messages []Message) ([]uuid.UUID, map[uuid.UUID][]int) {
+	var channels []uuid.UUID
+	groups := make(map[uuid.UUID][]int)
+	for i, message := range messages {
+		channel := message.Metadata.Channel
+		if _, exists := groups[channel]; !exists {
+			channels = append(channels, channel)
+		}
+		groups[channel] = append(groups[channel], i)
+	}
+	return channels, groups
 }
 
 type ResequencerMessageService struct {
 	messageRepository MessageRepository
 	rocketsRepository RocketsRepository
-	channelMutexes    map[uuid.UUID]*sync.Mutex
-	mutexLock         sync.Mutex
+	gapPolicy         GapPolicy
+	gapTimers         gapTimers
+	locks             *lockRegistry
+	feed              *RocketFeed
+	webhooks          *WebhookService
 }
 
-func NewResequencerMessageService(messageRepository MessageRepository, rocketsRepository RocketsRepository) *ResequencerMessageService {
+func NewResequencerMessageService(messageRepository MessageRepository, rocketsRepository RocketsRepository, gapPolicy GapPolicy) *ResequencerMessageService {
 	return &ResequencerMessageService{
 		messageRepository: messageRepository,
 		rocketsRepository: rocketsRepository,
-		channelMutexes:    make(map[uuid.UUID]*sync.Mutex),
+		gapPolicy:         gapPolicy,
+		locks:             newLockRegistry(),
 	}
 }
 
+// PublishTo makes the service publish every rocket it stores to the feed. It has to be called before the service is
+// used.
+func (m *ResequencerMessageService) PublishTo(feed *RocketFeed) {
+	m.feed = feed
+}
+
+// NotifyWebhooks makes the service report the launches, explosions and mission changes it stores to the webhooks. Like
+// PublishTo, it is called before the service is used.
+func (m *ResequencerMessageService) NotifyWebhooks(webhooks *WebhookService) {
+	m.webhooks = webhooks
+}
+
 func (m *ResequencerMessageService) Ingest(ctx context.Context, message Message) error {
-	if err := m.messageRepository.Store(ctx, message); err != nil {
-		return err
+	return ingest(ctx, m.messageRepository, m, message)
+}
+
+func (m *ResequencerMessageService) IngestBatch(ctx context.Context, messages []Message) []IngestResult {
+	return ingestBatch(ctx, m.messageRepository, m, messages)
+}
+
+func (m *ResequencerMessageService) Async() bool {
+	return false
+}
+
+func (m *ResequencerMessageService) Shutdown(ctx context.Context) error {
+	return m.gapTimers.stop(ctx)
+}
+
+// ingest stores the message and processes it right away.
+func ingest(ctx context.Context, messageRepository MessageRepository, processor MessageProcessor, message Message) error {
+	storeErr := messageRepository.Store(ctx, message)
+	if storeErr != nil && !errors.Is(storeErr, ErrDuplicateMessage) {
+		return storeErr
 	}
 
-	if err := m.Process(ctx, message); err != nil {
+	// Duplicates are processed again, so a redelivery heals a previous attempt that stored but failed to process.
+	if err := processor.Process(ctx, message); err != nil {
 		return err
 	}
 
-	return nil
+	return storeErr
 }
 
-func (m *ResequencerMessageService) getChannelMutex(channel uuid.UUID) *sync.Mutex {
-	m.mutexLock.Lock()
-	defer m.mutexLock.Unlock()
+// ingestBatch stores the messages and processes the stored ones of each channel together.
+func ingestBatch(ctx context.Context, messageRepository MessageRepository, processor MessageProcessor, messages []Message) []IngestResult {
+	results := make([]IngestResult, len(messages))
+	channels, groups := groupByChannel(messages)
+	for _, channel := range channels {
+		var stored []int
+		for _, i := range groups[channel] {
+			err := messageRepository.Store(ctx, messages[i])
+			results[i] = newIngestResult(err)
+			if err == nil || errors.Is(err, ErrDuplicateMessage) {
+				stored = append(stored, i)
+			}
+		}
+		if len(stored) == 0 {
+			continue
+		}
 
-	if _, exists := m.channelMutexes[channel]; !exists {
-		m.channelMutexes[channel] = &sync.Mutex{}
+		batch := make([]Message, 0, len(stored))
+		for _, i := range stored {
+			batch = append(batch, messages[i])
+		}
+		if err := processor.ProcessBatch(ctx, batch); err != nil {
+			for _, i := range stored {
+				results[i] = newIngestResult(err)
+			}
+		}
 	}
-	return m.channelMutexes[channel]
+	return results
 }
 
+// Process applies every stored message of the message channel that follows the rocket state without gaps, or past the
+// gaps the policy gives up on. It only depends on the channel, so one call catches up with every message stored before
+// it.
 func (m *ResequencerMessageService) Process(ctx context.Context, message Message) error {
 	// Lock channel
-	channelMutex := m.getChannelMutex(message.Metadata.Channel)
-	channelMutex.Lock()
-	defer channelMutex.Unlock()
+	channel := message.Metadata.Channel
+	unlock := m.locks.lock(channel)
+	defer unlock()
+
+	// The lock only covers this instance, another one may store the rocket in between
+	return retryOnConflict(func() error {
+		return m.process(ctx, channel)
+	})
+}
 
-	var rocket *Rocket
-	var err error
-	if message.Metadata.MessageNumber == 1 {
-		rocket, err = buildRocketState(message.Metadata.Channel, []Message{message})
-		if err != nil {
-			slog.Error("error building rocket state", "error", err)
-			return errors.New(ProcessMessageError)
-		}
-	} else {
-		rocket, err = m.rocketsRepository.FindByChannel(ctx, message.Metadata.Channel)
-		if err != nil {
-			slog.Error("error getting rocket from db", "error", err)
-		}
+func (m *ResequencerMessageService) process(ctx context.Context, channel uuid.UUID) error {
+	rocket, err := m.rocketsRepository.FindByChannel(ctx, channel)
+	if errors.Is(err, ErrRocketNotFound) {
+		// Not launched yet, replay the log from the beginning
+		rocket = newRocket(channel, 0)
+	} else if err != nil {
+		slog.Error("error getting rocket from db", "error", err)
+		return errors.New(ProcessMessageError)
 	}
+	// Messages are applied in place, the stored state is kept to tell which ones are new
+	previous := *rocket
 
-	if rocket == nil {
-		return nil
+	// Skipped numbers are read again, in case one of them arrived late
+	after := *rocket.LastMessageNumber
+	if len(rocket.SkippedMessages) > 0 {
+		after = rocket.SkippedMessages[0].From - 1
 	}
-
-	messages, err := m.messageRepository.FindAfterNumber(ctx, message.Metadata.Channel, *rocket.LastMessageNumber)
+	messages, err := m.messageRepository.FindAfterNumber(ctx, channel, after)
 	if err != nil {
 		slog.Error("error finding messages after last message number", "error", err)
 		return errors.New(ProcessMessageError)
 	}
 
-	for _, msg := range messages {
-		if msg.Metadata.MessageNumber == *rocket.LastMessageNumber+1 {
-			err = applyMessage(rocket, msg)
-			if err != nil {
-				slog.Error("error applying message", "error", err)
-				return errors.New(ProcessMessageError)
+	// A late message changes the past, so the rocket is rebuilt from the whole log. Numbers that are still missing are
+	// skipped again up to where the rocket was.
+	replayUntil := 0
+	rebuilt := false
+	if late, exists := firstSkipped(rocket, messages); exists {
+		slog.Info("skipped message arrived late, rebuilding rocket", "channel", channel, "messageNumber", late)
+		replayUntil = *rocket.LastMessageNumber
+		rocket = newRocket(channel, rocket.Version)
+		rebuilt = true
+		if messages, err = m.messageRepository.FindByChannel(ctx, channel); err != nil {
+			slog.Error("error finding messages of channel", "error", err)
+			return errors.New(ProcessMessageError)
+		}
+		messages = sinceSnapshot(messages)
+	}
+
+	applied := 0
+	var events []LifecycleEvent
+	now := time.Now()
+	for i, msg := range messages {
+		number := msg.Metadata.MessageNumber
+		if number <= *rocket.LastMessageNumber {
+			continue
+		}
+		if number != *rocket.LastMessageNumber+1 && msg.Metadata.MessageType != RocketSnapshot {
+			// The launch is never skipped, the rocket would have no type nor mission
+			if *rocket.LastMessageNumber == 0 || (number > replayUntil && !m.gapPolicy.skips(messages[i:], now)) {
+				break
 			}
-		} else {
-			break
+			rocket.SkippedMessages = append(rocket.SkippedMessages, SkippedRange{From: *rocket.LastMessageNumber + 1, To: number - 1})
+		}
+		before := *rocket
+		if err := applyMessage(rocket, msg); err != nil {
+			slog.Error("error applying message", "error", err)
+			return errors.New(ProcessMessageError)
+		}
+		if newlyApplied(previous, number) {
+			events = append(events, lifecycleEvents(before, *rocket, now)...)
 		}
+		applied++
 	}
 
+	var waiting []Message
+	for _, msg := range messages {
+		if msg.Metadata.MessageNumber > *rocket.LastMessageNumber {
+			waiting = append(waiting, msg)
+		}
+	}
+	pending := len(waiting)
+	if pending > 0 && m.gapPolicy.Timeout > 0 {
+		m.gapTimers.schedule(channel, openSince(waiting).Add(m.gapPolicy.Timeout), m.Process)
+	}
+
+	// A rocket that is not launched yet has nothing to persist, its gaps are read from the log
+	if *rocket.LastMessageNumber == 0 || (applied == 0 && !rebuilt && pending == rocket.PendingMessages) {
+		return nil
+	}
+	rocket.PendingMessages = pending
+
 	// Persist rocket
 	if err := m.rocketsRepository.Upsert(ctx, *rocket); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return err
+		}
 		slog.Error("error persisting rocket", "error", err)
 		return errors.New(ProcessMessageError)
 	}
+	m.feed.Publish(*rocket)
+	m.webhooks.Notify(events)
 
 	return nil
 }
 
+// maxUpdateAttempts bounds how many times a channel is processed again after losing a race with another instance.
+const maxUpdateAttempts = 10
+
+// retryOnConflict runs process again while it loses the race to store the rocket. Every lost race means another writer
+// made progress, so the next attempt starts from a newer state.
+func retryOnConflict(process func() error) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		if err = process(); !errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+	}
+	slog.Error("giving up processing after concurrent updates", "attempts", maxUpdateAttempts)
+	return errors.New(ProcessMessageError)
+}
+
+// ProcessBatch catches up with the channel once, since Process does not depend on the message.
+func (m *ResequencerMessageService) ProcessBatch(ctx context.Context, messages []Message) error {
+	return m.Process(ctx, messages[len(messages)-1])
+}
+
+// newRocket returns a rocket before its launch. The version is kept when an existing rocket is rebuilt.
+func newRocket(channel uuid.UUID, version int) *Rocket {
+	return &Rocket{Channel: channel, Status: "active", LastMessageNumber: new(int), Version: version}
+}
+
 func buildRocketState(channelID uuid.UUID, messages []Message) (*Rocket, error) {
 	rocket := &Rocket{
 		Channel: channelID,
@@ -136,51 +336,69 @@ func buildRocketState(channelID uuid.UUID, messages []Message) (*Rocket, error)
 }
 
 func applyMessage(rocket *Rocket, msg Message) error {
+	payload, err := replayPayload(msg)
+	if err != nil {
+		return err
+	}
+
 	// Update metadata from the current message
 	msgNum := msg.Metadata.MessageNumber
 	rocket.LastMessageNumber = &msgNum
 	msgTime := msg.Metadata.MessageTime
 	rocket.LastMessageTime = &msgTime
 
-	switch msg.Metadata.MessageType {
-	case "RocketLaunched":
-		rocketType, _ := msg.Message["type"].(string)
-		launchSpeed, _ := msg.Message["launchSpeed"].(float64)
-		mission, _ := msg.Message["mission"].(string)
-
-		rocket.Type = rocketType
-		rocket.Speed = int(launchSpeed)
-		rocket.Mission = mission
-		rocket.Status = "active"
-
-	case "RocketSpeedIncreased":
-		by, ok := msg.Message["by"].(float64)
-		if !ok {
-			return errors.New("invalid RocketSpeedIncreased message")
-		}
-		rocket.Speed += int(by)
-
-	case "RocketSpeedDecreased":
-		by, ok := msg.Message["by"].(float64)
-		if !ok {
-			return errors.New("invalid RocketSpeedDecreased message")
+	// The speed held until this message covers the time since the latest one. Times are cut to the milliseconds the
+	// storage keeps, so a rebuild from the stored log finds the same metrics.
+	sent := msgTime.Truncate(time.Millisecond)
+	if rocket.LaunchTime != nil {
+		if elapsed := sent.Sub(*rocket.LaunchTime).Seconds(); elapsed > rocket.FlightDuration {
+			rocket.Distance += float64(rocket.Speed) * (elapsed - rocket.FlightDuration)
+			rocket.FlightDuration = elapsed
 		}
-		rocket.Speed -= int(by)
+	}
 
-	case "RocketExploded":
-		reason, _ := msg.Message["reason"].(string)
+	switch p := payload.(type) {
+	case RocketLaunchedPayload:
+		rocket.Type = p.Type
+		rocket.Speed = p.LaunchSpeed
+		rocket.Mission = p.Mission
+		rocket.Status = "active"
+		rocket.PeakSpeed = p.LaunchSpeed
+		rocket.TotalAcceleration = 0
+		rocket.MissionChanges = 0
+		rocket.LaunchTime = &sent
+		rocket.FlightDuration = 0
+		rocket.Distance = 0
+
+	case RocketSpeedIncreasedPayload:
+		rocket.Speed += p.By
+		rocket.PeakSpeed = max(rocket.PeakSpeed, rocket.Speed)
+		rocket.TotalAcceleration += p.By
+
+	case RocketSpeedDecreasedPayload:
+		rocket.Speed -= p.By
+
+	case RocketExplodedPayload:
+		reason := p.Reason
 		rocket.ExplosionReason = &reason
 		rocket.Status = "exploded"
 
-	case "RocketMissionChanged":
-		newMission, ok := msg.Message["newMission"].(string)
-		if !ok {
-			return errors.New("invalid RocketMissionChanged message")
-		}
-		rocket.Mission = newMission
-
-	default:
-		return errors.New("unknown message type")
+	case RocketMissionChangedPayload:
+		rocket.Mission = p.NewMission
+		rocket.MissionChanges++
+
+	case RocketSnapshotPayload:
+		rocket.Type = p.Type
+		rocket.Speed = p.Speed
+		rocket.Mission = p.Mission
+		rocket.Status = p.Status
+		rocket.ExplosionReason = p.ExplosionReason
+		rocket.PeakSpeed = p.PeakSpeed
+		rocket.TotalAcceleration = p.TotalAcceleration
+		rocket.MissionChanges = p.MissionChanges
+		rocket.LaunchTime = p.LaunchTime
+		rocket.FlightDuration = p.FlightDuration
+		rocket.Distance = p.Distance
 	}
 
 	return nil
@@ -0,0 +1,128 @@
+package rockets
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AsOf is a past point of a rocket log, either an instant or a message number.
+type AsOf struct {
+	Time          *time.Time
+	MessageNumber *int
+}
+
+// ParseAsOf reads a positive message number or an RFC 3339 timestamp.
+func ParseAsOf(value string) (AsOf, error) {
+	if number, err := strconv.Atoi(value); err == nil {
+		if number <= 0 {
+			return AsOf{}, &ValidationError{Field: "asOf", Reason: "must be a positive message number"}
+		}
+		return AsOf{MessageNumber: &number}, nil
+	}
+	at, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return AsOf{}, &ValidationError{Field: "asOf", Reason: "must be a timestamp or a message number"}
+	}
+	return AsOf{Time: &at}, nil
+}
+
+func (a AsOf) includes(message Message) bool {
+	if a.MessageNumber != nil && message.Metadata.MessageNumber > *a.MessageNumber {
+		return false
+	}
+	if a.Time != nil && message.Metadata.MessageTime.After(*a.Time) {
+		return false
+	}
+	return true
+}
+
+// contiguousPrefix returns the messages, ordered by number, that follow each other from the first one without gaps
+// and happened at or before the point. The log starts at message 1, or at the snapshot of a compacted log.
+func contiguousPrefix(messages []Message, asOf AsOf) []Message {
+	messages = sinceSnapshot(messages)
+	first := 1
+	if len(messages) > 0 && messages[0].Metadata.MessageType == RocketSnapshot {
+		first = messages[0].Metadata.MessageNumber
+	}
+	for i, message := range messages {
+		if message.Metadata.MessageNumber != first+i || !asOf.includes(message) {
+			return messages[:i]
+		}
+	}
+	return messages
+}
+
+// compactedPast returns the snapshot the log starts at when the point is before it, so the state at the point is gone.
+// A rocket the snapshot tells was launched after the instant had no state then, and nil is returned.
+func compactedPast(messages []Message, asOf AsOf) *Metadata {
+	retained := sinceSnapshot(messages)
+	if len(retained) == 0 || retained[0].Metadata.MessageType != RocketSnapshot || asOf.includes(retained[0]) {
+		return nil
+	}
+	if asOf.Time != nil {
+		payload, err := DecodePayload(retained[0])
+		if snapshot, ok := payload.(RocketSnapshotPayload); err == nil && ok && snapshot.LaunchTime != nil && snapshot.LaunchTime.After(*asOf.Time) {
+			return nil
+		}
+	}
+	return &retained[0].Metadata
+}
+
+// GetByChannelAsOf rebuilds the state of a rocket at a past point from its message log. It returns ErrRocketNotFound
+// when the rocket had not been launched yet at that point, and ErrCompactedLog when the point is before the snapshot
+// the log starts at.
+func (r RocketsService) GetByChannelAsOf(ctx context.Context, channel uuid.UUID, asOf AsOf) (*Rocket, error) {
+	messages, err := r.messageRepository.FindByChannel(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	if earliest := compactedPast(messages, asOf); earliest != nil {
+		return nil, fmt.Errorf("%w, the earliest point left is message %d at %s", ErrCompactedLog, earliest.MessageNumber, earliest.MessageTime.Format(time.RFC3339Nano))
+	}
+
+	prefix := contiguousPrefix(messages, asOf)
+	if len(prefix) == 0 {
+		return nil, ErrRocketNotFound
+	}
+	return buildRocketState(channel, prefix)
+}
+
+// GetAllAsOf rebuilds the state of every rocket launched at or before the instant and returns the page of the query.
+// Logs are read one channel at a time, so only the rockets are held for the whole fleet. It returns ErrCompactedLog
+// when the log of one of those rockets was compacted past the instant, rather than leaving the rocket out. Cursors
+// stay valid as long as the instant is the same.
+func (r RocketsService) GetAllAsOf(ctx context.Context, at time.Time, query RocketQuery) (*RocketPage, error) {
+	channels, err := r.messageRepository.Channels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	asOf := AsOf{Time: &at}
+	rockets := make([]Rocket, 0)
+	for _, channel := range channels {
+		messages, err := r.messageRepository.FindByChannel(ctx, channel)
+		if err != nil {
+			return nil, err
+		}
+		if earliest := compactedPast(messages, asOf); earliest != nil {
+			return nil, fmt.Errorf("%w, the earliest point left for rocket %s is %s", ErrCompactedLog, channel, earliest.MessageTime.Format(time.RFC3339Nano))
+		}
+
+		prefix := contiguousPrefix(messages, asOf)
+		if len(prefix) == 0 {
+			continue
+		}
+		rocket, err := buildRocketState(channel, prefix)
+		if err != nil {
+			return nil, err
+		}
+		rockets = append(rockets, *rocket)
+	}
+
+	return pageRockets(rockets, query)
+}
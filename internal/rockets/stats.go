@@ -0,0 +1,162 @@
+package rockets
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// StatsGroupFields are the fields the fleet statistics can be grouped by.
+var StatsGroupFields = []string{"type", "mission", "status", "explosionReason"}
+
+// StatsQuery selects the rockets aggregated into FleetStats and how.
+type StatsQuery struct {
+	// From and To only aggregate the rockets whose last applied message was sent in between, both included.
+	From *time.Time
+	To   *time.Time
+	// ActiveSince is the instant after which a rocket that sent a message counts as active.
+	ActiveSince time.Time
+	// GroupBy is one of StatsGroupFields, or empty for no groups.
+	GroupBy string
+}
+
+// StatsCount is the number of rockets with a value of a field.
+type StatsCount struct {
+	Key   string
+	Count int
+}
+
+// StatsGroup aggregates the rockets with a value of a field.
+type StatsGroup struct {
+	Key          string
+	Count        int
+	Active       int
+	AverageSpeed float64
+	MinSpeed     int
+	MaxSpeed     int
+}
+
+// FleetStats aggregates the rockets matching a StatsQuery. Counts and groups are ordered by key, rockets without a
+// value, like an explosion without a reason, are under the empty key.
+type FleetStats struct {
+	Total       int
+	Active      int
+	ByStatus    []StatsCount
+	ByType      []StatsCount
+	ByMission   []StatsCount
+	SpeedByType []StatsGroup
+	// Explosions counts the exploded rockets by explosion reason.
+	Explosions []StatsCount
+	// Groups is only set when the query has a GroupBy.
+	Groups []StatsGroup
+}
+
+// Validate reports a query that can't be aggregated with a ValidationError.
+func (q StatsQuery) Validate() error {
+	switch {
+	case q.GroupBy != "" && !slices.Contains(StatsGroupFields, q.GroupBy):
+		return &ValidationError{Field: "groupBy", Reason: fmt.Sprintf("has unknown field %q", q.GroupBy)}
+	case q.From != nil && q.To != nil && q.From.After(*q.To):
+		return &ValidationError{Field: "from", Reason: "must not be after to"}
+	}
+	return nil
+}
+
+func (r RocketsService) Stats(ctx context.Context, query StatsQuery) (*FleetStats, error) {
+	return r.repository.Stats(ctx, query)
+}
+
+// fleetStats aggregates the rockets in process, the way the mongo pipeline of RocketsRepository.Stats does.
+func fleetStats(rockets []Rocket, query StatsQuery) *FleetStats {
+	filter := RocketFilter{LastMessageFrom: query.From, LastMessageTo: query.To}
+	rockets = slices.DeleteFunc(rockets, func(rocket Rocket) bool {
+		return !filter.matches(rocket)
+	})
+
+	stats := &FleetStats{
+		Total:       len(rockets),
+		ByStatus:    countRockets(rockets, func(rocket Rocket) (string, bool) { return rocket.Status, true }),
+		ByType:      countRockets(rockets, func(rocket Rocket) (string, bool) { return rocket.Type, true }),
+		ByMission:   countRockets(rockets, func(rocket Rocket) (string, bool) { return rocket.Mission, true }),
+		SpeedByType: groupRockets(rockets, query, "type"),
+		Explosions: countRockets(rockets, func(rocket Rocket) (string, bool) {
+			return statsKey(rocket, "explosionReason"), rocket.Status == "exploded"
+		}),
+	}
+	for _, rocket := range rockets {
+		if active(rocket, query) {
+			stats.Active++
+		}
+	}
+	if query.GroupBy != "" {
+		stats.Groups = groupRockets(rockets, query, query.GroupBy)
+	}
+	return stats
+}
+
+// countRockets counts the rockets by the key returned for them, leaving out the ones it is not returned for.
+func countRockets(rockets []Rocket, key func(rocket Rocket) (string, bool)) []StatsCount {
+	counts := make(map[string]int)
+	for _, rocket := range rockets {
+		if value, counted := key(rocket); counted {
+			counts[value]++
+		}
+	}
+
+	result := make([]StatsCount, 0, len(counts))
+	for value, count := range counts {
+		result = append(result, StatsCount{Key: value, Count: count})
+	}
+	slices.SortFunc(result, func(a, b StatsCount) int { return cmp.Compare(a.Key, b.Key) })
+	return result
+}
+
+func groupRockets(rockets []Rocket, query StatsQuery, field string) []StatsGroup {
+	groups := make(map[string]*StatsGroup)
+	speeds := make(map[string]int)
+	for _, rocket := range rockets {
+		key := statsKey(rocket, field)
+		group, exists := groups[key]
+		if !exists {
+			group = &StatsGroup{Key: key, MinSpeed: rocket.Speed, MaxSpeed: rocket.Speed}
+			groups[key] = group
+		}
+		group.Count++
+		if active(rocket, query) {
+			group.Active++
+		}
+		group.MinSpeed = min(group.MinSpeed, rocket.Speed)
+		group.MaxSpeed = max(group.MaxSpeed, rocket.Speed)
+		speeds[key] += rocket.Speed
+	}
+
+	result := make([]StatsGroup, 0, len(groups))
+	for key, group := range groups {
+		group.AverageSpeed = float64(speeds[key]) / float64(group.Count)
+		result = append(result, *group)
+	}
+	slices.SortFunc(result, func(a, b StatsGroup) int { return cmp.Compare(a.Key, b.Key) })
+	return result
+}
+
+func statsKey(rocket Rocket, field string) string {
+	switch field {
+	case "type":
+		return rocket.Type
+	case "mission":
+		return rocket.Mission
+	case "status":
+		return rocket.Status
+	case "explosionReason":
+		if rocket.ExplosionReason != nil {
+			return *rocket.ExplosionReason
+		}
+	}
+	return ""
+}
+
+func active(rocket Rocket, query StatsQuery) bool {
+	return rocket.LastMessageTime != nil && !rocket.LastMessageTime.Before(query.ActiveSince)
+}
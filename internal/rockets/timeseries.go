@@ -0,0 +1,169 @@
+package rockets
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TimeseriesFields are the fields of a rocket that can be followed over time.
+var TimeseriesFields = []string{"speed"}
+
+// MaxTimeseriesBuckets bounds the buckets of a timeseries, a smaller bucket or a shorter range is needed past it.
+const MaxTimeseriesBuckets = 10000
+
+// TimeseriesQuery selects the field followed by a timeseries, and the fixed buckets it is downsampled into when Bucket
+// is set.
+type TimeseriesQuery struct {
+	Field  string
+	Bucket time.Duration
+	// From and To bound the time of the points, or of the buckets, both included.
+	From *time.Time
+	To   *time.Time
+}
+
+// TimeseriesPoint is the state of a rocket after an applied message.
+type TimeseriesPoint struct {
+	MessageNumber int
+	Time          time.Time
+	Value         int
+	Mission       string
+}
+
+// TimeseriesBucket summarizes the values a field held from Start for the length of a bucket: the value carried in
+// from the previous bucket counts, so a bucket without messages holds the last known value. Mission is the mission
+// held at the end of the bucket.
+type TimeseriesBucket struct {
+	Start    time.Time
+	Messages int
+	Min      int
+	Max      int
+	Last     int
+	Mission  string
+}
+
+// Timeseries is either the points after every applied message, or the buckets they are downsampled into.
+type Timeseries struct {
+	Points  []TimeseriesPoint
+	Buckets []TimeseriesBucket
+}
+
+// Validate reports a query that can't be answered with a ValidationError.
+func (q TimeseriesQuery) Validate() error {
+	switch {
+	case !slices.Contains(TimeseriesFields, q.Field):
+		return &ValidationError{Field: "field", Reason: fmt.Sprintf("has unknown value %q", q.Field)}
+	case q.Bucket < 0 || q.Bucket > 0 && q.Bucket < time.Second:
+		return &ValidationError{Field: "bucket", Reason: "must be at least one second"}
+	case q.From != nil && q.To != nil && q.From.After(*q.To):
+		return &ValidationError{Field: "from", Reason: "must not be after to"}
+	}
+	return nil
+}
+
+// Timeseries replays the messages applied to the rocket and follows the field after each one. Message times are taken
+// as sent, except that a message sent before the one it follows counts at the time of that one, so time only moves
+// forward along the log.
+func (r RocketsService) Timeseries(ctx context.Context, channel uuid.UUID, query TimeseriesQuery) (*Timeseries, error) {
+	rocket, err := r.repository.FindByChannel(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := r.messageRepository.FindByChannel(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	lastApplied := 0
+	if rocket.LastMessageNumber != nil {
+		lastApplied = *rocket.LastMessageNumber
+	}
+	points := make([]TimeseriesPoint, 0, len(messages))
+	state := &Rocket{Channel: channel, Status: "active"}
+	for _, message := range sinceSnapshot(messages) {
+		number := message.Metadata.MessageNumber
+		if number > lastApplied {
+			break
+		}
+		// A redelivered message was only applied once
+		if len(points) > 0 && points[len(points)-1].MessageNumber == number {
+			continue
+		}
+		if err := applyMessage(state, message); err != nil {
+			return nil, err
+		}
+
+		sent := message.Metadata.MessageTime
+		if len(points) > 0 && sent.Before(points[len(points)-1].Time) {
+			sent = points[len(points)-1].Time
+		}
+		points = append(points, TimeseriesPoint{MessageNumber: number, Time: sent, Value: state.Speed, Mission: state.Mission})
+	}
+
+	if query.Bucket == 0 {
+		return &Timeseries{Points: slices.DeleteFunc(points, func(point TimeseriesPoint) bool {
+			return query.From != nil && point.Time.Before(*query.From) || query.To != nil && point.Time.After(*query.To)
+		})}, nil
+	}
+	buckets, err := bucketPoints(points, query)
+	if err != nil {
+		return nil, err
+	}
+	return &Timeseries{Buckets: buckets}, nil
+}
+
+// bucketPoints downsamples the points, ordered by time, into buckets aligned on multiples of the bucket length. They
+// start at the bucket of the first point, or of From when it is later, and end at the bucket of To, or of the last
+// point.
+func bucketPoints(points []TimeseriesPoint, query TimeseriesQuery) ([]TimeseriesBucket, error) {
+	buckets := make([]TimeseriesBucket, 0)
+	if len(points) == 0 {
+		return buckets, nil
+	}
+
+	from, to := points[0].Time, points[len(points)-1].Time
+	if query.From != nil && query.From.After(from) {
+		from = *query.From
+	}
+	if query.To != nil {
+		to = *query.To
+	}
+	from, to = from.Truncate(query.Bucket), to.Truncate(query.Bucket)
+	if to.Before(from) {
+		return buckets, nil
+	}
+	if count := to.Sub(from)/query.Bucket + 1; count > MaxTimeseriesBuckets {
+		return nil, &ValidationError{Field: "bucket", Reason: fmt.Sprintf("makes %d buckets, more than %d", count, MaxTimeseriesBuckets)}
+	}
+
+	// held is the last point before the current bucket, whose value carries into it
+	var held *TimeseriesPoint
+	i := 0
+	for ; i < len(points) && points[i].Time.Before(from); i++ {
+		held = &points[i]
+	}
+	for start := from; !start.After(to); start = start.Add(query.Bucket) {
+		bucket := TimeseriesBucket{Start: start}
+		if held != nil {
+			bucket.Min, bucket.Max, bucket.Last, bucket.Mission = held.Value, held.Value, held.Value, held.Mission
+		}
+		end := start.Add(query.Bucket)
+		for ; i < len(points) && points[i].Time.Before(end); i++ {
+			point := points[i]
+			if held == nil && bucket.Messages == 0 {
+				bucket.Min, bucket.Max = point.Value, point.Value
+			}
+			bucket.Min, bucket.Max = min(bucket.Min, point.Value), max(bucket.Max, point.Value)
+			bucket.Last, bucket.Mission = point.Value, point.Mission
+			bucket.Messages++
+		}
+		if i > 0 {
+			held = &points[i-1]
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
@@ -0,0 +1,451 @@
+package rockets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lifecycle events a webhook can subscribe to.
+const (
+	RocketLaunchedEvent       = "rocket.launched"
+	RocketExplodedEvent       = "rocket.exploded"
+	RocketMissionChangedEvent = "rocket.missionChanged"
+)
+
+// LifecycleEvents are every event a webhook can subscribe to.
+var LifecycleEvents = []string{RocketLaunchedEvent, RocketExplodedEvent, RocketMissionChangedEvent}
+
+// Headers of the deliveries. The signature is the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the
+// secret of the webhook, see SignWebhook.
+const (
+	WebhookEventHeader     = "X-Rockets-Event"
+	WebhookDeliveryHeader  = "X-Rockets-Delivery"
+	WebhookTimestampHeader = "X-Rockets-Timestamp"
+	WebhookSignatureHeader = "X-Rockets-Signature"
+)
+
+type Webhook struct {
+	ID  uuid.UUID `json:"id"`
+	URL string    `json:"url"`
+	// Secret keys the signature of the deliveries.
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LifecycleEvent is the body of a delivery, with the rocket right after the message that made the change.
+type LifecycleEvent struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Rocket     Rocket    `json:"rocket"`
+	// PreviousMission is the mission a rocket changed from, only set on RocketMissionChangedEvent.
+	PreviousMission string `json:"previousMission,omitempty"`
+}
+
+// WebhookDelivery is an event that could not be delivered to a webhook after every attempt. Its ID stays the same
+// across attempts, so receivers can drop the ones they already got.
+type WebhookDelivery struct {
+	ID        uuid.UUID      `json:"id"`
+	WebhookID uuid.UUID      `json:"webhookId"`
+	Event     LifecycleEvent `json:"event"`
+	Attempts  int            `json:"attempts"`
+	Error     string         `json:"error"`
+	FailedAt  time.Time      `json:"failedAt"`
+}
+
+// WebhookPolicy sets how deliveries are retried before they are parked with the dead letters.
+type WebhookPolicy struct {
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failed attempt, it doubles after each following one up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// Workers is how many deliveries run at once, the others wait in a queue of QueueSize notifications. Events notified
+	// while the queue is full are parked with the dead letters without being attempted.
+	Workers   int
+	QueueSize int
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (p WebhookPolicy) backoff(failures int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < failures && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
+
+// WebhookService sends the lifecycle events of the rockets to the subscribed webhooks. Deliveries are retried in the
+// background and the ones that fail every attempt are stored as dead letters, to be inspected and redelivered.
+type WebhookService struct {
+	webhooks    WebhookRepository
+	deadLetters DeadLetterRepository
+	policy      WebhookPolicy
+	client      *http.Client
+
+	ctx           context.Context
+	cancel        context.CancelFunc
+	mu            sync.Mutex
+	notifications chan []LifecycleEvent
+	deliveries    chan webhookDelivery
+	running       sync.WaitGroup
+}
+
+// webhookDelivery is a delivery waiting for a worker, with the webhook it goes to.
+type webhookDelivery struct {
+	webhook  Webhook
+	delivery WebhookDelivery
+}
+
+func NewWebhookService(webhooks WebhookRepository, deadLetters DeadLetterRepository, policy WebhookPolicy) *WebhookService {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 5
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = time.Second
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = max(policy.InitialBackoff, 5*time.Minute)
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = 10 * time.Second
+	}
+	if policy.Workers < 1 {
+		policy.Workers = 4
+	}
+	if policy.QueueSize < 1 {
+		policy.QueueSize = 1000
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &WebhookService{
+		webhooks:      webhooks,
+		deadLetters:   deadLetters,
+		policy:        policy,
+		client:        &http.Client{Timeout: policy.Timeout},
+		ctx:           ctx,
+		cancel:        cancel,
+		notifications: make(chan []LifecycleEvent, policy.QueueSize),
+		deliveries:    make(chan webhookDelivery),
+	}
+	s.running.Add(1 + policy.Workers)
+	go s.dispatch()
+	for range policy.Workers {
+		go func() {
+			defer s.running.Done()
+			for next := range s.deliveries {
+				s.deliver(next.webhook, next.delivery)
+			}
+		}()
+	}
+	return s
+}
+
+// Subscribe stores a webhook for the events. A secret is generated when none is given.
+func (s *WebhookService) Subscribe(ctx context.Context, target string, events []string, secret string) (*Webhook, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	for _, event := range events {
+		if !slices.Contains(LifecycleEvents, event) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+	if secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(key)
+	}
+
+	webhook := Webhook{ID: uuid.New(), URL: target, Secret: secret, Events: slices.Compact(slices.Sorted(slices.Values(events))), CreatedAt: storedTime(time.Now())}
+	if err := s.webhooks.Store(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (s *WebhookService) Webhooks(ctx context.Context) ([]Webhook, error) {
+	return s.webhooks.All(ctx)
+}
+
+// Unsubscribe deletes the webhook. Its dead letters are kept to be inspected, they can't be redelivered anymore.
+func (s *WebhookService) Unsubscribe(ctx context.Context, id uuid.UUID) error {
+	return s.webhooks.Delete(ctx, id)
+}
+
+// FailedDeliveries returns the dead letters, of a single webhook when webhookID is set, the oldest first.
+func (s *WebhookService) FailedDeliveries(ctx context.Context, webhookID *uuid.UUID) ([]WebhookDelivery, error) {
+	deliveries, err := s.deadLetters.All(ctx)
+	if err != nil || webhookID == nil {
+		return deliveries, err
+	}
+	return slices.DeleteFunc(deliveries, func(delivery WebhookDelivery) bool { return delivery.WebhookID != *webhookID }), nil
+}
+
+func (s *WebhookService) FailedDelivery(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error) {
+	return s.deadLetters.FindByID(ctx, id)
+}
+
+// Redeliver tries the dead letter once more, now. It leaves the dead letters when it is delivered, otherwise the
+// failure is recorded and ErrDeliveryFailed returned with the updated delivery.
+func (s *WebhookService) Redeliver(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error) {
+	delivery, err := s.deadLetters.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := s.webhooks.FindByID(ctx, delivery.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Attempts++
+	if err := s.send(ctx, *webhook, delivery.ID, delivery.Event); err != nil {
+		delivery.Error = err.Error()
+		delivery.FailedAt = storedTime(time.Now())
+		if storeErr := s.deadLetters.Store(ctx, *delivery); storeErr != nil {
+			return nil, storeErr
+		}
+		return delivery, fmt.Errorf("%w: %s", ErrDeliveryFailed, err)
+	}
+	if err := s.deadLetters.Delete(ctx, delivery.ID); err != nil && !errors.Is(err, ErrDeliveryNotFound) {
+		return nil, err
+	}
+	delivery.Error = ""
+	return delivery, nil
+}
+
+// Notify queues the events of a rocket, in the order they happened, for the webhooks subscribed to them. It returns
+// right away, the deliveries run in the background. Events that can't be queued, because the queue is full or the
+// service is shutting down, are parked with the dead letters to be redelivered. A nil service notifies nothing.
+func (s *WebhookService) Notify(events []LifecycleEvent) {
+	if s == nil || len(events) == 0 {
+		return
+	}
+
+	if reason := s.queue(events); reason != "" {
+		slog.Warn("webhook events not queued, parking them", "channel", events[0].Rocket.Channel, "events", len(events), "reason", reason)
+		s.park(events, reason)
+	}
+}
+
+// queue adds the events to the queue, or tells why it can't.
+func (s *WebhookService) queue(events []LifecycleEvent) string {
+	// Shutdown closes the queue, nothing can be queued once it began
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return "not queued, the service was shutting down"
+	}
+	select {
+	case s.notifications <- events:
+		return ""
+	default:
+		return "not queued, the queue was full"
+	}
+}
+
+// park stores a dead letter of each event for every webhook subscribed to it, without attempting the delivery.
+func (s *WebhookService) park(events []LifecycleEvent, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.policy.Timeout)
+	defer cancel()
+	webhooks, err := s.webhooks.All(ctx)
+	if err != nil {
+		slog.Error("error finding webhooks, events lost", "channel", events[0].Rocket.Channel, "error", err)
+		return
+	}
+
+	failedAt := storedTime(time.Now())
+	for _, event := range events {
+		for _, webhook := range webhooks {
+			if !slices.Contains(webhook.Events, event.Type) {
+				continue
+			}
+			delivery := WebhookDelivery{ID: uuid.New(), WebhookID: webhook.ID, Event: event, Error: reason, FailedAt: failedAt}
+			if err := s.deadLetters.Store(ctx, delivery); err != nil {
+				slog.Error("error parking webhook delivery, it is lost", "webhook", webhook.ID, "delivery", delivery.ID, "error", err)
+			}
+		}
+	}
+}
+
+// dispatch hands the deliveries of the queued events to the workers, reading the webhooks once per notification.
+func (s *WebhookService) dispatch() {
+	defer s.running.Done()
+	defer close(s.deliveries)
+	for events := range s.notifications {
+		// The webhooks are still read on shutdown, so the queued events are parked rather than lost
+		webhooks, err := s.webhooks.All(context.WithoutCancel(s.ctx))
+		if err != nil {
+			slog.Error("error finding webhooks, events not delivered", "channel", events[0].Rocket.Channel, "error", err)
+			continue
+		}
+		for _, event := range events {
+			for _, webhook := range webhooks {
+				if slices.Contains(webhook.Events, event.Type) {
+					s.deliveries <- webhookDelivery{webhook: webhook, delivery: WebhookDelivery{ID: uuid.New(), WebhookID: webhook.ID, Event: event}}
+				}
+			}
+		}
+	}
+}
+
+// Shutdown stops retrying and waits for the queued deliveries and the ones in progress, the ones that didn't make it
+// are parked with the dead letters.
+func (s *WebhookService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx.Err() == nil {
+		s.cancel()
+		close(s.notifications)
+	}
+	s.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver sends the delivery until the webhook accepts it, backing off between attempts, and parks it when it runs
+// out of attempts or the service shuts down. Shutting down only cuts the waits, an attempt in progress is not.
+func (s *WebhookService) deliver(webhook Webhook, delivery WebhookDelivery) {
+	ctx := context.WithoutCancel(s.ctx)
+	for {
+		delivery.Attempts++
+		err := s.send(ctx, webhook, delivery.ID, delivery.Event)
+		if err == nil {
+			return
+		}
+		delivery.Error = err.Error()
+		if delivery.Attempts >= s.policy.MaxAttempts {
+			break
+		}
+
+		wait := time.NewTimer(s.policy.backoff(delivery.Attempts))
+		select {
+		case <-wait.C:
+			continue
+		case <-s.ctx.Done():
+			wait.Stop()
+		}
+		break
+	}
+
+	slog.Warn("webhook delivery failed, parking it", "webhook", webhook.ID, "delivery", delivery.ID, "attempts", delivery.Attempts, "error", delivery.Error)
+	delivery.FailedAt = storedTime(time.Now())
+	ctx, cancel := context.WithTimeout(ctx, s.policy.Timeout)
+	defer cancel()
+	if err := s.deadLetters.Store(ctx, delivery); err != nil {
+		slog.Error("error parking webhook delivery, it is lost", "webhook", webhook.ID, "delivery", delivery.ID, "error", err)
+	}
+}
+
+// send makes a single attempt, any answer but a 2xx is a failure.
+func (s *WebhookService) send(ctx context.Context, webhook Webhook, deliveryID uuid.UUID, event LifecycleEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.policy.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event.Type)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID.String())
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// SignWebhook returns the signature header of a delivery body sent at the timestamp, in Unix seconds. Receivers compute
+// it again with the secret and compare, and reject old timestamps so a captured delivery can't be replayed.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// lifecycleEvents returns the events of a message, from the state of the rocket before it to the state after. Every
+// applied message is compared on its own, so a mission changed twice reports both changes. A rocket that wasn't
+// launched before only reports its launch, and its explosion when a snapshot brings both at once.
+func lifecycleEvents(previous, current Rocket, now time.Time) []LifecycleEvent {
+	event := func(eventType string) LifecycleEvent {
+		return LifecycleEvent{ID: uuid.New(), Type: eventType, OccurredAt: storedTime(now), Rocket: cloneRocket(current)}
+	}
+
+	var events []LifecycleEvent
+	launched := previous.LastMessageNumber != nil && *previous.LastMessageNumber > 0
+	if !launched {
+		events = append(events, event(RocketLaunchedEvent))
+	}
+	if launched && previous.Mission != current.Mission {
+		changed := event(RocketMissionChangedEvent)
+		changed.PreviousMission = previous.Mission
+		events = append(events, changed)
+	}
+	if previous.Status != "exploded" && current.Status == "exploded" {
+		events = append(events, event(RocketExplodedEvent))
+	}
+	return events
+}
+
+// newlyApplied tells whether the message was not part of the stored state of the rocket, a rebuild applies those again
+// and they changed nothing.
+func newlyApplied(stored Rocket, number int) bool {
+	if stored.LastMessageNumber == nil || number > *stored.LastMessageNumber {
+		return true
+	}
+	for _, skipped := range stored.SkippedMessages {
+		if number >= skipped.From && number <= skipped.To {
+			return true
+		}
+	}
+	return false
+}
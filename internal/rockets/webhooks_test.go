@@ -0,0 +1,309 @@
+package rockets
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleEvents(t *testing.T) {
+	launched := *newRocket(uuid.New(), 1)
+	*launched.LastMessageNumber = 1
+	launched.Mission = "ARTEMIS"
+
+	types := func(events []LifecycleEvent) []string {
+		var names []string
+		for _, event := range events {
+			names = append(names, event.Type)
+		}
+		return names
+	}
+
+	// A snapshot launches and explodes it at once
+	exploded := cloneRocket(launched)
+	exploded.Status = "exploded"
+	assert.Equal(t, []string{RocketLaunchedEvent, RocketExplodedEvent}, types(lifecycleEvents(*newRocket(launched.Channel, 0), exploded, time.Now())))
+
+	changed := cloneRocket(launched)
+	changed.Mission = "APOLLO"
+	events := lifecycleEvents(launched, changed, time.Now())
+	require.Len(t, events, 1)
+	assert.Equal(t, RocketMissionChangedEvent, events[0].Type)
+	assert.Equal(t, "ARTEMIS", events[0].PreviousMission)
+
+	faster := cloneRocket(launched)
+	faster.Speed += 100
+	assert.Empty(t, lifecycleEvents(launched, faster, time.Now()))
+	assert.Empty(t, lifecycleEvents(exploded, exploded, time.Now()))
+}
+
+func TestWebhookService_RetriesThenParks(t *testing.T) {
+	ctx := context.Background()
+	var attempts atomic.Int32
+	var delivered atomic.Value
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhook("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Fails the first attempt of every delivery
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		delivered.Store(r.Header.Get(WebhookDeliveryHeader))
+	}))
+	defer receiver.Close()
+
+	deadLetters := NewMemoryDeadLetterRepository()
+	service := NewWebhookService(NewMemoryWebhookRepository(), deadLetters, WebhookPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+	working, err := service.Subscribe(ctx, receiver.URL, []string{RocketExplodedEvent}, "secret")
+	require.NoError(t, err)
+	broken, err := service.Subscribe(ctx, receiver.URL+"/gone", []string{RocketExplodedEvent}, "other secret")
+	require.NoError(t, err)
+	_, err = service.Subscribe(ctx, "ftp://example.com", []string{RocketExplodedEvent}, "")
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = service.Subscribe(ctx, receiver.URL, []string{"rocket.landed"}, "")
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+
+	rocket := *newRocket(uuid.New(), 1)
+	*rocket.LastMessageNumber = 2
+	exploded := cloneRocket(rocket)
+	exploded.Status = "exploded"
+	service.Notify(lifecycleEvents(rocket, exploded, time.Now()))
+	defer service.Shutdown(ctx)
+
+	// The working webhook gets it on the second attempt, the other one runs out of attempts
+	var deliveries []WebhookDelivery
+	require.Eventually(t, func() bool {
+		deliveries, err = service.FailedDeliveries(ctx, nil)
+		return err == nil && len(deliveries) == 1 && delivered.Load() != nil
+	}, 5*time.Second, 5*time.Millisecond)
+	parked := deliveries[0]
+	assert.Equal(t, broken.ID, parked.WebhookID)
+	assert.Equal(t, 2, parked.Attempts)
+	assert.Contains(t, parked.Error, "401")
+	assert.Equal(t, RocketExplodedEvent, parked.Event.Type)
+	assert.Equal(t, exploded.Channel, parked.Event.Rocket.Channel)
+	assert.NotEmpty(t, delivered.Load())
+	assert.NotEqual(t, parked.ID.String(), delivered.Load())
+
+	only, err := service.FailedDeliveries(ctx, &working.ID)
+	require.NoError(t, err)
+	assert.Empty(t, only)
+
+	// Redelivering fails the same way until the webhook is fixed
+	_, err = service.Redeliver(ctx, parked.ID)
+	assert.ErrorIs(t, err, ErrDeliveryFailed)
+	stored, err := deadLetters.FindByID(ctx, parked.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stored.Attempts)
+
+	broken.URL, broken.Secret = receiver.URL, "secret"
+	require.NoError(t, service.webhooks.Store(ctx, *broken))
+	redelivered, err := service.Redeliver(ctx, parked.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, redelivered.Attempts)
+	assert.Equal(t, parked.ID.String(), delivered.Load())
+	_, err = service.FailedDelivery(ctx, parked.ID)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+}
+
+func TestWebhookService_Body(t *testing.T) {
+	received := make(chan LifecycleEvent, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event LifecycleEvent
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		assert.Equal(t, RocketMissionChangedEvent, r.Header.Get(WebhookEventHeader))
+		received <- event
+	}))
+	defer receiver.Close()
+
+	service := NewWebhookService(NewMemoryWebhookRepository(), NewMemoryDeadLetterRepository(), WebhookPolicy{})
+	defer service.Shutdown(context.Background())
+	_, err := service.Subscribe(context.Background(), receiver.URL, []string{RocketMissionChangedEvent}, "")
+	require.NoError(t, err)
+
+	rocket := *newRocket(uuid.New(), 1)
+	*rocket.LastMessageNumber = 1
+	rocket.Mission = "ARTEMIS"
+	changed := cloneRocket(rocket)
+	changed.Mission = "APOLLO"
+	service.Notify(lifecycleEvents(rocket, changed, time.Now()))
+
+	select {
+	case event := <-received:
+		assert.Equal(t, RocketMissionChangedEvent, event.Type)
+		assert.Equal(t, "ARTEMIS", event.PreviousMission)
+		assert.Equal(t, "APOLLO", event.Rocket.Mission)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery received")
+	}
+}
+
+func TestWebhookService_NotifiesEveryAppliedMessage(t *testing.T) {
+	ctx := context.Background()
+	received := make(chan LifecycleEvent, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event LifecycleEvent
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+	}))
+	defer receiver.Close()
+
+	for name, newService := range map[string]func(MessageRepository, RocketsRepository, *WebhookService) MessageService{
+		"log": func(messages MessageRepository, rockets RocketsRepository, webhooks *WebhookService) MessageService {
+			service := NewResequencerMessageService(messages, rockets, GapPolicy{})
+			service.NotifyWebhooks(webhooks)
+			return service
+		},
+		"buffered": func(messages MessageRepository, rockets RocketsRepository, webhooks *WebhookService) MessageService {
+			service := NewBufferedMessageService(messages, rockets, GapPolicy{})
+			service.NotifyWebhooks(webhooks)
+			return service
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			webhooks := NewWebhookService(NewMemoryWebhookRepository(), NewMemoryDeadLetterRepository(), WebhookPolicy{Workers: 1})
+			defer webhooks.Shutdown(ctx)
+			_, err := webhooks.Subscribe(ctx, receiver.URL, LifecycleEvents, "")
+			require.NoError(t, err)
+			service := newService(NewMemoryMessageRepository(), NewMemoryRocketsRepository(), webhooks)
+
+			// The mission goes to APOLLO and back within one batch, which the states before and after it don't show
+			channel := uuid.New()
+			sent := time.Now()
+			batch := []Message{
+				{Metadata: Metadata{Channel: channel, MessageNumber: 1, MessageTime: sent, MessageType: RocketLaunched}, Message: map[string]interface{}{"type": "Falcon-9", "launchSpeed": float64(500), "mission": "ARTEMIS"}},
+				{Metadata: Metadata{Channel: channel, MessageNumber: 2, MessageTime: sent, MessageType: RocketMissionChanged}, Message: map[string]interface{}{"newMission": "APOLLO"}},
+				{Metadata: Metadata{Channel: channel, MessageNumber: 3, MessageTime: sent, MessageType: RocketMissionChanged}, Message: map[string]interface{}{"newMission": "ARTEMIS"}},
+				{Metadata: Metadata{Channel: channel, MessageNumber: 4, MessageTime: sent, MessageType: RocketExploded}, Message: map[string]interface{}{"reason": "PRESSURE_VESSEL_FAILURE"}},
+			}
+			for _, result := range service.IngestBatch(ctx, batch) {
+				require.Equal(t, IngestAccepted, result.Status)
+			}
+			// Processing the channel again applies nothing new
+			require.NoError(t, service.Process(ctx, Message{Metadata: Metadata{Channel: channel}}))
+
+			var events []LifecycleEvent
+			for range 4 {
+				select {
+				case event := <-received:
+					events = append(events, event)
+				case <-time.After(5 * time.Second):
+					t.Fatal("missing deliveries")
+				}
+			}
+			assert.Equal(t, RocketLaunchedEvent, events[0].Type)
+			assert.Equal(t, RocketMissionChangedEvent, events[1].Type)
+			assert.Equal(t, "ARTEMIS", events[1].PreviousMission)
+			assert.Equal(t, "APOLLO", events[1].Rocket.Mission)
+			assert.Equal(t, RocketMissionChangedEvent, events[2].Type)
+			assert.Equal(t, "APOLLO", events[2].PreviousMission)
+			assert.Equal(t, "ARTEMIS", events[2].Rocket.Mission)
+			assert.Equal(t, RocketExplodedEvent, events[3].Type)
+
+			require.NoError(t, webhooks.Shutdown(ctx))
+			assert.Empty(t, received)
+		})
+	}
+}
+
+func TestWebhookService_BoundsConcurrentDeliveries(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var inFlight, peak, delivered int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		inFlight--
+		delivered++
+		mu.Unlock()
+	}))
+	count := func(value *int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return *value
+	}
+	defer receiver.Close()
+
+	service := NewWebhookService(NewMemoryWebhookRepository(), NewMemoryDeadLetterRepository(), WebhookPolicy{Workers: 2})
+	defer service.Shutdown(ctx)
+	_, err := service.Subscribe(ctx, receiver.URL, []string{RocketLaunchedEvent}, "")
+	require.NoError(t, err)
+
+	for range 10 {
+		service.Notify(lifecycleEvents(*newRocket(uuid.New(), 0), *newRocket(uuid.New(), 0), time.Now()))
+	}
+
+	// The other deliveries wait for one of the two workers
+	require.Eventually(t, func() bool { return count(&inFlight) == 2 }, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 2, count(&inFlight))
+
+	close(release)
+	require.Eventually(t, func() bool { return count(&delivered) == 10 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, 2, count(&peak))
+}
+
+func TestWebhookService_ParksWhatOverflowsTheQueue(t *testing.T) {
+	ctx := context.Background()
+	received, release := make(chan struct{}, 10), make(chan struct{})
+	var delivered atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		delivered.Add(1)
+	}))
+	defer receiver.Close()
+
+	service := NewWebhookService(NewMemoryWebhookRepository(), NewMemoryDeadLetterRepository(), WebhookPolicy{Workers: 1, QueueSize: 1})
+	webhook, err := service.Subscribe(ctx, receiver.URL, []string{RocketLaunchedEvent}, "")
+	require.NoError(t, err)
+	launch := func() []LifecycleEvent {
+		return lifecycleEvents(*newRocket(uuid.New(), 0), *newRocket(uuid.New(), 0), time.Now())
+	}
+
+	// The worker is stuck on the first delivery, the dispatcher on the second and the third fills the queue
+	service.Notify(launch())
+	<-received
+	service.Notify(launch())
+	time.Sleep(20 * time.Millisecond)
+	service.Notify(launch())
+	overflowing := launch()
+	service.Notify(overflowing)
+
+	parked, err := service.FailedDeliveries(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, parked, 1)
+	assert.Equal(t, webhook.ID, parked[0].WebhookID)
+	assert.Equal(t, overflowing[0].ID, parked[0].Event.ID)
+	assert.Equal(t, 0, parked[0].Attempts)
+	assert.Contains(t, parked[0].Error, "queue was full")
+
+	// The queued ones are still delivered, and the parked one can be
+	close(release)
+	require.Eventually(t, func() bool { return delivered.Load() == 3 }, 5*time.Second, time.Millisecond)
+	_, err = service.Redeliver(ctx, parked[0].ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, delivered.Load())
+	require.NoError(t, service.Shutdown(ctx))
+}